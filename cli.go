@@ -0,0 +1,350 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"register-bot/internal/config"
+	"register-bot/internal/history"
+	"register-bot/internal/tasks"
+)
+
+const usage = `Usage: register-bot <command> [flags]
+
+Commands:
+  run          Run every task in the task file (the default with no command)
+  signup       Sign up for CRNs, optionally waiting for registration to open
+  watch        Watch CRNs and sign up as soon as a seat or waitlist spot opens
+  search       Export the sections of a subject, optionally filtered
+  catalog      Export every section of every subject in a term to JSON
+  schedule     List conflict-free section combinations for a set of courses
+  transcript   Export your unofficial transcript
+  terms        List the terms Banner currently offers
+  history      Report seat history recorded by search, catalog and watch
+  diff         Compare two catalog snapshots of a term
+  status       Check whether registration is open for a term
+  validate     Check CRNs for schedule conflicts without signing up
+  current      List the classes you are registered or waitlisted in
+  reconcile    Add, waitlist and drop classes until your schedule is exactly -crns
+  store        Save a login to the encrypted vault or the system keyring
+
+Run "register-bot <command> -h" for the flags of a command.
+`
+
+// command is one parsed invocation.
+type command struct {
+	Name string
+	// ConfigPath is the task file for run; empty means REGISTER_BOT_CONFIG or
+	// the first default file in config/.
+	ConfigPath      string
+	CredentialsPath string
+	// Database is the history database the history command reports on.
+	Database string
+	// Args are the snapshot files the diff command compares, and Notify
+	// sends the diff to the webhooks.
+	Args   []string
+	Notify bool
+	// Store is the credential store logins are looked up in and the store
+	// command saves to: file, vault or keyring.
+	Store string
+	// Endpoints are the -*-url flags, which override REGISTER_BOT_*_URL and
+	// the task file's endpoints block.
+	Endpoints config.FileEndpoints
+	// Task describes the single task run by signup, watch, search,
+	// transcript, status and the other single-task commands.
+	Task config.FileTask
+}
+
+// errHelp and errUsage mean the usage text has already been printed, on
+// request or because the command line was wrong.
+var (
+	errHelp  = errors.New("help requested")
+	errUsage = errors.New("invalid command line")
+)
+
+// parseCommand parses the command line, not including the program name.
+func parseCommand(args []string, output io.Writer) (command, error) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		args = append([]string{"run"}, args...)
+	}
+
+	cmd := command{Name: args[0]}
+	flags := flag.NewFlagSet("register-bot "+cmd.Name, flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.StringVar(&cmd.CredentialsPath, "credentials", "config/.credentials", "credentials `file`")
+	cmd.Store = config.StoreName()
+
+	var crns, dropCRNs, courses, instructors string
+	task := &cmd.Task
+	switch cmd.Name {
+	case "diff":
+		flags.BoolVar(&cmd.Notify, "notify", false, "also send the diff to the webhook")
+	case "run":
+		flags.StringVar(&cmd.ConfigPath, "config", "", "task `file` (.yaml, .yml, .json or .csv)")
+	case "signup":
+		task.Mode = "Signup"
+		flags.StringVar(&task.Term, "term", "", "academic `term`, e.g. \"2026 Winter De Anza\"")
+		flags.StringVar(&crns, "crns", "", "comma-separated `CRNs` to add")
+		flags.StringVar(&dropCRNs, "drop", "", "comma-separated `CRNs` to drop in the same transaction")
+		flags.StringVar(&task.RegistrationTime, "at", "", "wait until this registration `time` (\""+config.ScheduleLayout+"\", Pacific)")
+		flags.StringVar(&task.OnConflict, "on-conflict", "", "refuse or warn when the CRNs overlap in time (default refuse)")
+		flags.BoolVar(&task.SafeSwap, "safe-swap", false, "only drop each -drop CRN if the -crns CRN in the same position is added")
+		flags.BoolVar(&task.DryRun, "dry-run", false, "check and print the batch that would be submitted, then clear the worksheet without submitting")
+		flags.Func("alternates", "fall back to these sections when a CRN is full or rejected, as `CRN=ALT,ALT`; repeat for each CRN", func(value string) error {
+			crn, alternates, ok := strings.Cut(value, "=")
+			if crn = strings.TrimSpace(crn); !ok || crn == "" {
+				return fmt.Errorf("%q should look like 41846=44412,45000", value)
+			}
+			if task.Alternates == nil {
+				task.Alternates = make(map[string]config.CRNList)
+			}
+			task.Alternates[crn] = append(task.Alternates[crn], splitFlag(alternates)...)
+			return nil
+		})
+	case "watch":
+		task.Mode = "Watch"
+		flags.StringVar(&task.Term, "term", "", "academic `term`, e.g. \"2026 Winter De Anza\"")
+		flags.StringVar(&crns, "crns", "", "comma-separated `CRNs` to watch")
+		flags.StringVar(&dropCRNs, "drop", "", "comma-separated `CRNs` to drop when the first one opens")
+		flags.BoolVar(&task.SafeSwap, "safe-swap", false, "drop each -drop CRN only with the -crns CRN in the same position, and only if it is added")
+		flags.StringVar(&task.PollInterval, "interval", "", "how often to poll each CRN, e.g. \"10s\"")
+		flags.Func("crn-interval", "poll one CRN on its own schedule, as `CRN=DURATION`; repeat for each CRN", func(value string) error {
+			crn, interval, ok := strings.Cut(value, "=")
+			if crn = strings.TrimSpace(crn); !ok || crn == "" {
+				return fmt.Errorf("%q should look like 41846=30s", value)
+			}
+			if task.Intervals == nil {
+				task.Intervals = make(map[string]string)
+			}
+			task.Intervals[crn] = strings.TrimSpace(interval)
+			return nil
+		})
+		flags.Func("jitter", "spread each poll by up to this `fraction` of its interval, 0 for a fixed cadence (default 0.1)", func(value string) error {
+			jitter, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("%q is not a number", value)
+			}
+			task.Jitter = &jitter
+			return nil
+		})
+		flags.StringVar(&task.MaxDuration, "max-duration", "", "stop watching after this long, e.g. \"2h\"")
+		flags.StringVar(&task.History, "history", "", "record seat counts in this history `database`, e.g. "+history.DefaultPath)
+	case "search":
+		task.Mode = "Search"
+		flags.StringVar(&task.Term, "term", "", "academic `term`, e.g. \"2026 Winter De Anza\"")
+		flags.StringVar(&task.Subject, "subject", "", "`subject` to search, e.g. MATH")
+		flags.StringVar(&task.Output, "output", "", "`path` or directory to write to")
+		flags.StringVar(&task.Format, "format", "", "export `format`: csv, json, ndjson or sqlite (default from -output, else csv)")
+		flags.StringVar(&task.CourseNumber, "course", "", "only this course `number`, e.g. 1C")
+		flags.StringVar(&task.Instructor, "instructor", "", "only sections taught by an instructor whose `name` contains this")
+		flags.BoolVar(&task.OpenOnly, "open", false, "only sections with open seats")
+		flags.StringVar(&task.Campus, "campus", "", "only this `campus`, e.g. \"De Anza\"")
+		flags.StringVar(&task.Method, "method", "", "only this instructional `method`, e.g. Online or Hybrid")
+		flags.StringVar(&task.Days, "days", "", "only sections meeting on these `days`, any of UMTWRFS")
+		flags.StringVar(&task.StartsAfter, "after", "", "only sections starting at or after this `time`, e.g. 09:00")
+		flags.StringVar(&task.EndsBefore, "before", "", "only sections ending at or before this `time`, e.g. \"3:30 PM\"")
+		flags.StringVar(&task.History, "history", "", "record seat counts in this history `database`, e.g. "+history.DefaultPath)
+	case "catalog":
+		task.Mode = "Catalog"
+		flags.StringVar(&task.Term, "term", "", "academic `term`, e.g. \"2026 Winter De Anza\"")
+		flags.StringVar(&task.Output, "output", "", "JSON `path` or directory to write to")
+		flags.BoolVar(&task.OpenOnly, "open", false, "only sections with open seats")
+		flags.StringVar(&task.History, "history", "", "record seat counts in this history `database`, e.g. "+history.DefaultPath)
+	case "schedule":
+		task.Mode = "Schedule"
+		flags.StringVar(&task.Term, "term", "", "academic `term`, e.g. \"2026 Winter De Anza\"")
+		flags.StringVar(&courses, "courses", "", "comma-separated `courses` to take, e.g. \"MATH 1C,PHYS 4A\"")
+		flags.StringVar(&task.Prefer.StartsAfter, "after", "", "prefer classes starting at or after this `time`, e.g. 09:00")
+		flags.StringVar(&task.Prefer.EndsBefore, "before", "", "prefer classes ending at or before this `time`, e.g. \"3:30 PM\"")
+		flags.StringVar(&task.Prefer.DaysOff, "days-off", "", "prefer no classes on these `days`, any of UMTWRFS")
+		flags.StringVar(&task.Prefer.Campus, "campus", "", "prefer this `campus`, e.g. \"De Anza\"")
+		flags.StringVar(&task.Prefer.Method, "method", "", "prefer this instructional `method`, e.g. Online or \"In Person\"")
+		flags.StringVar(&instructors, "instructor", "", "prefer instructors whose `names` contain any of these, comma-separated")
+		flags.BoolVar(&task.Prefer.OpenSeats, "open", false, "prefer sections with open seats")
+		flags.IntVar(&task.Limit, "limit", 0, "list at most this `many` schedules (default 10)")
+		flags.StringVar(&task.Output, "output", "", "also export the schedules to this `path` or directory")
+		flags.StringVar(&task.Format, "format", "", "export `format`: csv, json, ndjson or sqlite (default from -output, else csv)")
+		flags.StringVar(&task.History, "history", "", "record seat counts in this history `database`, e.g. "+history.DefaultPath)
+	case "transcript":
+		task.Mode = "Transcript"
+		flags.StringVar(&task.Output, "output", "", "`path` or directory to write to")
+		flags.StringVar(&task.Format, "format", "", "export `format`: csv, json, ndjson or sqlite (default from -output, else csv)")
+	case "status":
+		task.Mode = "Status"
+		flags.StringVar(&task.Term, "term", "", "academic `term`, e.g. \"2026 Winter De Anza\"")
+	case "validate":
+		task.Mode = "Validate"
+		flags.StringVar(&task.Term, "term", "", "academic `term`, e.g. \"2026 Winter De Anza\"")
+		flags.StringVar(&crns, "crns", "", "comma-separated `CRNs` to check")
+	case "current":
+		task.Mode = "Current"
+		flags.StringVar(&task.Term, "term", "", "academic `term`, e.g. \"2026 Winter De Anza\"")
+		flags.StringVar(&task.Output, "output", "", "also export the schedule to this `path` or directory")
+		flags.StringVar(&task.Format, "format", "", "export `format`: csv, json, ndjson or sqlite (default from -output, else csv)")
+	case "reconcile":
+		task.Mode = "Reconcile"
+		flags.StringVar(&task.Term, "term", "", "academic `term`, e.g. \"2026 Winter De Anza\"")
+		flags.StringVar(&crns, "crns", "", "comma-separated `CRNs` your schedule should be, including ones you already have")
+		flags.StringVar(&task.OnConflict, "on-conflict", "", "refuse or warn when the CRNs overlap in time (default refuse)")
+		flags.BoolVar(&task.DryRun, "dry-run", false, "check and print the batch that would be submitted, then clear the worksheet without submitting")
+	case "store":
+		flags.StringVar(&task.Account, "account", "", "save the login of this credentials `profile` instead of the default credentials")
+		flags.StringVar(&cmd.Store, "store", cmd.Store, "credential `store` to save to: vault or keyring (default from REGISTER_BOT_CREDENTIAL_STORE)")
+	case "terms":
+	case "history":
+		flags.StringVar(&task.Term, "term", "", "only this `term`, e.g. \"2026 Winter De Anza\" or 202632")
+		flags.StringVar(&crns, "crns", "", "only these comma-separated `CRNs`")
+		flags.StringVar(&task.Subject, "subject", "", "only this `subject`, e.g. MATH")
+		flags.StringVar(&cmd.Database, "db", history.DefaultPath, "history `database` to report on")
+	case "help":
+		fmt.Fprint(output, usage)
+		return cmd, errHelp
+	default:
+		fmt.Fprintf(output, "Unknown command %q\n\n%s", cmd.Name, usage)
+		return cmd, errUsage
+	}
+
+	if task.Mode != "" {
+		flags.StringVar(&task.Account, "account", "", "sign in with this credentials `profile` instead of the default credentials")
+	}
+	if task.Mode != "" || cmd.Name == "run" || cmd.Name == "terms" {
+		flags.StringVar(&cmd.Endpoints.Registration, "registration-url", "", "Banner registration base `URL` (default "+tasks.DefaultEndpoints.Registration+")")
+		flags.StringVar(&cmd.Endpoints.SSO, "sso-url", "", "SSO base `URL` (default "+tasks.DefaultEndpoints.SSO+")")
+		flags.StringVar(&cmd.Endpoints.EIS, "eis-url", "", "EIS base `URL` (default "+tasks.DefaultEndpoints.EIS+")")
+		flags.StringVar(&cmd.Endpoints.DegreeWorks, "degreeworks-url", "", "DegreeWorks base `URL` (default "+tasks.DefaultEndpoints.DegreeWorks+")")
+	}
+
+	flags.Usage = func() {
+		if cmd.Name == "diff" {
+			fmt.Fprintf(output, "Usage: register-bot diff [flags] <older snapshot> <newer snapshot>\n\nFlags:\n")
+		} else {
+			fmt.Fprintf(output, "Usage: register-bot %s [flags]\n\nFlags:\n", cmd.Name)
+		}
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args[1:]); errors.Is(err, flag.ErrHelp) {
+		return cmd, errHelp
+	} else if err != nil {
+		return cmd, errUsage
+	}
+	if cmd.Name == "diff" {
+		if flags.NArg() != 2 {
+			fmt.Fprintf(output, "diff needs two catalog snapshots, got %d\n", flags.NArg())
+			flags.Usage()
+			return cmd, errUsage
+		}
+		cmd.Args = flags.Args()
+	} else if flags.NArg() > 0 {
+		fmt.Fprintf(output, "Unexpected argument %q\n", flags.Arg(0))
+		flags.Usage()
+		return cmd, errUsage
+	}
+
+	if task.Mode == "Signup" && task.RegistrationTime != "" {
+		task.Mode = "Release"
+	}
+	task.CRNs = splitFlag(crns)
+	task.DropCRNs = splitFlag(dropCRNs)
+	task.Courses = splitFlag(courses)
+	task.Prefer.Instructors = splitFlag(instructors)
+	return cmd, nil
+}
+
+// splitFlag splits a comma-separated flag value, dropping blanks.
+func splitFlag(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// taskConfig validates the command's task the same way a task file entry is
+// validated, so flags and files report problems alike.
+func (cmd command) taskConfig(credentials config.Credentials) (*config.TaskConfig, error) {
+	cfg, errs := cmd.Task.TaskConfig()
+	if len(errs) > 0 {
+		for i, err := range errs {
+			errs[i] = fmt.Errorf("%s: %w", cmd.Name, flagName(err))
+		}
+		return nil, errors.Join(errs...)
+	}
+	cfg.Name = cmd.Name
+	if err := credentials.Apply(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// endpoints returns the service URLs from the -*-url flags, falling back to
+// REGISTER_BOT_*_URL.
+func (cmd command) endpoints() (tasks.Endpoints, error) {
+	endpoints, errs := cmd.Endpoints.Endpoints()
+	if len(errs) > 0 {
+		for i, err := range errs {
+			errs[i] = fmt.Errorf("%s: %w", cmd.Name, flagName(err))
+		}
+		return tasks.Endpoints{}, errors.Join(errs...)
+	}
+	env, err := config.EnvEndpoints()
+	if err != nil {
+		return tasks.Endpoints{}, err
+	}
+	return endpoints.Or(env), nil
+}
+
+// flagName rewrites a task file field name at the start of err as the
+// matching command-line flag.
+func flagName(err error) error {
+	fields := map[string]string{
+		"crns":                "-crns",
+		"drop_crns":           "-drop",
+		"registration_time":   "-at",
+		"poll_interval":       "-interval",
+		"intervals":           "-crn-interval",
+		"max_duration":        "-max-duration",
+		"course_number":       "-course",
+		"starts_after":        "-after",
+		"ends_before":         "-before",
+		"on_conflict":         "-on-conflict",
+		"safe_swap":           "-safe-swap",
+		"dry_run":             "-dry-run",
+		"prefer.starts_after": "-after",
+		"prefer.ends_before":  "-before",
+		"prefer.days_off":     "-days-off",
+		"registration":        "-registration-url",
+		"sso":                 "-sso-url",
+		"eis":                 "-eis-url",
+		"degreeworks":         "-degreeworks-url",
+	}
+	message := err.Error()
+	field, rest, _ := strings.Cut(message, ":")
+	base, index, _ := strings.Cut(field, "[")
+	if name, ok := fields[base]; ok {
+		base = name
+	} else {
+		base = "-" + base
+	}
+	if index != "" {
+		base += "[" + index
+	}
+	return errors.New(base + ":" + rest)
+}
+
+// exitUsage ends a run whose command line could not be used.
+func exitUsage(err error) {
+	if errors.Is(err, errHelp) {
+		os.Exit(0)
+	}
+	if !errors.Is(err, errUsage) {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	os.Exit(2)
+}
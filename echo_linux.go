@@ -0,0 +1,28 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// disableEcho turns off echo on file if it is a terminal. The returned
+// function turns it back on and reports whether echo had been turned off.
+func disableEcho(file *os.File) func() bool {
+	fd := int(file.Fd())
+	termios, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return func() bool { return false }
+	}
+	saved := *termios
+	termios.Lflag &^= unix.ECHO
+	if err := unix.IoctlSetTermios(fd, unix.TCSETS, termios); err != nil {
+		return func() bool { return false }
+	}
+	return func() bool {
+		unix.IoctlSetTermios(fd, unix.TCSETS, &saved)
+		return true
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package main
+
+import "os"
+
+// disableEcho is not supported here, so secrets are echoed as they are
+// typed.
+func disableEcho(file *os.File) func() bool {
+	return func() bool { return false }
+}
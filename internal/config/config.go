@@ -0,0 +1,294 @@
+// Package config loads task configurations from config/tasks.yaml,
+// config/tasks.json or the legacy config/settings.csv.
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"register-bot/internal/tasks"
+)
+
+// TaskConfig represents a single task configuration
+type TaskConfig struct {
+	Name             string
+	Term             string
+	Subject          string
+	Mode             string
+	CRNs             []string
+	DropCRNs         []string
+	Alternates       map[string][]string
+	Swaps            map[string]string
+	DryRun           bool
+	RegistrationTime string
+	WatchOptions     tasks.WatchOptions
+	Notify           []string
+	Schedule         time.Time
+	Priority         int
+	Output           string
+	Format           string
+	History          string
+	OnConflict       string
+	Courses          []string
+	Preferences      tasks.SchedulePreferences
+	Limit            int
+	Filters          tasks.SearchFilters
+	Username         string
+	Password         string
+	WebhookURL       string
+	SessionFile      string
+	Account          string
+	LogFile          string
+	Endpoints        tasks.Endpoints
+	// SettingsFile and SettingsRow locate a task loaded from settings.csv,
+	// counting the header as row 0, so its SavedRegistrationTime can be
+	// written back.
+	SettingsFile string
+	SettingsRow  int
+}
+
+// Label names the task in log output.
+func (c *TaskConfig) Label() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	if c.Term != "" {
+		return c.Term
+	}
+	return c.Mode
+}
+
+// Credentials are the username, password and webhook read from
+// config/.credentials, and where the login session is saved next to it.
+// Profiles are the named accounts from the file's [name] sections, for
+// running tasks as several students at once.
+type Credentials struct {
+	// Name is the profile's name, empty for the default credentials.
+	Name     string
+	Username string
+	Password string
+	Webhook  string
+	Session  string
+	// Log is a file a profile's task output is also written to.
+	Log      string
+	Profiles map[string]Credentials
+	// Store, when set, is consulted for logins before the file.
+	Store CredentialStore
+
+	dir string
+}
+
+// LoadCredentials reads username, password, and webhook from a .credentials
+// file. Lines after a [name] header belong to that profile, which may also
+// set log=. A missing file yields empty credentials.
+func LoadCredentials(path string) Credentials {
+	dir := filepath.Dir(path)
+	credentials := Credentials{Session: filepath.Join(dir, ".session"), dir: dir}
+	file, err := os.Open(path)
+	if err != nil {
+		return credentials
+	}
+	defer file.Close()
+
+	current := &credentials
+	var profile Credentials
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			if current != &credentials {
+				credentials.addProfile(profile)
+			}
+			profile = credentials.newProfile(strings.TrimSpace(line[1 : len(line)-1]))
+			current = &profile
+		} else if strings.HasPrefix(line, "username=") {
+			current.Username = strings.TrimPrefix(line, "username=")
+		} else if strings.HasPrefix(line, "password=") {
+			current.Password = strings.TrimPrefix(line, "password=")
+		} else if strings.HasPrefix(line, "webhook=") {
+			current.Webhook = strings.TrimPrefix(line, "webhook=")
+		} else if strings.HasPrefix(line, "log=") && current != &credentials {
+			current.Log = strings.TrimPrefix(line, "log=")
+		}
+	}
+	if current != &credentials {
+		credentials.addProfile(profile)
+	}
+
+	return credentials
+}
+
+// newProfile returns an empty profile with its own session file and log.
+func (credentials Credentials) newProfile(name string) Credentials {
+	return Credentials{
+		Name:    name,
+		Session: filepath.Join(credentials.dir, ".session-"+name),
+		Log:     filepath.Join("logs", name+".log"),
+	}
+}
+
+func (credentials *Credentials) addProfile(profile Credentials) {
+	if credentials.Profiles == nil {
+		credentials.Profiles = make(map[string]Credentials)
+	}
+	credentials.Profiles[strings.ToLower(profile.Name)] = profile
+}
+
+// profile returns the named profile from the file, or an empty one with its
+// own session file and log if the file has no such section. The empty name
+// is the default credentials.
+func (credentials Credentials) profile(name string) Credentials {
+	if name == "" {
+		return credentials
+	}
+	if profile, ok := credentials.Profiles[strings.ToLower(name)]; ok {
+		return profile
+	}
+	return credentials.newProfile(name)
+}
+
+// env reads the profile's environment variable for key:
+// REGISTER_BOT_<key> for the default credentials, REGISTER_BOT_<NAME>_<key>
+// for a profile.
+func (credentials Credentials) env(key string) string {
+	if credentials.Name == "" {
+		return os.Getenv("REGISTER_BOT_" + key)
+	}
+	name := strings.ToUpper(strings.ReplaceAll(credentials.Name, "-", "_"))
+	return os.Getenv("REGISTER_BOT_" + name + "_" + key)
+}
+
+// providers are where logins are looked up, in priority order: env vars >
+// Store > credentials file.
+func (credentials Credentials) providers() []CredentialProvider {
+	providers := []CredentialProvider{EnvProvider{}}
+	if credentials.Store != nil {
+		providers = append(providers, credentials.Store)
+	}
+	return append(providers, credentials)
+}
+
+// Login looks up a profile's login, each field from the first provider that
+// has it.
+func (credentials Credentials) Login(profile string) (Login, error) {
+	var login Login
+	for _, provider := range credentials.providers() {
+		found, err := provider.Lookup(profile)
+		if err != nil {
+			return Login{}, err
+		}
+		login.fill(found)
+	}
+	return login, nil
+}
+
+// Apply fills in the task's username, password and webhook from the profile
+// named by its account, or the default credentials, with priority: env vars
+// > credential store > credentials file.
+func (credentials Credentials) Apply(config *TaskConfig) error {
+	login, err := credentials.Login(config.Account)
+	if err != nil {
+		return err
+	}
+	var sources []string
+	for _, provider := range credentials.providers() {
+		sources = append(sources, provider.String())
+	}
+	source := strings.Join(sources[:len(sources)-1], ", ") + " or " + sources[len(sources)-1]
+	if config.Account != "" {
+		if login.Username == "" {
+			return fmt.Errorf("account %q not found in %s", config.Account, source)
+		}
+		source = fmt.Sprintf("%s for [%s]", source, config.Account)
+	}
+
+	if login.Username == "" {
+		return fmt.Errorf("username not found in %s", source)
+	}
+	config.Username = login.Username
+	if login.Password == "" {
+		return fmt.Errorf("password not found in %s", source)
+	}
+	config.Password = login.Password
+
+	// Webhook is optional
+	profile := credentials.profile(config.Account)
+	config.WebhookURL = login.Webhook
+	config.SessionFile = profile.SessionFile()
+	if config.Account != "" {
+		config.LogFile = profile.Log
+	}
+	return nil
+}
+
+// SessionFile returns where the login session is saved with priority: env
+// var > next to the credentials file. "off" turns saving off, and
+// REGISTER_BOT_SESSION=off turns it off for every profile.
+func (credentials Credentials) SessionFile() string {
+	path := credentials.Session
+	if envSession := credentials.env("SESSION"); envSession != "" {
+		path = envSession
+	}
+	if path == "off" || os.Getenv("REGISTER_BOT_SESSION") == "off" {
+		return ""
+	}
+	return path
+}
+
+// WebhookURL returns the default credentials' webhook with priority: env
+// var > credential store > credentials file.
+func (credentials Credentials) WebhookURL() string {
+	login, _ := credentials.Login("")
+	return login.Webhook
+}
+
+// DefaultFiles are tried in order by Find.
+var DefaultFiles = []string{"tasks.yaml", "tasks.yml", "tasks.json", "settings.csv"}
+
+// Find returns the first of DefaultFiles that exists in dir.
+func Find(dir string) (string, error) {
+	for _, name := range DefaultFiles {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no task configuration found in %s (looked for %s)", dir, strings.Join(DefaultFiles, ", "))
+}
+
+// Load reads task configurations from path, picking the format by file
+// extension, and applies credentials to each task. Tasks are returned
+// highest priority first.
+func Load(path string, credentials Credentials) ([]*TaskConfig, error) {
+	var configs []*TaskConfig
+	var err error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		configs, err = loadYAML(path)
+	case ".json":
+		configs, err = loadJSON(path)
+	case ".csv":
+		configs, err = loadCSV(path)
+	default:
+		return nil, fmt.Errorf("%s: unsupported configuration format (want .yaml, .yml, .json or .csv)", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, config := range configs {
+		if err := credentials.Apply(config); err != nil {
+			return nil, fmt.Errorf("%s: %w", config.Label(), err)
+		}
+	}
+
+	sort.SliceStable(configs, func(i, j int) bool {
+		return configs[i].Priority > configs[j].Priority
+	})
+	return configs, nil
+}
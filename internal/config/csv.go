@@ -0,0 +1,103 @@
+package config
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// loadCSV reads the legacy settings.csv format. Columns are positional:
+// Term, Subject, Mode, CRNs, SavedRegistrationTime and optionally DropCRNs
+// and Account.
+// Malformed rows are reported and skipped, as they always have been.
+func loadCSV(path string) ([]*TaskConfig, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	// Files written before DropCRNs or Account was added have fewer columns,
+	// and their rows may have been given one anyway.
+	reader.FieldsPerRecord = -1
+
+	// Read header
+	if _, err := reader.Read(); err != nil {
+		return nil, fmt.Errorf("%s: reading header: %w", path, err)
+	}
+
+	var configs []*TaskConfig
+	for line := 1; ; line++ {
+		row, err := reader.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			fmt.Printf("Error Reading Row: %v\n", err)
+			continue
+		}
+
+		config, err := parseCSVRow(row)
+		if err != nil {
+			fmt.Printf("Error parsing row: %v\n", err)
+			continue
+		}
+		config.SettingsFile = path
+		config.SettingsRow = line
+		configs = append(configs, config)
+	}
+	return configs, nil
+}
+
+// parseCSVRow parses a CSV row into a TaskConfig
+func parseCSVRow(row []string) (*TaskConfig, error) {
+	if len(row) < 5 {
+		return nil, fmt.Errorf("invalid row: expected at least 5 columns, got %d", len(row))
+	}
+
+	config := &TaskConfig{
+		Term:             row[0],
+		Subject:          row[1],
+		Mode:             strings.TrimSpace(row[2]),
+		CRNs:             splitCRNs(row[3]),
+		DropCRNs:         []string{}, // Default empty
+		RegistrationTime: row[4],
+	}
+
+	// Check if there's a 6th column for DropCRNs
+	if len(row) >= 6 {
+		config.DropCRNs = splitCRNs(row[5])
+	}
+
+	// and a 7th for the credentials profile
+	if len(row) >= 7 {
+		config.Account = strings.TrimSpace(row[6])
+		if config.Account != "" && !accountPattern.MatchString(config.Account) {
+			return nil, fmt.Errorf("invalid account %q: should be letters, digits, - and _", config.Account)
+		}
+	}
+
+	// Set default mode to Watch if empty
+	if config.Mode == "" {
+		config.Mode = "Watch"
+	}
+
+	return config, nil
+}
+
+// splitCRNs splits a comma-separated CRN list, dropping quotes and empty
+// entries.
+func splitCRNs(value string) []string {
+	var crns []string
+	for _, crn := range strings.Split(strings.Trim(value, "\""), ",") {
+		crn = strings.TrimSpace(crn)
+		if crn != "" {
+			crns = append(crns, crn)
+		}
+	}
+	return crns
+}
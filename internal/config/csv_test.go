@@ -0,0 +1,57 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLoadCSVColumns(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		accounts []string
+	}{
+		{
+			name: "account column",
+			contents: "Term,Subject,Mode,CRNs,SavedRegistrationTime,DropCRNs,Account\n" +
+				"2026 Winter De Anza,MATH,Watch,\"38894,\",,,alex\n" +
+				"2026 Winter De Anza,MATH,Watch,\"41846,\",,,\n",
+			accounts: []string{"alex", ""},
+		},
+		{
+			name: "older header without account",
+			contents: "Term,Subject,Mode,CRNs,SavedRegistrationTime,DropCRNs\n" +
+				"2026 Winter De Anza,MATH,Watch,\"38894,\",,\n" +
+				"2026 Winter De Anza,MATH,Watch,\"41846,\",,,alex\n",
+			accounts: []string{"", "alex"},
+		},
+		{
+			name: "without drop CRNs",
+			contents: "Term,Subject,Mode,CRNs,SavedRegistrationTime\n" +
+				"2026 Winter De Anza,MATH,Watch,\"38894,\",\n",
+			accounts: []string{""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "settings.csv")
+			if err := os.WriteFile(path, []byte(tt.contents), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			configs, err := loadCSV(path)
+			if err != nil {
+				t.Fatalf("loadCSV() error = %v", err)
+			}
+			var accounts []string
+			for _, config := range configs {
+				accounts = append(accounts, config.Account)
+			}
+			if !slices.Equal(accounts, tt.accounts) {
+				t.Errorf("loadCSV() accounts = %q, want %q", accounts, tt.accounts)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"register-bot/internal/tasks"
+)
+
+// FileEndpoints is the endpoints block of a task file, and the -*-url flags:
+// the base URL of each service, for pointing the bot at a test or mirror
+// instance. Empty fields keep tasks.DefaultEndpoints.
+type FileEndpoints struct {
+	Registration string `yaml:"registration" json:"registration"`
+	SSO          string `yaml:"sso" json:"sso"`
+	EIS          string `yaml:"eis" json:"eis"`
+	DegreeWorks  string `yaml:"degreeworks" json:"degreeworks"`
+}
+
+// Endpoints validates every URL that is set and converts them. Each returned
+// error starts with the offending field name.
+func (f FileEndpoints) Endpoints() (tasks.Endpoints, []error) {
+	var errs []error
+	var endpoints tasks.Endpoints
+	for _, field := range []struct {
+		name  string
+		value string
+		into  *string
+	}{
+		{"registration", f.Registration, &endpoints.Registration},
+		{"sso", f.SSO, &endpoints.SSO},
+		{"eis", f.EIS, &endpoints.EIS},
+		{"degreeworks", f.DegreeWorks, &endpoints.DegreeWorks},
+	} {
+		value := strings.TrimRight(strings.TrimSpace(field.value), "/")
+		if value == "" {
+			continue
+		}
+		parsed, err := url.Parse(value)
+		if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" || parsed.RawQuery != "" {
+			errs = append(errs, fmt.Errorf("%s: %q is not a base URL like \"https://reg.oci.fhda.edu\"", field.name, field.value))
+			continue
+		}
+		*field.into = value
+	}
+	return endpoints, errs
+}
+
+// EnvEndpoints reads REGISTER_BOT_REGISTRATION_URL, REGISTER_BOT_SSO_URL,
+// REGISTER_BOT_EIS_URL and REGISTER_BOT_DEGREEWORKS_URL.
+func EnvEndpoints() (tasks.Endpoints, error) {
+	endpoints, errs := FileEndpoints{
+		Registration: os.Getenv("REGISTER_BOT_REGISTRATION_URL"),
+		SSO:          os.Getenv("REGISTER_BOT_SSO_URL"),
+		EIS:          os.Getenv("REGISTER_BOT_EIS_URL"),
+		DegreeWorks:  os.Getenv("REGISTER_BOT_DEGREEWORKS_URL"),
+	}.Endpoints()
+	for i, err := range errs {
+		field, rest, _ := strings.Cut(err.Error(), ":")
+		errs[i] = fmt.Errorf("REGISTER_BOT_%s_URL:%s", strings.ToUpper(field), rest)
+	}
+	return endpoints, errors.Join(errs...)
+}
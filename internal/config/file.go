@@ -0,0 +1,513 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"register-bot/internal/export"
+	"register-bot/internal/tasks"
+)
+
+// Modes lists every value accepted in a task's mode field.
+var Modes = []string{"Signup", "Release", "Watch", "Search", "Classes", "Catalog", "Transcript", "Status", "Validate", "Schedule", "Current", "Reconcile"}
+
+// ScheduleLayout is the Pacific-time layout used by schedule and
+// registration_time, matching SavedRegistrationTime in settings.csv.
+const ScheduleLayout = "01/02/2006 03:04 PM"
+
+// MinPollInterval keeps Watch tasks from hammering Banner.
+const MinPollInterval = time.Second
+
+// File is the shape of config/tasks.yaml and config/tasks.json.
+type File struct {
+	// Endpoints overrides the services every task in the file talks to.
+	Endpoints FileEndpoints `yaml:"endpoints" json:"endpoints"`
+	Tasks     []FileTask    `yaml:"tasks" json:"tasks"`
+}
+
+type FileTask struct {
+	Name             string   `yaml:"name" json:"name"`
+	Term             string   `yaml:"term" json:"term"`
+	Subject          string   `yaml:"subject" json:"subject"`
+	Mode             string   `yaml:"mode" json:"mode"`
+	CRNs             CRNList  `yaml:"crns" json:"crns"`
+	DropCRNs         CRNList  `yaml:"drop_crns" json:"drop_crns"`
+	RegistrationTime string   `yaml:"registration_time" json:"registration_time"`
+	PollInterval     string   `yaml:"poll_interval" json:"poll_interval"`
+	Notify           []string `yaml:"notify" json:"notify"`
+	Schedule         string   `yaml:"schedule" json:"schedule"`
+	Priority         int      `yaml:"priority" json:"priority"`
+	Output           string   `yaml:"output" json:"output"`
+	Format           string   `yaml:"format" json:"format"`
+	History          string   `yaml:"history" json:"history"`
+	OnConflict       string   `yaml:"on_conflict" json:"on_conflict"`
+	// Alternates maps a CRN in crns to other sections of the same course to
+	// try, in order, when it is full or rejected.
+	Alternates map[string]CRNList `yaml:"alternates" json:"alternates"`
+	// SafeSwap pairs each of drop_crns with the CRN at the same position in
+	// crns, and only drops it if that CRN is added.
+	SafeSwap bool `yaml:"safe_swap" json:"safe_swap"`
+	// DryRun rehearses a signup without submitting it.
+	DryRun bool `yaml:"dry_run" json:"dry_run"`
+	// Account names the credentials profile the task signs in with; empty
+	// is the default credentials.
+	Account string `yaml:"account" json:"account"`
+	// Intervals overrides poll_interval for some CRNs, Jitter spreads each
+	// poll by up to this fraction of its interval (0 for a fixed cadence)
+	// and MaxDuration stops watching after this long.
+	Intervals   map[string]string `yaml:"intervals" json:"intervals"`
+	Jitter      *float64          `yaml:"jitter" json:"jitter"`
+	MaxDuration string            `yaml:"max_duration" json:"max_duration"`
+
+	// Schedule
+	Courses []string        `yaml:"courses" json:"courses"`
+	Prefer  FilePreferences `yaml:"prefer" json:"prefer"`
+	Limit   int             `yaml:"limit" json:"limit"`
+
+	// Search filters
+	CourseNumber string `yaml:"course_number" json:"course_number"`
+	Instructor   string `yaml:"instructor" json:"instructor"`
+	OpenOnly     bool   `yaml:"open_only" json:"open_only"`
+	Campus       string `yaml:"campus" json:"campus"`
+	Method       string `yaml:"method" json:"method"`
+	Days         string `yaml:"days" json:"days"`
+	StartsAfter  string `yaml:"starts_after" json:"starts_after"`
+	EndsBefore   string `yaml:"ends_before" json:"ends_before"`
+}
+
+// FilePreferences are the preferences a Schedule task ranks schedules by.
+type FilePreferences struct {
+	StartsAfter string   `yaml:"starts_after" json:"starts_after"`
+	EndsBefore  string   `yaml:"ends_before" json:"ends_before"`
+	DaysOff     string   `yaml:"days_off" json:"days_off"`
+	Campus      string   `yaml:"campus" json:"campus"`
+	Method      string   `yaml:"method" json:"method"`
+	Instructors []string `yaml:"instructors" json:"instructors"`
+	OpenSeats   bool     `yaml:"open_seats" json:"open_seats"`
+}
+
+// CRNList accepts CRNs written as strings, bare numbers, or a single
+// comma-separated string.
+type CRNList []string
+
+func (l *CRNList) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		*l = splitCRNs(node.Value)
+		return nil
+	case yaml.SequenceNode:
+		var crns []string
+		for _, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				return fmt.Errorf("line %d: CRN must be a number or string", item.Line)
+			}
+			crns = append(crns, strings.TrimSpace(item.Value))
+		}
+		*l = crns
+		return nil
+	}
+	return fmt.Errorf("line %d: expected a list of CRNs", node.Line)
+}
+
+func (l *CRNList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*l = splitCRNs(single)
+		return nil
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return fmt.Errorf("expected a list of CRNs")
+	}
+	var crns []string
+	for _, item := range items {
+		value := strings.Trim(strings.TrimSpace(string(item)), "\"")
+		crns = append(crns, value)
+	}
+	*l = crns
+	return nil
+}
+
+func loadYAML(path string) ([]*TaskConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	var file File
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return file.TaskConfigs(path)
+}
+
+func loadJSON(path string) ([]*TaskConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var file File
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return file.TaskConfigs(path)
+}
+
+// TaskConfigs validates every task and converts it. All problems are
+// reported together, each prefixed with the file and task it belongs to.
+func (f File) TaskConfigs(source string) ([]*TaskConfig, error) {
+	if len(f.Tasks) == 0 {
+		return nil, fmt.Errorf("%s: tasks: at least one task is required", source)
+	}
+
+	var configs []*TaskConfig
+	endpoints, errs := f.Endpoints.Endpoints()
+	for i, err := range errs {
+		errs[i] = fmt.Errorf("%s: endpoints.%w", source, err)
+	}
+	for i, task := range f.Tasks {
+		config, taskErrs := task.TaskConfig()
+		prefix := fmt.Sprintf("%s: tasks[%d]", source, i)
+		if task.Name != "" {
+			prefix = fmt.Sprintf("%s (%s)", prefix, task.Name)
+		}
+		for _, err := range taskErrs {
+			errs = append(errs, fmt.Errorf("%s.%w", prefix, err))
+		}
+		if len(taskErrs) == 0 {
+			config.Endpoints = endpoints
+			configs = append(configs, config)
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return configs, nil
+}
+
+var (
+	crnPattern    = regexp.MustCompile(`^\d{5}$`)
+	coursePattern = regexp.MustCompile(`^\S.* \S+$`)
+	termPattern   = regexp.MustCompile(`^\d{4} (Summer|Fall|Winter|Spring) (Foothill|De Anza)$`)
+	// accountPattern keeps profile names usable in environment variable
+	// and file names.
+	accountPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+)
+
+// TaskConfig validates the task against the schema and converts it. Each
+// returned error starts with the offending field name.
+func (t FileTask) TaskConfig() (*TaskConfig, []error) {
+	var errs []error
+	fail := func(field string, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+	}
+
+	config := &TaskConfig{
+		Name:             t.Name,
+		Term:             strings.TrimSpace(t.Term),
+		Subject:          strings.ToUpper(strings.TrimSpace(t.Subject)),
+		Mode:             canonicalMode(t.Mode),
+		CRNs:             []string(t.CRNs),
+		DropCRNs:         []string(t.DropCRNs),
+		RegistrationTime: t.RegistrationTime,
+		Notify:           t.Notify,
+		Priority:         t.Priority,
+		Output:           strings.TrimSpace(t.Output),
+		Format:           strings.ToLower(strings.TrimSpace(t.Format)),
+		History:          strings.TrimSpace(t.History),
+		OnConflict:       strings.ToLower(strings.TrimSpace(t.OnConflict)),
+		Limit:            t.Limit,
+		DryRun:           t.DryRun,
+		Account:          strings.TrimSpace(t.Account),
+		Preferences: tasks.SchedulePreferences{
+			DaysOff:     strings.ToUpper(strings.TrimSpace(t.Prefer.DaysOff)),
+			Campus:      strings.TrimSpace(t.Prefer.Campus),
+			Method:      strings.TrimSpace(t.Prefer.Method),
+			Instructors: t.Prefer.Instructors,
+			OpenSeats:   t.Prefer.OpenSeats,
+		},
+		Filters: tasks.SearchFilters{
+			CourseNumber: strings.ToUpper(strings.TrimSpace(t.CourseNumber)),
+			Instructor:   strings.TrimSpace(t.Instructor),
+			OpenOnly:     t.OpenOnly,
+			Campus:       strings.TrimSpace(t.Campus),
+			Method:       strings.TrimSpace(t.Method),
+			Days:         strings.ToUpper(strings.TrimSpace(t.Days)),
+		},
+	}
+
+	if config.Term == "" {
+		if config.Mode != "Transcript" {
+			fail("term", "is required")
+		}
+	} else if !termPattern.MatchString(config.Term) {
+		fail("term", "%q should look like \"2026 Winter De Anza\" or \"2026 Fall Foothill\"", config.Term)
+	}
+
+	if config.Account != "" && !accountPattern.MatchString(config.Account) {
+		fail("account", "%q should be letters, digits, - and _", config.Account)
+	}
+
+	if t.Mode == "" {
+		fail("mode", "is required (one of %s)", strings.Join(Modes, ", "))
+	} else if config.Mode == "" {
+		fail("mode", "unknown mode %q (want one of %s)", t.Mode, strings.Join(Modes, ", "))
+	}
+
+	switch config.Mode {
+	case "Signup", "Release", "Watch", "Validate", "Reconcile":
+		if len(config.CRNs) == 0 {
+			fail("crns", "at least one CRN is required for %s", config.Mode)
+		}
+	case "Search", "Classes":
+		if config.Subject == "" {
+			fail("subject", "is required for %s", config.Mode)
+		}
+	case "Schedule":
+		if len(t.Courses) == 0 {
+			fail("courses", "at least one course is required for %s", config.Mode)
+		}
+	}
+
+	for i, course := range t.Courses {
+		course = strings.ToUpper(strings.Join(strings.Fields(course), " "))
+		if !coursePattern.MatchString(course) {
+			fail(fmt.Sprintf("courses[%d]", i), "%q should be a subject and course number, e.g. \"MATH 1C\"", t.Courses[i])
+			continue
+		}
+		config.Courses = append(config.Courses, course)
+	}
+	if strings.Trim(config.Preferences.DaysOff, "UMTWRFS") != "" {
+		fail("prefer.days_off", "%q should only use the letters UMTWRFS, e.g. \"F\"", t.Prefer.DaysOff)
+	}
+	if config.Limit < 0 {
+		fail("limit", "must not be negative")
+	}
+
+	if strings.Trim(config.Filters.Days, "UMTWRFS") != "" {
+		fail("days", "%q should only use the letters UMTWRFS, e.g. \"MW\"", t.Days)
+	}
+	for _, bound := range []struct {
+		field string
+		value string
+		into  *string
+	}{
+		{"starts_after", t.StartsAfter, &config.Filters.StartsAfter},
+		{"ends_before", t.EndsBefore, &config.Filters.EndsBefore},
+		{"prefer.starts_after", t.Prefer.StartsAfter, &config.Preferences.StartsAfter},
+		{"prefer.ends_before", t.Prefer.EndsBefore, &config.Preferences.EndsBefore},
+	} {
+		if bound.value == "" {
+			continue
+		}
+		clock, err := parseClock(bound.value)
+		if err != nil {
+			fail(bound.field, "%q is not a time like \"09:30\" or \"1:30 PM\"", bound.value)
+			continue
+		}
+		*bound.into = clock
+	}
+
+	for i, crn := range config.CRNs {
+		if !crnPattern.MatchString(crn) {
+			fail(fmt.Sprintf("crns[%d]", i), "%q is not a 5-digit CRN", crn)
+		}
+	}
+	for i, crn := range config.DropCRNs {
+		if !crnPattern.MatchString(crn) {
+			fail(fmt.Sprintf("drop_crns[%d]", i), "%q is not a 5-digit CRN", crn)
+		}
+	}
+	if t.DryRun && config.Mode != "Signup" && config.Mode != "Release" && config.Mode != "Reconcile" {
+		fail("dry_run", "is only used by Signup, Release and Reconcile")
+	}
+	if t.SafeSwap {
+		switch {
+		case config.Mode != "Signup" && config.Mode != "Release" && config.Mode != "Watch":
+			fail("safe_swap", "is only used by Signup, Release and Watch")
+		case len(config.DropCRNs) == 0:
+			fail("safe_swap", "needs drop_crns to pair with crns")
+		case len(config.DropCRNs) > len(config.CRNs):
+			fail("drop_crns", "safe_swap pairs each with a CRN, but there are %d drop_crns and %d crns", len(config.DropCRNs), len(config.CRNs))
+		default:
+			config.Swaps = make(map[string]string)
+			for i, drop := range config.DropCRNs {
+				config.Swaps[config.CRNs[i]] = drop
+			}
+		}
+	}
+	if len(t.Alternates) > 0 && config.Mode != "Signup" && config.Mode != "Release" {
+		fail("alternates", "are only used by Signup and Release")
+	}
+	preferred := make([]string, 0, len(t.Alternates))
+	for crn := range t.Alternates {
+		preferred = append(preferred, crn)
+	}
+	sort.Strings(preferred)
+	for _, crn := range preferred {
+		if !slices.Contains(config.CRNs, crn) {
+			fail(fmt.Sprintf("alternates[%s]", crn), "%q is not one of crns", crn)
+			continue
+		}
+		for i, alternate := range t.Alternates[crn] {
+			if !crnPattern.MatchString(alternate) {
+				fail(fmt.Sprintf("alternates[%s][%d]", crn, i), "%q is not a 5-digit CRN", alternate)
+			}
+		}
+		if config.Alternates == nil {
+			config.Alternates = make(map[string][]string)
+		}
+		config.Alternates[crn] = []string(t.Alternates[crn])
+	}
+
+	if t.PollInterval != "" {
+		interval, err := parseInterval(t.PollInterval)
+		if err != nil {
+			fail("poll_interval", "%q is not a duration like \"10s\" or \"1m\"", t.PollInterval)
+		} else if interval < MinPollInterval {
+			fail("poll_interval", "%s is shorter than the %s minimum", interval, MinPollInterval)
+		} else {
+			config.WatchOptions.Interval = interval
+		}
+	}
+	if (len(t.Intervals) > 0 || t.Jitter != nil || t.MaxDuration != "") && config.Mode != "Watch" {
+		fail("intervals", "intervals, jitter and max_duration are only used by Watch")
+	}
+	watched := make([]string, 0, len(t.Intervals))
+	for crn := range t.Intervals {
+		watched = append(watched, crn)
+	}
+	sort.Strings(watched)
+	for _, crn := range watched {
+		field := fmt.Sprintf("intervals[%s]", crn)
+		interval, err := parseInterval(t.Intervals[crn])
+		switch {
+		case !slices.Contains(config.CRNs, crn):
+			fail(field, "%q is not one of crns", crn)
+		case err != nil:
+			fail(field, "%q is not a duration like \"10s\" or \"1m\"", t.Intervals[crn])
+		case interval < MinPollInterval:
+			fail(field, "%s is shorter than the %s minimum", interval, MinPollInterval)
+		default:
+			if config.WatchOptions.Intervals == nil {
+				config.WatchOptions.Intervals = make(map[string]time.Duration)
+			}
+			config.WatchOptions.Intervals[crn] = interval
+		}
+	}
+	if t.Jitter != nil {
+		switch {
+		case *t.Jitter < 0 || *t.Jitter >= 1:
+			fail("jitter", "%g should be a fraction of the interval from 0 up to 1, e.g. 0.1", *t.Jitter)
+		case *t.Jitter == 0:
+			config.WatchOptions.Jitter = -1
+		default:
+			config.WatchOptions.Jitter = *t.Jitter
+		}
+	}
+	if t.MaxDuration != "" {
+		duration, err := parseInterval(t.MaxDuration)
+		if err != nil || duration <= 0 {
+			fail("max_duration", "%q is not a duration like \"30m\" or \"2h\"", t.MaxDuration)
+		} else {
+			config.WatchOptions.MaxDuration = duration
+		}
+	}
+
+	for i, target := range t.Notify {
+		parsed, err := url.Parse(target)
+		if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+			fail(fmt.Sprintf("notify[%d]", i), "%q is not an http(s) webhook URL", target)
+		}
+	}
+
+	if t.Schedule != "" {
+		schedule, err := parseSchedule(t.Schedule)
+		if err != nil {
+			fail("schedule", "%q should be %q (Pacific time) or RFC 3339", t.Schedule, ScheduleLayout)
+		} else {
+			config.Schedule = schedule
+		}
+	}
+
+	if t.RegistrationTime != "" {
+		// Only the layout Release reads, unlike schedule
+		if _, err := time.Parse(ScheduleLayout, t.RegistrationTime); err != nil {
+			fail("registration_time", "%q should be %q (Pacific time)", t.RegistrationTime, ScheduleLayout)
+		}
+	} else if config.Mode == "Release" {
+		fail("registration_time", "is required for Release")
+	}
+
+	if config.Format != "" {
+		if _, err := export.Lookup(config.Format); err != nil {
+			fail("format", "%q is not an export format (want one of %s)", t.Format, strings.Join(export.Formats(), ", "))
+		}
+	}
+
+	switch config.OnConflict {
+	case "", "refuse", "warn":
+	default:
+		fail("on_conflict", "%q should be \"refuse\" or \"warn\"", t.OnConflict)
+	}
+
+	if config.Priority < 0 {
+		fail("priority", "must not be negative")
+	}
+
+	return config, errs
+}
+
+func canonicalMode(mode string) string {
+	for _, known := range Modes {
+		if strings.EqualFold(strings.TrimSpace(mode), known) {
+			return known
+		}
+	}
+	return ""
+}
+
+// parseClock converts a time of day to Banner's "HHMM" form.
+func parseClock(value string) (string, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	for _, layout := range []string{"15:04", "1504", "3:04 PM", "3:04PM", "3 PM", "3PM"} {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed.Format("1504"), nil
+		}
+	}
+	return "", fmt.Errorf("invalid time of day %q", value)
+}
+
+// parseInterval accepts Go durations and bare seconds.
+func parseInterval(value string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return time.ParseDuration(value)
+}
+
+func parseSchedule(value string) (time.Time, error) {
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed, nil
+	}
+	location, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.ParseInLocation(ScheduleLayout, value, location)
+}
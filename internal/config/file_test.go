@@ -0,0 +1,34 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRegistrationTimeLayout(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{name: "schedule layout", value: "01/20/2026 08:00 AM"},
+		{name: "RFC 3339", value: "2026-01-20T08:00:00-08:00", wantErr: true},
+		{name: "24-hour clock", value: "01/20/2026 08:00", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			task := FileTask{Mode: "Release", Term: "2026 Winter De Anza", CRNs: CRNList{"41846"}, RegistrationTime: tt.value}
+			_, errs := task.TaskConfig()
+			failed := false
+			for _, err := range errs {
+				if strings.Contains(err.Error(), "registration_time") {
+					failed = true
+				}
+			}
+			if failed != tt.wantErr {
+				t.Errorf("TaskConfig() errors = %v, want a registration_time error: %v", errs, tt.wantErr)
+			}
+		})
+	}
+}
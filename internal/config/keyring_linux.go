@@ -0,0 +1,78 @@
+//go:build linux
+
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Keyring keeps logins in the Secret Service (GNOME Keyring, KWallet) over
+// D-Bus, through libsecret's secret-tool. Each field is its own secret,
+// found by the attributes service=register-bot, profile and field.
+type Keyring struct {
+	tool string
+}
+
+// OpenKeyring finds secret-tool, which comes with libsecret-tools.
+func OpenKeyring() (*Keyring, error) {
+	tool, err := exec.LookPath("secret-tool")
+	if err != nil {
+		return nil, fmt.Errorf("keyring store needs secret-tool (install libsecret-tools): %w", err)
+	}
+	return &Keyring{tool: tool}, nil
+}
+
+func keyringAttributes(profile string, field string) []string {
+	if profile == "" {
+		profile = "default"
+	}
+	return []string{"service", "register-bot", "profile", strings.ToLower(profile), "field", field}
+}
+
+func (k *Keyring) Lookup(profile string) (Login, error) {
+	var login Login
+	for field, value := range map[string]*string{"username": &login.Username, "password": &login.Password, "webhook": &login.Webhook} {
+		var stdout, stderr bytes.Buffer
+		cmd := exec.Command(k.tool, append([]string{"lookup"}, keyringAttributes(profile, field)...)...)
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+		err := cmd.Run()
+		var exit *exec.ExitError
+		if errors.As(err, &exit) && stderr.Len() == 0 {
+			// Nothing stored for this field
+			continue
+		} else if err != nil {
+			return Login{}, fmt.Errorf("keyring: %w: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		*value = strings.TrimRight(stdout.String(), "\n")
+	}
+	return login, nil
+}
+
+func (k *Keyring) String() string {
+	return "keyring"
+}
+
+// Save stores each non-empty field of the login as its own secret.
+func (k *Keyring) Save(profile string, login Login) error {
+	for _, field := range []struct{ name, value string }{{"username", login.Username}, {"password", login.Password}, {"webhook", login.Webhook}} {
+		if field.value == "" {
+			continue
+		}
+		label := fmt.Sprintf("register-bot %s", field.name)
+		if profile != "" {
+			label = fmt.Sprintf("register-bot [%s] %s", profile, field.name)
+		}
+		var stderr bytes.Buffer
+		cmd := exec.Command(k.tool, append([]string{"store", "--label=" + label}, keyringAttributes(profile, field.name)...)...)
+		cmd.Stdin = strings.NewReader(field.value)
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("keyring: %w: %s", err, strings.TrimSpace(stderr.String()))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+//go:build linux
+
+package config
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestOpenKeyringWithoutSecretTool(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+
+	for name, open := range map[string]func() error{
+		"OpenKeyring": func() error {
+			_, err := OpenKeyring()
+			return err
+		},
+		"OpenStore": func() error {
+			_, err := OpenStore("keyring", "config/.credentials", nil)
+			return err
+		},
+	} {
+		err := open()
+		if err == nil {
+			t.Fatalf("%s found a secret-tool that isn't on PATH", name)
+		}
+		if !strings.Contains(err.Error(), "libsecret-tools") {
+			t.Errorf("%s = %v, want it to say what to install", name, err)
+		}
+		if !errors.Is(err, exec.ErrNotFound) {
+			t.Errorf("%s = %v, want it to wrap the lookup error", name, err)
+		}
+	}
+}
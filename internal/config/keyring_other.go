@@ -0,0 +1,26 @@
+//go:build !linux
+
+package config
+
+import "errors"
+
+// Keyring is only available on Linux, where it keeps logins in the Secret
+// Service.
+type Keyring struct{}
+
+// OpenKeyring always fails outside Linux.
+func OpenKeyring() (*Keyring, error) {
+	return nil, errors.New("keyring store is only supported on Linux")
+}
+
+func (k *Keyring) Lookup(profile string) (Login, error) {
+	return Login{}, nil
+}
+
+func (k *Keyring) String() string {
+	return "keyring"
+}
+
+func (k *Keyring) Save(profile string, login Login) error {
+	return errors.New("keyring store is only supported on Linux")
+}
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Stores lists the values accepted for REGISTER_BOT_CREDENTIAL_STORE.
+var Stores = []string{"file", "vault", "keyring"}
+
+// Login is one profile's username, password and webhook.
+type Login struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Webhook  string `json:"webhook,omitempty"`
+}
+
+// fill sets every empty field of l from other.
+func (l *Login) fill(other Login) {
+	if l.Username == "" {
+		l.Username = other.Username
+	}
+	if l.Password == "" {
+		l.Password = other.Password
+	}
+	if l.Webhook == "" {
+		l.Webhook = other.Webhook
+	}
+}
+
+// A CredentialProvider is somewhere logins are kept: the environment, the
+// .credentials file, an encrypted vault or the system keyring. Its String
+// names it in error messages.
+type CredentialProvider interface {
+	// Lookup returns what the provider holds for a profile, "" being the
+	// default credentials, leaving the fields it does not have empty.
+	Lookup(profile string) (Login, error)
+	String() string
+}
+
+// A CredentialStore is a CredentialProvider logins can be saved to.
+type CredentialStore interface {
+	CredentialProvider
+	Save(profile string, login Login) error
+}
+
+// EnvProvider reads REGISTER_BOT_USERNAME, _PASSWORD and _WEBHOOK for the
+// default credentials and REGISTER_BOT_<NAME>_USERNAME and so on for a
+// profile.
+type EnvProvider struct{}
+
+func (EnvProvider) Lookup(profile string) (Login, error) {
+	credentials := Credentials{Name: profile}
+	return Login{
+		Username: credentials.env("USERNAME"),
+		Password: credentials.env("PASSWORD"),
+		Webhook:  credentials.env("WEBHOOK"),
+	}, nil
+}
+
+func (EnvProvider) String() string {
+	return "environment variables"
+}
+
+// Lookup makes the plaintext credentials file a CredentialProvider.
+func (credentials Credentials) Lookup(profile string) (Login, error) {
+	found := credentials.profile(profile)
+	return Login{Username: found.Username, Password: found.Password, Webhook: found.Webhook}, nil
+}
+
+func (credentials Credentials) String() string {
+	return ".credentials file"
+}
+
+// VaultPath is where the vault store is kept: next to the credentials file.
+func VaultPath(credentialsPath string) string {
+	return filepath.Join(filepath.Dir(credentialsPath), ".credentials.vault")
+}
+
+// StoreName returns the store named by REGISTER_BOT_CREDENTIAL_STORE, or
+// "file" when it is unset.
+func StoreName() string {
+	if name := strings.ToLower(strings.TrimSpace(os.Getenv("REGISTER_BOT_CREDENTIAL_STORE"))); name != "" {
+		return name
+	}
+	return "file"
+}
+
+// OpenStore opens the named credential store. "file" has no store of its
+// own and yields nil. The vault asks passphrase for its passphrase, with
+// create set when the vault does not exist yet.
+func OpenStore(name string, credentialsPath string, passphrase func(create bool) (string, error)) (CredentialStore, error) {
+	switch name {
+	case "file":
+		return nil, nil
+	case "vault":
+		path := VaultPath(credentialsPath)
+		_, err := os.Stat(path)
+		secret, err := passphrase(os.IsNotExist(err))
+		if err != nil {
+			return nil, err
+		}
+		vault, err := OpenVault(path, secret)
+		if err != nil {
+			return nil, err
+		}
+		return vault, nil
+	case "keyring":
+		keyring, err := OpenKeyring()
+		if err != nil {
+			return nil, err
+		}
+		return keyring, nil
+	}
+	return nil, fmt.Errorf("unknown credential store %q (want one of %s)", name, strings.Join(Stores, ", "))
+}
@@ -0,0 +1,140 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// ErrWrongPassphrase means the vault could not be opened with the
+// passphrase given.
+var ErrWrongPassphrase = errors.New("wrong vault passphrase")
+
+const vaultVersion = 1
+
+// vaultFile is a vault as written to disk. Data is the sealed map of
+// logins, keyed by lowercase profile name.
+type vaultFile struct {
+	Version int    `json:"version"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+// Vault is a file of logins encrypted with a key derived from a passphrase
+// by scrypt. It is read whole when opened and written whole on Save.
+type Vault struct {
+	path       string
+	passphrase string
+	logins     map[string]Login
+}
+
+// OpenVault unlocks the vault at path. A missing file is an empty vault,
+// created on the first Save.
+func OpenVault(path string, passphrase string) (*Vault, error) {
+	vault := &Vault{path: path, passphrase: passphrase, logins: make(map[string]Login)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return vault, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var file vaultFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if file.Version != vaultVersion {
+		return nil, fmt.Errorf("%s: unsupported vault version %d", path, file.Version)
+	}
+	aead, err := vaultCipher(passphrase, file.Salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(file.Nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("%s: bad nonce", path)
+	}
+	plain, err := aead.Open(nil, file.Nonce, file.Data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, ErrWrongPassphrase)
+	}
+	if err := json.Unmarshal(plain, &vault.logins); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return vault, nil
+}
+
+func vaultCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (v *Vault) Lookup(profile string) (Login, error) {
+	return v.logins[strings.ToLower(profile)], nil
+}
+
+func (v *Vault) String() string {
+	return "vault " + v.path
+}
+
+// Save stores the profile's login and writes the vault, sealed with a fresh
+// salt and nonce.
+func (v *Vault) Save(profile string, login Login) error {
+	v.logins[strings.ToLower(profile)] = login
+	plain, err := json.Marshal(v.logins)
+	if err != nil {
+		return err
+	}
+
+	file := vaultFile{Version: vaultVersion, Salt: make([]byte, 16)}
+	if _, err := rand.Read(file.Salt); err != nil {
+		return err
+	}
+	aead, err := vaultCipher(v.passphrase, file.Salt)
+	if err != nil {
+		return err
+	}
+	file.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(file.Nonce); err != nil {
+		return err
+	}
+	file.Data = aead.Seal(nil, file.Nonce, plain, nil)
+	data, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so a crash never leaves half a vault.
+	if err := os.MkdirAll(filepath.Dir(v.path), 0o700); err != nil {
+		return err
+	}
+	temp, err := os.CreateTemp(filepath.Dir(v.path), filepath.Base(v.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), v.path)
+}
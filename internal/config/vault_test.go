@@ -0,0 +1,101 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVaultRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".credentials.vault")
+	vault, err := OpenVault(path, "correct horse")
+	if err != nil {
+		t.Fatalf("OpenVault on a missing file: %v", err)
+	}
+	logins := map[string]Login{
+		"":      {Username: "student", Password: "hunter2", Webhook: "https://example.com/hook"},
+		"Alice": {Username: "alice", Password: "s3cret"},
+	}
+	for profile, login := range logins {
+		if err := vault.Save(profile, login); err != nil {
+			t.Fatalf("Save(%q): %v", profile, err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"hunter2", "s3cret", "student"} {
+		if bytes.Contains(data, []byte(secret)) {
+			t.Errorf("vault file holds %q in the clear", secret)
+		}
+	}
+
+	reopened, err := OpenVault(path, "correct horse")
+	if err != nil {
+		t.Fatalf("reopening vault: %v", err)
+	}
+	tests := []struct {
+		profile string
+		want    Login
+	}{
+		{"", logins[""]},
+		{"Alice", logins["Alice"]},
+		{"alice", logins["Alice"]},
+		{"ALICE", logins["Alice"]},
+		{"bob", Login{}},
+	}
+	for _, test := range tests {
+		got, err := reopened.Lookup(test.profile)
+		if err != nil {
+			t.Fatalf("Lookup(%q): %v", test.profile, err)
+		}
+		if got != test.want {
+			t.Errorf("Lookup(%q) = %+v, want %+v", test.profile, got, test.want)
+		}
+	}
+}
+
+func TestVaultWrongPassphrase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".credentials.vault")
+	vault, err := OpenVault(path, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vault.Save("", Login{Username: "student", Password: "hunter2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, passphrase := range []string{"battery staple", "", "Correct horse"} {
+		_, err := OpenVault(path, passphrase)
+		if !errors.Is(err, ErrWrongPassphrase) {
+			t.Errorf("OpenVault(%q) = %v, want ErrWrongPassphrase", passphrase, err)
+		}
+	}
+}
+
+func TestOpenVaultRejectsOtherFiles(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"not json", "username=student"},
+		{"other version", `{"version":99}`},
+		{"bad nonce", `{"version":1,"salt":"AAAAAAAAAAAAAAAAAAAAAA==","nonce":"AA==","data":""}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), ".credentials.vault")
+			if err := os.WriteFile(path, []byte(test.data), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := OpenVault(path, "correct horse")
+			if err == nil || errors.Is(err, ErrWrongPassphrase) {
+				t.Errorf("OpenVault = %v, want an error about the file", err)
+			}
+		})
+	}
+}
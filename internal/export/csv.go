@@ -0,0 +1,45 @@
+package export
+
+import (
+	"encoding/csv"
+	"os"
+	"time"
+)
+
+// CSV writes a header row of column names followed by one row per record.
+type CSV struct{}
+
+func (CSV) FileName(base string, at time.Time) string {
+	return timestamped(base, at, "csv")
+}
+
+func (CSV) Export(path string, dataset Dataset) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	header := make([]string, len(dataset.Columns))
+	for i, column := range dataset.Columns {
+		header[i] = column.Name
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+	for _, row := range dataset.Rows {
+		record := make([]string, len(row))
+		for i, value := range row {
+			record[i] = text(value)
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,89 @@
+// Package export writes tabular task results, such as search results and
+// transcripts, as CSV, JSON, JSON Lines or rows in a SQLite database.
+package export
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Column is one field of a Dataset.
+type Column struct {
+	// Name is the human-readable CSV header, e.g. "Course Reference Number".
+	Name string
+	// Key names the field in JSON and the column in SQLite, e.g. "crn".
+	Key string
+}
+
+// Dataset is a named table of rows. Each row holds one value per column;
+// values are strings, ints, float64s or bools.
+type Dataset struct {
+	// Name is the SQLite table name, e.g. "courses".
+	Name    string
+	Columns []Column
+	Rows    [][]any
+}
+
+// Exporter writes a dataset to path.
+type Exporter interface {
+	// FileName is the default file to write when no output file is given.
+	FileName(base string, at time.Time) string
+	Export(path string, dataset Dataset) error
+}
+
+var exporters = map[string]Exporter{
+	"csv":    CSV{},
+	"json":   JSON{},
+	"ndjson": NDJSON{},
+	"sqlite": SQLite{},
+}
+
+var extensions = map[string]string{
+	".csv":     "csv",
+	".json":    "json",
+	".ndjson":  "ndjson",
+	".jsonl":   "ndjson",
+	".db":      "sqlite",
+	".sqlite":  "sqlite",
+	".sqlite3": "sqlite",
+}
+
+// Formats lists the names accepted by Lookup.
+func Formats() []string {
+	var formats []string
+	for format := range exporters {
+		formats = append(formats, format)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// Lookup returns the exporter for a format name, ignoring case.
+func Lookup(format string) (Exporter, error) {
+	exporter, ok := exporters[strings.ToLower(strings.TrimSpace(format))]
+	if !ok {
+		return nil, fmt.Errorf("unknown export format %q (want one of %s)", format, strings.Join(Formats(), ", "))
+	}
+	return exporter, nil
+}
+
+// FormatOf guesses the format from a file extension, returning "" when the
+// extension is not one of ours.
+func FormatOf(path string) string {
+	return extensions[strings.ToLower(filepath.Ext(path))]
+}
+
+func timestamped(base string, at time.Time, extension string) string {
+	return fmt.Sprintf("%s%s.%s", base, at.Format("2006-01-02_15-04-05"), extension)
+}
+
+// text renders a value for CSV.
+func text(value any) string {
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
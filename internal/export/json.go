@@ -0,0 +1,83 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// JSON writes one array of objects keyed by column key, in column order.
+type JSON struct{}
+
+func (JSON) FileName(base string, at time.Time) string {
+	return timestamped(base, at, "json")
+}
+
+func (JSON) Export(path string, dataset Dataset) error {
+	return writeObjects(path, dataset, []byte("[\n  "), []byte(",\n  "), []byte("\n]\n"))
+}
+
+// NDJSON writes one object per line, which streams and appends well.
+type NDJSON struct{}
+
+func (NDJSON) FileName(base string, at time.Time) string {
+	return timestamped(base, at, "ndjson")
+}
+
+func (NDJSON) Export(path string, dataset Dataset) error {
+	return writeObjects(path, dataset, nil, []byte("\n"), []byte("\n"))
+}
+
+func writeObjects(path string, dataset Dataset, start, separator, end []byte) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	if len(dataset.Rows) == 0 && start != nil {
+		writer.WriteString("[]\n")
+	} else if len(dataset.Rows) > 0 {
+		writer.Write(start)
+		for i, row := range dataset.Rows {
+			if i > 0 {
+				writer.Write(separator)
+			}
+			encoded, err := object(dataset.Columns, row)
+			if err != nil {
+				return err
+			}
+			writer.Write(encoded)
+		}
+		writer.Write(end)
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// object encodes a row as a JSON object, keeping the column order that
+// encoding/json would lose with a map.
+func object(columns []Column, row []any) ([]byte, error) {
+	var buffer bytes.Buffer
+	buffer.WriteByte('{')
+	for i, column := range columns {
+		if i > 0 {
+			buffer.WriteByte(',')
+		}
+		key, _ := json.Marshal(column.Key)
+		value, err := json.Marshal(row[i])
+		if err != nil {
+			return nil, err
+		}
+		buffer.Write(key)
+		buffer.WriteByte(':')
+		buffer.Write(value)
+	}
+	buffer.WriteByte('}')
+	return buffer.Bytes(), nil
+}
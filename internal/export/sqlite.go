@@ -0,0 +1,122 @@
+package export
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLite appends rows to a table named after the dataset, creating the
+// database and table as needed and adding columns a table written by an
+// older version lacks. Every row also gets an exported_at column, so
+// repeated exports to one database build up a history of snapshots.
+type SQLite struct{}
+
+// FileName is the same for every export so snapshots accumulate in one
+// database. It is kept apart from the seat history database.
+func (SQLite) FileName(base string, at time.Time) string {
+	return "register-bot-exports.db"
+}
+
+func (SQLite) Export(path string, dataset Dataset) error {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	columns := []string{quote("exported_at") + " TEXT NOT NULL"}
+	names := []string{quote("exported_at")}
+	placeholders := []string{"?"}
+	for i, column := range dataset.Columns {
+		columns = append(columns, fmt.Sprintf("%s %s", quote(column.Key), sqliteType(dataset.Rows, i)))
+		names = append(names, quote(column.Key))
+		placeholders = append(placeholders, "?")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s)", quote(dataset.Name), strings.Join(columns, ", "))); err != nil {
+		return fmt.Errorf("creating table %s: %w", dataset.Name, err)
+	}
+	if err := addMissingColumns(tx, dataset); err != nil {
+		return fmt.Errorf("updating table %s: %w", dataset.Name, err)
+	}
+	insert, err := tx.Prepare(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", quote(dataset.Name), strings.Join(names, ", "), strings.Join(placeholders, ", ")))
+	if err != nil {
+		return fmt.Errorf("preparing insert into %s: %w", dataset.Name, err)
+	}
+	defer insert.Close()
+
+	exportedAt := time.Now().UTC().Format(time.RFC3339)
+	for _, row := range dataset.Rows {
+		if _, err := insert.Exec(append([]any{exportedAt}, row...)...); err != nil {
+			return fmt.Errorf("inserting into %s: %w", dataset.Name, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// addMissingColumns adds the dataset's columns that an existing table does
+// not have yet, such as ones added to the dataset since it was created.
+// Earlier rows get NULL in them.
+func addMissingColumns(tx *sql.Tx, dataset Dataset) error {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", quote(dataset.Name)))
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			kind       string
+			notNull    int
+			defaultVal sql.NullString
+			primaryKey int
+		)
+		if err := rows.Scan(&cid, &name, &kind, &notNull, &defaultVal, &primaryKey); err != nil {
+			rows.Close()
+			return err
+		}
+		existing[name] = true
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+
+	for i, column := range dataset.Columns {
+		if existing[column.Key] {
+			continue
+		}
+		if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", quote(dataset.Name), quote(column.Key), sqliteType(dataset.Rows, i))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sqliteType picks a column type from the first row's value.
+func sqliteType(rows [][]any, column int) string {
+	if len(rows) == 0 {
+		return "TEXT"
+	}
+	switch rows[0][column].(type) {
+	case int, int64, bool:
+		return "INTEGER"
+	case float64:
+		return "REAL"
+	}
+	return "TEXT"
+}
+
+func quote(identifier string) string {
+	return `"` + strings.ReplaceAll(identifier, `"`, `""`) + `"`
+}
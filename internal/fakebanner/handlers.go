@@ -0,0 +1,856 @@
+package fakebanner
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	http "github.com/bogdanfinn/fhttp"
+)
+
+const (
+	regPrefix = "/StudentRegistrationSsb"
+	ssoPath   = "/idp/profile/SAML2/POST/SSO"
+	dwPrefix  = "/responsiveDashboard"
+)
+
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc(regPrefix+"/saml/login", s.handleSAMLRequestPage)
+	mux.HandleFunc(dwPrefix+"/worksheets/WEB31", s.handleSAMLRequestPage)
+	mux.HandleFunc(regPrefix+"/ssb/registration/registerPostSignIn", s.handleSAMLRequestPage)
+	mux.HandleFunc(ssoPath, s.handleSSO)
+	mux.HandleFunc(regPrefix+"/saml/SSO", s.handleAssertionConsumer)
+	mux.HandleFunc(regPrefix+"/saml/SSO/alias/registrationssb-prod-sp", s.handleAssertionConsumer)
+	mux.HandleFunc("/samlsso", s.handleEIS)
+	mux.HandleFunc(regPrefix+"/ssb/registration", s.handleRegistrationHome)
+	mux.HandleFunc(regPrefix+"/login/authAjax", s.handleAuthAjax)
+
+	mux.HandleFunc(regPrefix+"/ssb/classSearch/getTerms", s.handleGetTerms)
+	mux.HandleFunc(regPrefix+"/ssb/classSearch/get_subject", s.handleGetSubjects)
+	mux.HandleFunc(regPrefix+"/ssb/classSearch/resetDataForm", s.handleOK)
+	mux.HandleFunc(regPrefix+"/ssb/term/search", s.handleTermSearch)
+	mux.HandleFunc(regPrefix+"/ssb/searchResults/searchResults", s.handleSearchResults)
+	mux.HandleFunc(regPrefix+"/ssb/searchResults/getEnrollmentInfo", s.handleEnrollmentInfo)
+	mux.HandleFunc(regPrefix+"/ssb/searchResults/getFacultyMeetingTimes", s.handleFacultyMeetingTimes)
+	mux.HandleFunc(regPrefix+"/ssb/searchResults/fetchLinkedSections", s.handleLinkedSections)
+
+	mux.HandleFunc(regPrefix+"/ssb/classRegistration/classRegistration", s.requireSession(s.handleOK))
+	mux.HandleFunc(regPrefix+"/ssb/classRegistration/getSectionDetailsFromCRN", s.requireSession(s.handleSectionDetails))
+	mux.HandleFunc(regPrefix+"/ssb/classRegistration/addRegistrationItem", s.requireSession(s.handleAddRegistrationItem))
+	mux.HandleFunc(regPrefix+"/ssb/classRegistration/submitRegistration/batch", s.requireSession(s.handleBatch))
+	mux.HandleFunc(regPrefix+"/ssb/classRegistration/resetDataForm", s.requireSession(s.handleResetWorksheet))
+	mux.HandleFunc(regPrefix+"/ssb/registrationHistory/reset", s.requireSession(s.handleRegistrationHistory))
+
+	mux.HandleFunc(dwPrefix+"/api/students/myself", s.requireSession(s.handleStudent))
+	mux.HandleFunc(dwPrefix+"/api/audit", s.requireSession(s.handleAudit))
+
+	mux.HandleFunc("/webhook", s.handleWebhook)
+	return mux
+}
+
+func (s *Server) requireSession(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if !s.loggedIn(req) {
+			writeError(w, http.StatusUnauthorized, "User is not logged in")
+			return
+		}
+		next(w, req)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeHTML(w http.ResponseWriter, body string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	io.WriteString(w, "<!DOCTYPE html><html><head></head><body>"+body+"</body></html>")
+}
+
+// writeError mirrors Banner's error pages, which carry the reason in a meta tag.
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `<!DOCTYPE html><html><head><meta name="errorMessage" content="%s"></head><body></body></html>`, html.EscapeString(message))
+}
+
+func samlForm(action string, fields map[string]string) string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, `<form method="post" action="%s">`, html.EscapeString(action))
+	for name, value := range fields {
+		fmt.Fprintf(&builder, `<input type="hidden" name="%s" value="%s"/>`, name, html.EscapeString(value))
+	}
+	builder.WriteString(`</form>`)
+	return builder.String()
+}
+
+func (s *Server) handleOK(w http.ResponseWriter, req *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) handleSAMLRequestPage(w http.ResponseWriter, req *http.Request) {
+	writeHTML(w, samlForm(s.URL+ssoPath, map[string]string{"SAMLRequest": "ZmFrZS1zYW1sLXJlcXVlc3Q="}))
+}
+
+func (s *Server) handleSSO(w http.ResponseWriter, req *http.Request) {
+	req.ParseForm()
+	execution := req.URL.Query().Get("execution")
+	if execution == "" {
+		writeHTML(w, `<form method="post" action="`+ssoPath+`?execution=e1s1"><input name="j_username"/><input name="j_password" type="password"/></form>`)
+		return
+	}
+
+	if username := req.PostForm.Get("challenge_user"); username != "" {
+		_, proceed := req.PostForm["_eventId_proceed"]
+		s.handleChallenge(w, username, req.PostForm.Get("j_otp"), proceed, execution)
+		return
+	}
+
+	username := req.PostForm.Get("j_username")
+	password := req.PostForm.Get("j_password")
+
+	s.mu.Lock()
+	expected, known := s.Accounts[username]
+	checkAccounts := len(s.Accounts) > 0
+	s.mu.Unlock()
+
+	if checkAccounts && !known {
+		writeHTML(w, `<div class="alert alert-danger">The username you entered cannot be identified.</div>`)
+		return
+	}
+	if checkAccounts && expected != password {
+		writeHTML(w, `<div class="alert alert-danger">The password you entered was incorrect.</div>`)
+		return
+	}
+
+	s.mu.Lock()
+	challenge := s.Challenge
+	s.pushPolled[username] = 0
+	if challenge == "push" {
+		s.pushesSent[username]++
+	}
+	s.mu.Unlock()
+	if challenge != "" {
+		writeChallenge(w, challenge, username, "", "e1s2")
+		return
+	}
+	s.writeSAMLResponse(w, username)
+}
+
+func (s *Server) writeSAMLResponse(w http.ResponseWriter, username string) {
+	writeHTML(w, samlForm(s.URL+regPrefix+"/saml/SSO", map[string]string{
+		"RelayState":   "ss:mem:fake",
+		"SAMLResponse": "ZmFrZS1zYW1sLXJlc3BvbnNlOg==" + username,
+	}))
+}
+
+// handleChallenge answers a submitted challenge step the way Shibboleth
+// does: the SAMLResponse once it is passed, or the step again. A push step
+// posted without an event is a poll; proceeding sends another push.
+func (s *Server) handleChallenge(w http.ResponseWriter, username string, passcode string, proceed bool, execution string) {
+	s.mu.Lock()
+	challenge := s.Challenge
+	passed := false
+	alert := ""
+	switch challenge {
+	case "passcode":
+		passed = passcode == s.Passcode
+		alert = "Incorrect passcode. Please try again."
+	case "push":
+		if proceed {
+			s.pushPolled[username] = 0
+			s.pushesSent[username]++
+			break
+		}
+		s.pushPolled[username]++
+		passed = s.pushPolled[username] > s.PushPolls
+	case "expiring":
+		passed = true
+	}
+	s.mu.Unlock()
+
+	if passed {
+		s.writeSAMLResponse(w, username)
+		return
+	}
+	if challenge == "push" {
+		alert = ""
+	}
+	writeChallenge(w, challenge, username, alert, execution)
+}
+
+// writeChallenge renders the page for a challenge step.
+func writeChallenge(w http.ResponseWriter, challenge string, username string, alert string, execution string) {
+	var body strings.Builder
+	if alert != "" {
+		fmt.Fprintf(&body, `<div class="alert alert-danger">%s</div>`, html.EscapeString(alert))
+	}
+	fmt.Fprintf(&body, `<form method="post" action="%s?execution=%s"><input type="hidden" name="challenge_user" value="%s"/>`, ssoPath, execution, html.EscapeString(username))
+	switch challenge {
+	case "passcode":
+		body.WriteString(`<p>Enter a passcode from Duo Mobile.</p><input type="text" name="j_otp"/>`)
+	case "push":
+		body.WriteString(`<iframe id="duo_iframe" data-host="api-fake.duosecurity.com"></iframe><p>Pushed a login request to your device.</p>`)
+	case "consent":
+		body.WriteString(`<p>Information to be provided to the service.</p><input type="checkbox" name="_shib_idp_consentIds" value="mail"/>`)
+	case "expiring":
+		body.WriteString(`<p>Your password will expire in 3 days.</p>`)
+	case "expired":
+		body.WriteString(`<p>Your password has expired and must be changed.</p>`)
+	}
+	body.WriteString(`<button name="_eventId_proceed">Continue</button></form>`)
+	writeHTML(w, body.String())
+}
+
+func (s *Server) handleAssertionConsumer(w http.ResponseWriter, req *http.Request) {
+	req.ParseForm()
+	response := req.PostForm.Get("SAMLResponse")
+	if response == "" {
+		writeError(w, http.StatusForbidden, "Missing SAMLResponse")
+		return
+	}
+	username := strings.TrimPrefix(response, "ZmFrZS1zYW1sLXJlc3BvbnNlOg==")
+
+	s.mu.Lock()
+	id := s.newSession(username)
+	s.mu.Unlock()
+
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: id, Path: "/"})
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) handleEIS(w http.ResponseWriter, req *http.Request) {
+	writeHTML(w, samlForm(s.URL+regPrefix+"/saml/SSO/alias/registrationssb-prod-sp", map[string]string{
+		"SAMLResponse": "ZmFrZS1zYW1sLXJlc3BvbnNlOg==eis",
+	}))
+}
+
+func (s *Server) handleRegistrationHome(w http.ResponseWriter, req *http.Request) {
+	name := ""
+	if s.loggedIn(req) {
+		name = s.FullName
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, `<!DOCTYPE html><html><head><meta name="fullName" content="%s"></head><body></body></html>`, html.EscapeString(name))
+}
+
+func (s *Server) handleAuthAjax(w http.ResponseWriter, req *http.Request) {
+	if !s.loggedIn(req) {
+		io.WriteString(w, "userNotLoggedIn")
+		return
+	}
+	io.WriteString(w, "")
+}
+
+func (s *Server) handleGetTerms(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	terms := map[string]string{}
+	for description, code := range s.Terms {
+		terms[description] = code
+	}
+	for _, section := range s.sections {
+		terms[section.TermDesc] = section.Term
+	}
+	s.mu.Unlock()
+
+	var result []map[string]string
+	for description, code := range terms {
+		result = append(result, map[string]string{"code": code, "description": description})
+	}
+	writeJSON(w, result)
+}
+
+func (s *Server) handleGetSubjects(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	term := query.Get("term")
+	prefix := strings.ToUpper(query.Get("searchTerm"))
+	offset, _ := strconv.Atoi(query.Get("offset"))
+	limit, _ := strconv.Atoi(query.Get("max"))
+	if offset < 1 {
+		offset = 1
+	}
+	if limit <= 0 {
+		limit = 10
+	}
+
+	s.mu.Lock()
+	var subjects []string
+	seen := map[string]bool{}
+	for _, section := range s.sortedSections() {
+		if term != "" && section.Term != term || seen[section.Subject] || !strings.HasPrefix(section.Subject, prefix) {
+			continue
+		}
+		seen[section.Subject] = true
+		subjects = append(subjects, section.Subject)
+	}
+	s.mu.Unlock()
+
+	// offset is a 1-based page number, as in Banner's lookups.
+	result := []map[string]string{}
+	for i := (offset - 1) * limit; i < len(subjects) && i < offset*limit; i++ {
+		result = append(result, map[string]string{"code": subjects[i], "description": subjects[i]})
+	}
+	writeJSON(w, result)
+}
+
+func (s *Server) handleTermSearch(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Query().Get("mode") != "registration" {
+		writeJSON(w, map[string]any{"fwdURL": s.URL + regPrefix + "/ssb/classSearch/classSearch"})
+		return
+	}
+
+	s.mu.Lock()
+	failures := append([]string{}, s.EligibilityFailures...)
+	s.mu.Unlock()
+
+	writeJSON(w, map[string]any{
+		"studentEligValid":    len(failures) == 0,
+		"studentEligFailures": failures,
+		"fwdURL":              s.URL + regPrefix + "/ssb/classRegistration/classRegistration",
+	})
+}
+
+func (s *Server) handleSearchResults(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	subject := query.Get("txt_subject")
+	term := query.Get("txt_term")
+	courseNumber := query.Get("txt_courseNumber")
+	openOnly := query.Get("chk_open_only") == "true"
+	offset, _ := strconv.Atoi(query.Get("pageOffset"))
+	maxSize, _ := strconv.Atoi(query.Get("pageMaxSize"))
+	if maxSize <= 0 {
+		maxSize = 10
+	}
+
+	s.mu.Lock()
+	var matches []map[string]any
+	for _, section := range s.sortedSections() {
+		if subject != "" && section.Subject != subject {
+			continue
+		}
+		if term != "" && section.Term != term {
+			continue
+		}
+		if courseNumber != "" && section.CourseNumber != courseNumber {
+			continue
+		}
+		if openOnly && section.Capacity <= section.Enrolled {
+			continue
+		}
+		matches = append(matches, sectionJSON(section))
+	}
+	s.mu.Unlock()
+
+	total := len(matches)
+	page := []map[string]any{}
+	if offset < total {
+		end := offset + maxSize
+		if end > total {
+			end = total
+		}
+		page = matches[offset:end]
+	}
+
+	writeJSON(w, map[string]any{
+		"success":              true,
+		"totalCount":           total,
+		"data":                 page,
+		"pageOffset":           offset,
+		"pageMaxSize":          maxSize,
+		"sectionsFetchedCount": total,
+		"pathMode":             "search",
+	})
+}
+
+func sectionJSON(section *Section) map[string]any {
+	seats := section.Capacity - section.Enrolled
+	waitSeats := section.WaitCapacity - section.WaitCount
+	attributes := []map[string]any{}
+	for _, attribute := range section.Attributes {
+		attributes = append(attributes, map[string]any{
+			"code":                  attribute,
+			"description":           attribute,
+			"courseReferenceNumber": section.CRN,
+			"termCode":              section.Term,
+		})
+	}
+	meeting := meetingJSON(section)
+	return map[string]any{
+		"term":                           section.Term,
+		"termDesc":                       section.TermDesc,
+		"courseReferenceNumber":          section.CRN,
+		"courseNumber":                   section.CourseNumber,
+		"subject":                        section.Subject,
+		"sequenceNumber":                 section.SequenceNumber,
+		"campusDescription":              section.Campus,
+		"courseTitle":                    section.Title,
+		"maximumEnrollment":              section.Capacity,
+		"enrollment":                     section.Enrolled,
+		"seatsAvailable":                 seats,
+		"waitCapacity":                   section.WaitCapacity,
+		"waitCount":                      section.WaitCount,
+		"waitAvailable":                  waitSeats,
+		"openSection":                    seats > 0,
+		"subjectCourse":                  section.Subject + section.CourseNumber,
+		"instructionalMethodDescription": section.Method,
+		"crossList":                      nullable(section.CrossList),
+		"linkIdentifier":                 nullable(section.LinkIdentifier),
+		"isSectionLinked":                section.LinkIdentifier != "",
+		"sectionAttributes":              attributes,
+		"faculty": []map[string]any{{
+			"courseReferenceNumber": section.CRN,
+			"displayName":           section.Instructor,
+			"primaryIndicator":      true,
+			"term":                  section.Term,
+		}},
+		"meetingsFaculty": []map[string]any{{
+			"courseReferenceNumber": section.CRN,
+			"meetingTime":           meeting,
+			"term":                  section.Term,
+		}},
+	}
+}
+
+func meetingJSON(section *Section) map[string]any {
+	return map[string]any{
+		"beginTime":              section.BeginTime,
+		"endTime":                section.EndTime,
+		"startDate":              section.StartDate,
+		"endDate":                section.EndDate,
+		"room":                   section.Room,
+		"campusDescription":      section.Campus,
+		"courseReferenceNumber":  section.CRN,
+		"meetingTypeDescription": "Class",
+		"term":                   section.Term,
+		"sunday":                 strings.Contains(section.Days, "U"),
+		"monday":                 strings.Contains(section.Days, "M"),
+		"tuesday":                strings.Contains(section.Days, "T"),
+		"wednesday":              strings.Contains(section.Days, "W"),
+		"thursday":               strings.Contains(section.Days, "R"),
+		"friday":                 strings.Contains(section.Days, "F"),
+		"saturday":               strings.Contains(section.Days, "S"),
+	}
+}
+
+// nullable renders an empty string as JSON null, as Banner does.
+func nullable(value string) any {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+func (s *Server) handleFacultyMeetingTimes(w http.ResponseWriter, req *http.Request) {
+	crn := req.URL.Query().Get("courseReferenceNumber")
+
+	s.mu.Lock()
+	section, ok := s.sections[crn]
+	var response map[string]any
+	if ok {
+		response = map[string]any{"fmt": []map[string]any{{
+			"category":              "01",
+			"courseReferenceNumber": section.CRN,
+			"faculty":               []map[string]any{{"displayName": section.Instructor}},
+			"meetingTime":           meetingJSON(section),
+			"term":                  section.Term,
+		}}}
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "Section not found")
+		return
+	}
+	writeJSON(w, response)
+}
+
+func (s *Server) handleLinkedSections(w http.ResponseWriter, req *http.Request) {
+	crn := req.URL.Query().Get("courseReferenceNumber")
+
+	s.mu.Lock()
+	linkedData := [][]map[string]any{}
+	if section, ok := s.sections[crn]; ok {
+		for _, linked := range s.linkedSections(section) {
+			linkedData = append(linkedData, []map[string]any{sectionJSON(linked)})
+		}
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, map[string]any{"linkedData": linkedData})
+}
+
+func (s *Server) handleEnrollmentInfo(w http.ResponseWriter, req *http.Request) {
+	req.ParseForm()
+	crn := req.PostForm.Get("courseReferenceNumber")
+
+	s.mu.Lock()
+	section, ok := s.sections[crn]
+	var rows [][2]string
+	if ok {
+		rows = [][2]string{
+			{"Enrollment Actual:", strconv.Itoa(section.Enrolled)},
+			{"Enrollment Maximum:", strconv.Itoa(section.Capacity)},
+			{"Enrollment Seats Available:", strconv.Itoa(section.Capacity - section.Enrolled)},
+			{"Waitlist Capacity:", strconv.Itoa(section.WaitCapacity)},
+			{"Waitlist Actual:", strconv.Itoa(section.WaitCount)},
+			{"Waitlist Seats Available:", strconv.Itoa(section.WaitCapacity - section.WaitCount)},
+		}
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, http.StatusNotFound, "Section not found")
+		return
+	}
+
+	var builder strings.Builder
+	builder.WriteString("<section>")
+	for _, row := range rows {
+		fmt.Fprintf(&builder, `<span class="status-bold">%s</span> <span dir="ltr">%s</span><br/>`, row[0], row[1])
+	}
+	builder.WriteString("</section>")
+	writeHTML(w, builder.String())
+}
+
+func (s *Server) handleSectionDetails(w http.ResponseWriter, req *http.Request) {
+	crn := req.URL.Query().Get("courseReferenceNumber")
+
+	s.mu.Lock()
+	section, ok := s.sections[crn]
+	var response map[string]any
+	if ok {
+		response = map[string]any{
+			"subject":         section.Subject,
+			"courseTitle":     section.Title,
+			"sequenceNumber":  section.SequenceNumber,
+			"courseNumber":    section.CourseNumber,
+			"responseDisplay": fmt.Sprintf("%s %s %s - %s", section.Subject, section.CourseNumber, section.SequenceNumber, section.Title),
+			"olr":             false,
+			"success":         true,
+		}
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeJSON(w, map[string]any{"olr": true, "success": false})
+		return
+	}
+	writeJSON(w, response)
+}
+
+func (s *Server) handleAddRegistrationItem(w http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	crn := query.Get("courseReferenceNumber")
+
+	s.mu.Lock()
+	section, ok := s.sections[crn]
+	var model map[string]any
+	if ok {
+		s.worksheet[crn] = true
+		model = map[string]any{
+			"courseReferenceNumber": section.CRN,
+			"term":                  query.Get("term"),
+			"subject":               section.Subject,
+			"courseNumber":          section.CourseNumber,
+			"courseTitle":           section.Title,
+			"sequenceNumber":        section.SequenceNumber,
+			"selectedAction":        nil,
+		}
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeJSON(w, map[string]any{"success": false, "message": "Invalid CRN"})
+		return
+	}
+	writeJSON(w, map[string]any{"success": true, "model": model})
+}
+
+func (s *Server) handleResetWorksheet(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	s.worksheet = map[string]bool{}
+	s.mu.Unlock()
+	s.handleOK(w, req)
+}
+
+func (s *Server) handleBatch(w http.ResponseWriter, req *http.Request) {
+	var batch struct {
+		Update []map[string]any `json:"update"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&batch); err != nil {
+		writeError(w, http.StatusBadRequest, "Malformed batch")
+		return
+	}
+
+	s.mu.Lock()
+	s.worksheet = map[string]bool{}
+	drops := make(map[string]bool)
+	for _, model := range batch.Update {
+		crn, _ := model["courseReferenceNumber"].(string)
+		if action, _ := model["selectedAction"].(string); action == "DW" {
+			drops[crn] = true
+		}
+	}
+	adds := s.batchAdds(batch.Update, drops)
+	var updates []map[string]any
+	for _, model := range batch.Update {
+		crn, _ := model["courseReferenceNumber"].(string)
+		action, _ := model["selectedAction"].(string)
+		updates = append(updates, s.applyAction(crn, action, adds, drops))
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, map[string]any{
+		"success": true,
+		"data": map[string]any{
+			"create":  []any{},
+			"destroy": []any{},
+			"update":  updates,
+		},
+	})
+}
+
+// batchAdds returns the CRNs a batch adds that have room and do not clash
+// with a class kept through the batch, less any linked section left without
+// a companion, so a lab is not taken when its lecture is rejected. It must
+// be called with s.mu held.
+func (s *Server) batchAdds(models []map[string]any, drops map[string]bool) map[string]bool {
+	adds := make(map[string]bool)
+	for _, model := range models {
+		crn, _ := model["courseReferenceNumber"].(string)
+		action, _ := model["selectedAction"].(string)
+		if section, ok := s.sections[crn]; ok && s.hasRoom(section, action) && s.clash(section, drops) == "" {
+			adds[crn] = true
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for crn := range adds {
+			if !s.linkSatisfied(crn, adds) {
+				delete(adds, crn)
+				changed = true
+			}
+		}
+	}
+	return adds
+}
+
+// hasRoom reports whether section has a seat for an RW or a waitlist spot
+// for a WL.
+func (s *Server) hasRoom(section *Section, action string) bool {
+	switch action {
+	case "RW":
+		return section.Enrolled < section.Capacity
+	case "WL":
+		return section.WaitCount < section.WaitCapacity
+	}
+	return false
+}
+
+// clash returns why Banner would refuse to add section next to the classes
+// already taken, other than those the batch drops: another section of the
+// same course that it is not linked to, or one meeting at the same time. It
+// is empty when there is no clash. It must be called with s.mu held.
+func (s *Server) clash(section *Section, drops map[string]bool) string {
+	linked := make(map[string]bool)
+	for _, other := range s.linkedSections(section) {
+		linked[other.CRN] = true
+	}
+	for crn, status := range s.registered {
+		other, ok := s.sections[crn]
+		if !ok || crn == section.CRN || drops[crn] || (status != "Registered" && status != "Waitlisted") {
+			continue
+		}
+		if other.Subject == section.Subject && other.CourseNumber == section.CourseNumber && !linked[crn] {
+			return "Duplicate Course with Section " + other.SequenceNumber
+		}
+		if overlaps(section, other) {
+			return "Time conflict with CRN " + crn
+		}
+	}
+	return ""
+}
+
+// overlaps reports whether two sections meet on a shared day at overlapping
+// times during overlapping dates.
+func overlaps(a *Section, b *Section) bool {
+	if a.BeginTime == "" || b.BeginTime == "" || !strings.ContainsAny(a.Days, b.Days) {
+		return false
+	}
+	if a.BeginTime >= b.EndTime || b.BeginTime >= a.EndTime {
+		return false
+	}
+	const layout = "01/02/2006"
+	aStart, errA := time.Parse(layout, a.StartDate)
+	bEnd, errB := time.Parse(layout, b.EndDate)
+	bStart, errC := time.Parse(layout, b.StartDate)
+	aEnd, errD := time.Parse(layout, a.EndDate)
+	if errA != nil || errB != nil || errC != nil || errD != nil {
+		return true
+	}
+	return !aStart.After(bEnd) && !bStart.After(aEnd)
+}
+
+// linkSatisfied reports whether a linked section is being added with, or is
+// already taken with, one of the sections it needs. It must be called with
+// s.mu held.
+func (s *Server) linkSatisfied(crn string, adds map[string]bool) bool {
+	section, ok := s.sections[crn]
+	if !ok {
+		return true
+	}
+	linked := s.linkedSections(section)
+	if len(linked) == 0 {
+		return true
+	}
+	for _, other := range linked {
+		if adds[other.CRN] || s.registered[other.CRN] == "Registered" || s.registered[other.CRN] == "Waitlisted" {
+			return true
+		}
+	}
+	return false
+}
+
+// applyAction must be called with s.mu held. adds holds the CRNs the batch
+// can add, for linked sections to find their companions in, and drops the
+// CRNs it drops, which no longer clash with what it adds.
+func (s *Server) applyAction(crn string, action string, adds map[string]bool, drops map[string]bool) map[string]any {
+	update := map[string]any{"courseReferenceNumber": crn}
+	section, ok := s.sections[crn]
+	if !ok {
+		return rejected(update, "Invalid CRN")
+	}
+	update["subject"] = section.Subject
+	update["courseNumber"] = section.CourseNumber
+	update["courseTitle"] = section.Title
+
+	if action == "RW" || action == "WL" {
+		if message := s.clash(section, drops); message != "" {
+			return rejected(update, message)
+		}
+		if s.hasRoom(section, action) && !adds[crn] {
+			return rejected(update, "Linked course required")
+		}
+	}
+	switch action {
+	case "RW":
+		if section.Enrolled >= section.Capacity {
+			return rejected(update, fmt.Sprintf("Closed - %d Waitlisted", section.WaitCount))
+		}
+		section.Enrolled++
+		s.registered[crn] = "Registered"
+	case "WL":
+		if section.WaitCount >= section.WaitCapacity {
+			return rejected(update, "Closed - Waitlist Full")
+		}
+		section.WaitCount++
+		s.registered[crn] = "Waitlisted"
+		s.waitPositions[crn] = section.WaitCount
+	case "DW":
+		switch s.registered[crn] {
+		case "Registered":
+			section.Enrolled--
+		case "Waitlisted":
+			section.WaitCount--
+		}
+		s.registered[crn] = "Deleted"
+	default:
+		return rejected(update, fmt.Sprintf("Unsupported action %q", action))
+	}
+	update["statusDescription"] = s.registered[crn]
+	return update
+}
+
+func rejected(update map[string]any, message string) map[string]any {
+	update["statusDescription"] = "Errors Preventing Registration"
+	update["crnErrors"] = []map[string]any{{"message": message, "messageType": "ERROR"}}
+	return update
+}
+
+func (s *Server) handleRegistrationHistory(w http.ResponseWriter, req *http.Request) {
+	term := req.URL.Query().Get("term")
+
+	s.mu.Lock()
+	registrations := []map[string]any{}
+	for _, section := range s.sortedSections() {
+		status, ok := s.registered[section.CRN]
+		if !ok || section.Term != term {
+			continue
+		}
+		registration := map[string]any{
+			"courseReferenceNumber":               section.CRN,
+			"term":                                section.Term,
+			"subject":                             section.Subject,
+			"courseNumber":                        section.CourseNumber,
+			"sequenceNumber":                      section.SequenceNumber,
+			"courseTitle":                         section.Title,
+			"courseRegistrationStatusDescription": status,
+			"creditHour":                          section.Credits,
+		}
+		if status == "Waitlisted" {
+			registration["waitlistPosition"] = s.waitPositions[section.CRN]
+		}
+		registrations = append(registrations, registration)
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, map[string]any{"success": true, "data": map[string]any{"registrations": registrations}})
+}
+
+func (s *Server) handleStudent(w http.ResponseWriter, req *http.Request) {
+	writeJSON(w, map[string]any{
+		"_embedded": map[string]any{
+			"students": []map[string]any{{
+				"id":   "00000001",
+				"name": s.FullName,
+				"goals": []map[string]any{{
+					"school": map[string]string{"key": "UG", "description": "Undergraduate"},
+					"degree": map[string]string{"key": "AA", "description": "Associate in Arts"},
+				}},
+			}},
+		},
+	})
+}
+
+func (s *Server) handleAudit(w http.ResponseWriter, req *http.Request) {
+	s.mu.Lock()
+	var classes []map[string]string
+	for _, class := range s.Transcript {
+		classes = append(classes, map[string]string{
+			"discipline":      class.Subject,
+			"number":          class.Number,
+			"courseTitle":     class.Title,
+			"letterGrade":     class.LetterGrade,
+			"credits":         class.Credits,
+			"termLiteralLong": class.Term,
+		})
+	}
+	s.mu.Unlock()
+
+	writeJSON(w, map[string]any{
+		"classInformation": map[string]any{"classArray": classes},
+	})
+}
+
+func (s *Server) handleWebhook(w http.ResponseWriter, req *http.Request) {
+	var payload struct {
+		Embeds []struct {
+			Title string `json:"title"`
+		} `json:"embeds"`
+	}
+	json.NewDecoder(req.Body).Decode(&payload)
+
+	s.mu.Lock()
+	for _, embed := range payload.Embeds {
+		s.notifications = append(s.notifications, embed.Title)
+	}
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,325 @@
+// Package fakebanner is an in-process stand-in for the FHDA Banner
+// registration, Shibboleth SSO, EIS and DegreeWorks hosts. It speaks just
+// enough of each protocol for a Task to log in, search, sign up, watch and
+// export a transcript without touching the network.
+package fakebanner
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+
+	http "github.com/bogdanfinn/fhttp"
+	"github.com/bogdanfinn/fhttp/cookiejar"
+
+	"register-bot/internal/tasks"
+)
+
+const sessionCookie = "JSESSIONID"
+
+// Section is one offered class section.
+type Section struct {
+	CRN            string
+	Term           string
+	TermDesc       string
+	Subject        string
+	CourseNumber   string
+	SequenceNumber string
+	Title          string
+	Credits        float64
+	Instructor     string
+	Campus         string
+	Method         string
+	BeginTime      string
+	EndTime        string
+	Days           string // any of "UMTWRFS"
+	StartDate      string
+	EndDate        string
+	Room           string
+	Capacity       int
+	Enrolled       int
+	WaitCapacity   int
+	WaitCount      int
+	CrossList      string
+	LinkIdentifier string
+	Attributes     []string
+}
+
+// Class is one row of the fake DegreeWorks transcript.
+type Class struct {
+	Term        string
+	Subject     string
+	Number      string
+	Title       string
+	LetterGrade string
+	Credits     string
+}
+
+type Server struct {
+	URL string
+
+	// Accounts maps username to password. When empty any credentials log in.
+	Accounts map[string]string
+	FullName string
+	Terms    map[string]string // description -> code
+	// EligibilityFailures is returned verbatim from term/search?mode=registration.
+	EligibilityFailures []string
+	Transcript          []Class
+	// Challenge is a step the IdP asks for after a correct password:
+	// "passcode" until Passcode is entered, "push" until it has been polled
+	// PushPolls more times, or "consent", "expiring" or "expired". Empty
+	// signs in straight away. Proceeding from a push step sends a new push,
+	// which has to be polled for all over again.
+	Challenge string
+	Passcode  string
+	PushPolls int
+
+	mu            sync.Mutex
+	sections      map[string]*Section
+	registered    map[string]string // CRN -> status description
+	worksheet     map[string]bool   // CRNs queued and not yet submitted
+	waitPositions map[string]int    // CRN -> place on the waitlist
+	sessions      map[string]string // session id -> username
+	notifications []string
+	faults        map[string][]fault
+	listener      net.Listener
+	server        *http.Server
+	nextSession   int
+	pushPolled    map[string]int // username -> times asked about a push
+	pushesSent    map[string]int // username -> pushes sent to the device
+}
+
+// New starts a fake server on a loopback port, seeded with sections.
+func New(sections ...Section) (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		URL:           "http://" + listener.Addr().String(),
+		FullName:      "Offline Student",
+		Terms:         map[string]string{},
+		sections:      map[string]*Section{},
+		registered:    map[string]string{},
+		worksheet:     map[string]bool{},
+		waitPositions: map[string]int{},
+		sessions:      map[string]string{},
+		pushPolled:    map[string]int{},
+		pushesSent:    map[string]int{},
+		faults:        map[string][]fault{},
+		listener:      listener,
+	}
+	for i := range sections {
+		section := sections[i]
+		s.sections[section.CRN] = &section
+	}
+	s.server = &http.Server{Handler: s.injectFaults(s.routes())}
+	go s.server.Serve(listener)
+	return s, nil
+}
+
+func (s *Server) Close() error {
+	return s.server.Close()
+}
+
+// Endpoints points every host a Task talks to at this server.
+func (s *Server) Endpoints() tasks.Endpoints {
+	return tasks.SingleHostEndpoints(s.URL)
+}
+
+// WebhookURL accepts Discord-style webhook posts and records them.
+func (s *Server) WebhookURL() string {
+	return s.URL + "/webhook"
+}
+
+// Client returns a plain HTTP client with its own cookie jar, suitable for
+// Task.Client.
+func (s *Server) Client() tasks.HTTPClient {
+	jar, _ := cookiejar.New(nil)
+	return &http.Client{Jar: jar}
+}
+
+// PushesSent returns how many pushes the IdP has sent to username's device.
+func (s *Server) PushesSent(username string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pushesSent[username]
+}
+
+// SetSeats changes how many enrollment and waitlist seats a section has
+// available.
+func (s *Server) SetSeats(crn string, seats int, waitSeats int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	section, ok := s.sections[crn]
+	if !ok {
+		return
+	}
+	section.Enrolled = section.Capacity - seats
+	section.WaitCount = section.WaitCapacity - waitSeats
+}
+
+type fault struct {
+	status     int
+	retryAfter string
+}
+
+// FailNext makes the next times requests to path answer with status and an
+// errorMessage page instead of being served. path may carry a query string,
+// to fail only the request with exactly that query. retryAfter, when not
+// empty, is sent as the Retry-After header.
+func (s *Server) FailNext(path string, status int, times int, retryAfter string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < times; i++ {
+		s.faults[path] = append(s.faults[path], fault{status: status, retryAfter: retryAfter})
+	}
+}
+
+func (s *Server) injectFaults(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		s.mu.Lock()
+		key := req.URL.RequestURI()
+		if len(s.faults[key]) == 0 {
+			key = req.URL.Path
+		}
+		pending := s.faults[key]
+		var injected *fault
+		if len(pending) > 0 {
+			injected = &pending[0]
+			s.faults[key] = pending[1:]
+		}
+		s.mu.Unlock()
+
+		if injected == nil {
+			next.ServeHTTP(w, req)
+			return
+		}
+		if injected.retryAfter != "" {
+			w.Header().Set("Retry-After", injected.retryAfter)
+		}
+		writeError(w, injected.status, fmt.Sprintf("Injected failure (%d)", injected.status))
+	})
+}
+
+// Registered returns the status description of every CRN the batch endpoint
+// has processed, keyed by CRN.
+func (s *Server) Registered() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := make(map[string]string, len(s.registered))
+	for crn, status := range s.registered {
+		result[crn] = status
+	}
+	return result
+}
+
+// Enroll puts a CRN on the student's schedule with a status description such
+// as "Registered" or "Waitlisted", as if it had been signed up for earlier.
+// Seat counts are left alone.
+func (s *Server) Enroll(crn string, status string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.registered[crn] = status
+	if section, ok := s.sections[crn]; ok && status == "Waitlisted" {
+		s.waitPositions[crn] = section.WaitCount
+	}
+}
+
+// Worksheet returns the CRNs queued on the registration worksheet and not
+// yet submitted or cleared, sorted.
+func (s *Server) Worksheet() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var crns []string
+	for crn := range s.worksheet {
+		crns = append(crns, crn)
+	}
+	sort.Strings(crns)
+	return crns
+}
+
+// ExpireSessions logs every client out, as Banner does when sessions time
+// out, so the next task to check its login has to log in again.
+func (s *Server) ExpireSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions = map[string]string{}
+}
+
+// Notifications returns the webhook embed titles received so far.
+func (s *Server) Notifications() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.notifications...)
+}
+
+func (s *Server) sortedSections() []*Section {
+	var result []*Section
+	for _, section := range s.sections {
+		result = append(result, section)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Subject != result[j].Subject {
+			return result[i].Subject < result[j].Subject
+		}
+		if result[i].CourseNumber != result[j].CourseNumber {
+			return result[i].CourseNumber < result[j].CourseNumber
+		}
+		return result[i].CRN < result[j].CRN
+	})
+	return result
+}
+
+func (s *Server) newSession(username string) string {
+	s.nextSession++
+	id := fmt.Sprintf("fake-%d", s.nextSession)
+	s.sessions[id] = username
+	return id
+}
+
+func (s *Server) loggedIn(req *http.Request) bool {
+	cookie, err := req.Cookie(sessionCookie)
+	if err != nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.sessions[cookie.Value]
+	return ok
+}
+
+// linkedSections returns the sections that complete section, as Banner's
+// link identifiers pair them: in the same course, with a different letter
+// and the same number, like lecture "A1" and labs "B1". It must be called
+// with s.mu held.
+func (s *Server) linkedSections(section *Section) []*Section {
+	if len(section.LinkIdentifier) < 2 {
+		return nil
+	}
+	var linked []*Section
+	for _, other := range s.sortedSections() {
+		if other.Term != section.Term || other.Subject != section.Subject || other.CourseNumber != section.CourseNumber || len(other.LinkIdentifier) < 2 {
+			continue
+		}
+		if other.LinkIdentifier[0] != section.LinkIdentifier[0] && other.LinkIdentifier[1:] == section.LinkIdentifier[1:] {
+			linked = append(linked, other)
+		}
+	}
+	return linked
+}
+
+// DemoSections is a small De Anza catalog used by offline runs.
+func DemoSections() []Section {
+	return []Section{
+		{CRN: "41846", Term: "202632", TermDesc: "2026 Winter De Anza", Subject: "MATH", CourseNumber: "1C", SequenceNumber: "01", Title: "Calculus III", Credits: 5, Instructor: "Ada Lovelace", Campus: "De Anza", Method: "In Person", BeginTime: "0930", EndTime: "1045", Days: "MW", StartDate: "01/05/2026", EndDate: "03/27/2026", Room: "S44", Capacity: 40, Enrolled: 40, WaitCapacity: 10, WaitCount: 10, Attributes: []string{"ZTC"}},
+		{CRN: "44412", Term: "202632", TermDesc: "2026 Winter De Anza", Subject: "MATH", CourseNumber: "1C", SequenceNumber: "02", Title: "Calculus III", Credits: 5, Instructor: "Emmy Noether", Campus: "De Anza", Method: "Online", BeginTime: "", EndTime: "", Days: "", StartDate: "01/05/2026", EndDate: "03/27/2026", Room: "ONLINE", Capacity: 45, Enrolled: 30, WaitCapacity: 10, WaitCount: 0},
+		{CRN: "47520", Term: "202632", TermDesc: "2026 Winter De Anza", Subject: "PHYS", CourseNumber: "4A", SequenceNumber: "01", Title: "Physics for Scientists and Engineers: Mechanics", Credits: 6, Instructor: "Richard Feynman", Campus: "De Anza", Method: "In Person", BeginTime: "1130", EndTime: "1320", Days: "TR", StartDate: "01/05/2026", EndDate: "03/27/2026", Room: "S12", Capacity: 36, Enrolled: 36, WaitCapacity: 5, WaitCount: 2, LinkIdentifier: "A1"},
+		{CRN: "47531", Term: "202632", TermDesc: "2026 Winter De Anza", Subject: "PHYS", CourseNumber: "4A", SequenceNumber: "01L", Title: "Physics for Scientists and Engineers: Mechanics", Credits: 0, Instructor: "Richard Feynman", Campus: "De Anza", Method: "In Person", BeginTime: "1400", EndTime: "1650", Days: "R", StartDate: "01/05/2026", EndDate: "03/27/2026", Room: "S16", Capacity: 24, Enrolled: 24, WaitCapacity: 5, WaitCount: 0, LinkIdentifier: "B1"},
+		{CRN: "47532", Term: "202632", TermDesc: "2026 Winter De Anza", Subject: "PHYS", CourseNumber: "4A", SequenceNumber: "02L", Title: "Physics for Scientists and Engineers: Mechanics", Credits: 0, Instructor: "Lise Meitner", Campus: "De Anza", Method: "In Person", BeginTime: "0900", EndTime: "1150", Days: "F", StartDate: "01/05/2026", EndDate: "03/27/2026", Room: "S16", Capacity: 24, Enrolled: 20, WaitCapacity: 5, WaitCount: 0, LinkIdentifier: "B1"},
+		{CRN: "38894", Term: "202632", TermDesc: "2026 Winter De Anza", Subject: "ENGL", CourseNumber: "1B", SequenceNumber: "03", Title: "Reading, Writing and Research", Credits: 5, Instructor: "Toni Morrison", Campus: "De Anza", Method: "Hybrid", BeginTime: "1330", EndTime: "1520", Days: "W", StartDate: "01/05/2026", EndDate: "03/27/2026", Room: "L23", Capacity: 30, Enrolled: 29, WaitCapacity: 5, WaitCount: 0, CrossList: "XE1"},
+		{CRN: "45210", Term: "202632", TermDesc: "2026 Winter De Anza", Subject: "MATH", CourseNumber: "22", SequenceNumber: "61", Title: "Discrete Mathematics", Credits: 5, Instructor: "Alan Turing", Campus: "De Anza", Method: "In Person", BeginTime: "1000", EndTime: "1150", Days: "MW", StartDate: "02/16/2026", EndDate: "03/27/2026", Room: "S46", Capacity: 35, Enrolled: 12, WaitCapacity: 5, WaitCount: 0},
+	}
+}
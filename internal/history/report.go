@@ -0,0 +1,153 @@
+package history
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// curveWidth is how many points a fill curve is drawn with.
+const curveWidth = 24
+
+// Summary describes one section's observations.
+type Summary struct {
+	Term   string
+	CRN    string
+	Course string // e.g. "MATH 1C-01 Calculus III", empty if only Watch saw it
+
+	Observations int
+	First        time.Time
+	Last         time.Time
+
+	Capacity       int
+	SeatsAvailable int
+	WaitAvailable  int
+
+	// FilledAt is when the section was first seen with no seats left; zero
+	// if it never was.
+	FilledAt time.Time
+	// Reopenings counts how often a full section was later seen with seats,
+	// and SeatsFreed how many seats opened up in total.
+	Reopenings int
+	SeatsFreed int
+	// Fill is the fraction of seats taken at each observation.
+	Fill []float64
+}
+
+// Outlook rates the section as a Watch target from its churn.
+func (s Summary) Outlook() string {
+	switch {
+	case s.FilledAt.IsZero():
+		return "never filled"
+	case s.Reopenings >= 2:
+		return "good"
+	case s.Reopenings == 1:
+		return "fair"
+	}
+	return "poor"
+}
+
+// Curve draws Fill as a sparkline of at most curveWidth points.
+func (s Summary) Curve() string {
+	levels := []rune("▁▂▃▄▅▆▇█")
+	var curve strings.Builder
+	points := len(s.Fill)
+	width := points
+	if width > curveWidth {
+		width = curveWidth
+	}
+	for i := 0; i < width; i++ {
+		fill := s.Fill[i*points/width]
+		level := int(fill * float64(len(levels)-1))
+		if level < 0 {
+			level = 0
+		} else if level >= len(levels) {
+			level = len(levels) - 1
+		}
+		curve.WriteRune(levels[level])
+	}
+	return curve.String()
+}
+
+// Summarize groups observations by term and CRN. Summaries are ordered with
+// the sections that churn the most seats first.
+func Summarize(observations []Observation) []Summary {
+	var summaries []Summary
+	index := make(map[[2]string]int)
+	for _, o := range observations {
+		key := [2]string{o.Term, o.CRN}
+		i, ok := index[key]
+		if !ok {
+			i = len(summaries)
+			index[key] = i
+			summaries = append(summaries, Summary{Term: o.Term, CRN: o.CRN, First: o.At})
+		}
+		summary := &summaries[i]
+
+		if summary.Observations > 0 {
+			if summary.SeatsAvailable <= 0 && o.SeatsAvailable > 0 {
+				summary.Reopenings++
+			}
+			if o.SeatsAvailable > summary.SeatsAvailable {
+				summary.SeatsFreed += o.SeatsAvailable - summary.SeatsAvailable
+			}
+		}
+		if summary.FilledAt.IsZero() && o.SeatsAvailable <= 0 {
+			summary.FilledAt = o.At
+		}
+
+		summary.Observations++
+		summary.Last = o.At
+		summary.SeatsAvailable = o.SeatsAvailable
+		summary.WaitAvailable = o.WaitAvailable
+		if o.MaximumEnrollment > 0 {
+			summary.Capacity = o.MaximumEnrollment
+		}
+		if o.Subject != "" {
+			summary.Course = strings.TrimSpace(fmt.Sprintf("%s %s-%s %s", o.Subject, o.CourseNumber, o.SequenceNumber, o.Title))
+		}
+
+		fill := 1.0
+		if summary.Capacity > 0 {
+			fill = 1 - float64(o.SeatsAvailable)/float64(summary.Capacity)
+		} else if o.SeatsAvailable > 0 {
+			fill = 0
+		}
+		summary.Fill = append(summary.Fill, fill)
+	}
+
+	sort.SliceStable(summaries, func(i, j int) bool {
+		a, b := summaries[i], summaries[j]
+		if a.Reopenings != b.Reopenings {
+			return a.Reopenings > b.Reopenings
+		}
+		if a.SeatsFreed != b.SeatsFreed {
+			return a.SeatsFreed > b.SeatsFreed
+		}
+		return a.CRN < b.CRN
+	})
+	return summaries
+}
+
+// WriteReport prints one line per section: its fill curve, when it filled
+// and how often it reopened.
+func WriteReport(w io.Writer, summaries []Summary) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "TERM\tCRN\tCOURSE\tSEEN\tSEATS\tWAIT\tFILLED\tREOPENED\tFREED\tOUTLOOK\tFILL CURVE")
+	for _, s := range summaries {
+		filled := "-"
+		if !s.FilledAt.IsZero() {
+			filled = s.FilledAt.Local().Format("01/02 03:04 PM")
+		}
+		seats := fmt.Sprint(s.SeatsAvailable)
+		if s.Capacity > 0 {
+			seats = fmt.Sprintf("%d/%d", s.SeatsAvailable, s.Capacity)
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%d\t%s\t%d\t%s\t%d\t%d\t%s\t%s\n",
+			s.Term, s.CRN, s.Course, s.Observations, seats, s.WaitAvailable, filled, s.Reopenings, s.SeatsFreed, s.Outlook(), s.Curve())
+	}
+	return table.Flush()
+}
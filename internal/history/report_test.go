@@ -0,0 +1,73 @@
+package history
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSummarize(t *testing.T) {
+	start := time.Date(2026, 1, 5, 8, 0, 0, 0, time.UTC)
+	seen := func(CRN string, hour int, seats int) Observation {
+		return Observation{Term: "202632", CRN: CRN, At: start.Add(time.Duration(hour) * time.Hour), MaximumEnrollment: 40, SeatsAvailable: seats}
+	}
+	observations := []Observation{
+		seen("41846", 0, 10),
+		seen("45210", 0, 20),
+		seen("41846", 1, 0),
+		seen("38894", 1, 0),
+		seen("45210", 1, 15),
+		seen("41846", 2, 3),
+		seen("38894", 2, 2),
+		seen("41846", 3, 0),
+		seen("41846", 4, 1),
+		// Another term's section with the same CRN is summarised apart
+		{Term: "202642", CRN: "41846", At: start, SeatsAvailable: 5},
+	}
+	observations[0].Subject, observations[0].CourseNumber, observations[0].SequenceNumber, observations[0].Title = "MATH", "1C", "01", "Calculus III"
+
+	summaries := Summarize(observations)
+	tests := []struct {
+		term         string
+		CRN          string
+		course       string
+		observations int
+		seats        int
+		filledAt     time.Time
+		reopenings   int
+		seatsFreed   int
+		outlook      string
+	}{
+		{"202632", "41846", "MATH 1C-01 Calculus III", 5, 1, start.Add(time.Hour), 2, 4, "good"},
+		{"202632", "38894", "", 2, 2, start.Add(time.Hour), 1, 2, "fair"},
+		// Ties go by CRN
+		{"202642", "41846", "", 1, 5, time.Time{}, 0, 0, "never filled"},
+		{"202632", "45210", "", 2, 15, time.Time{}, 0, 0, "never filled"},
+	}
+	if len(summaries) != len(tests) {
+		t.Fatalf("Summarize returned %d summaries, want %d", len(summaries), len(tests))
+	}
+	for i, want := range tests {
+		got := summaries[i]
+		if got.Term != want.term || got.CRN != want.CRN || got.Course != want.course || got.Observations != want.observations || got.SeatsAvailable != want.seats || !got.FilledAt.Equal(want.filledAt) || got.Reopenings != want.reopenings || got.SeatsFreed != want.seatsFreed || got.Outlook() != want.outlook {
+			t.Errorf("summary %d = %+v (%s), want %+v", i, got, got.Outlook(), want)
+		}
+	}
+
+	calculus := summaries[0]
+	if !calculus.First.Equal(start) || !calculus.Last.Equal(start.Add(4*time.Hour)) {
+		t.Errorf("41846 seen from %s to %s, want %s to %s", calculus.First, calculus.Last, start, start.Add(4*time.Hour))
+	}
+	if want := []float64{0.75, 1, 1 - 3.0/40, 1, 1 - 1.0/40}; len(calculus.Fill) != len(want) {
+		t.Errorf("Fill = %v, want %v", calculus.Fill, want)
+	} else {
+		for i := range want {
+			if calculus.Fill[i] != want[i] {
+				t.Errorf("Fill = %v, want %v", calculus.Fill, want)
+				break
+			}
+		}
+	}
+	if curve := []rune(calculus.Curve()); len(curve) != 5 || curve[1] != '█' {
+		t.Errorf("Curve() = %q, want 5 points, the second full", calculus.Curve())
+	}
+}
@@ -0,0 +1,168 @@
+// Package history keeps a time series of section seat counts, keyed by term
+// and CRN, so fill curves and seat churn can be reported across many search
+// and watch runs.
+package history
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// DefaultPath is the database history is kept in when no other is given.
+const DefaultPath = "register-bot.db"
+
+// Observation is a section's seat counts at one moment. Watch only sees
+// seat counts, so its observations leave the course fields empty.
+type Observation struct {
+	Term string
+	CRN  string
+	At   time.Time
+
+	Subject        string
+	CourseNumber   string
+	SequenceNumber string
+	Title          string
+
+	MaximumEnrollment int
+	Enrollment        int
+	SeatsAvailable    int
+	WaitCapacity      int
+	WaitCount         int
+	WaitAvailable     int
+}
+
+// timeLayout is fixed-width so observed_at sorts as text.
+const timeLayout = "2006-01-02T15:04:05.000000000Z07:00"
+
+// Store is a history database. It is safe for concurrent use.
+type Store struct {
+	db *sql.DB
+}
+
+const schema = `
+CREATE TABLE IF NOT EXISTS seat_history (
+	term               TEXT NOT NULL,
+	crn                TEXT NOT NULL,
+	observed_at        TEXT NOT NULL,
+	subject            TEXT NOT NULL DEFAULT '',
+	course_number      TEXT NOT NULL DEFAULT '',
+	sequence_number    TEXT NOT NULL DEFAULT '',
+	title              TEXT NOT NULL DEFAULT '',
+	maximum_enrollment INTEGER NOT NULL,
+	enrollment         INTEGER NOT NULL,
+	seats_available    INTEGER NOT NULL,
+	wait_capacity      INTEGER NOT NULL,
+	wait_count         INTEGER NOT NULL,
+	wait_available     INTEGER NOT NULL,
+	PRIMARY KEY (term, crn, observed_at)
+)`
+
+// Open opens or creates the history database at path.
+func Open(path string) (*Store, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows one writer at a time; a single connection queues writes
+	// from concurrent tasks instead of failing them.
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Record saves observations in one transaction. Recording the same term,
+// CRN and time twice keeps the later one.
+func (s *Store) Record(observations []Observation) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	insert, err := tx.Prepare(`INSERT OR REPLACE INTO seat_history (
+		term, crn, observed_at, subject, course_number, sequence_number, title,
+		maximum_enrollment, enrollment, seats_available, wait_capacity, wait_count, wait_available
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+
+	for _, o := range observations {
+		if _, err := insert.Exec(o.Term, o.CRN, o.At.UTC().Format(timeLayout), o.Subject, o.CourseNumber, o.SequenceNumber, o.Title,
+			o.MaximumEnrollment, o.Enrollment, o.SeatsAvailable, o.WaitCapacity, o.WaitCount, o.WaitAvailable); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Query selects observations for a report. Empty fields don't filter.
+type Query struct {
+	Term    string
+	CRNs    []string
+	Subject string
+}
+
+// Observations returns the matching observations ordered by term, CRN and
+// time.
+func (s *Store) Observations(query Query) ([]Observation, error) {
+	var where []string
+	var args []any
+	if query.Term != "" {
+		where = append(where, "term = ?")
+		args = append(args, query.Term)
+	}
+	if len(query.CRNs) > 0 {
+		where = append(where, "crn IN (?"+strings.Repeat(", ?", len(query.CRNs)-1)+")")
+		for _, crn := range query.CRNs {
+			args = append(args, crn)
+		}
+	}
+	if query.Subject != "" {
+		// Watch observations carry no subject, so match on any observation
+		// of the CRN that does.
+		where = append(where, "crn IN (SELECT crn FROM seat_history WHERE subject = ?)")
+		args = append(args, query.Subject)
+	}
+
+	statement := `SELECT term, crn, observed_at, subject, course_number, sequence_number, title,
+		maximum_enrollment, enrollment, seats_available, wait_capacity, wait_count, wait_available
+		FROM seat_history`
+	if len(where) > 0 {
+		statement += " WHERE " + strings.Join(where, " AND ")
+	}
+	statement += " ORDER BY term, crn, observed_at"
+
+	rows, err := s.db.Query(statement, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var observations []Observation
+	for rows.Next() {
+		var o Observation
+		var at string
+		if err := rows.Scan(&o.Term, &o.CRN, &at, &o.Subject, &o.CourseNumber, &o.SequenceNumber, &o.Title,
+			&o.MaximumEnrollment, &o.Enrollment, &o.SeatsAvailable, &o.WaitCapacity, &o.WaitCount, &o.WaitAvailable); err != nil {
+			return nil, err
+		}
+		if o.At, err = time.Parse(timeLayout, at); err != nil {
+			return nil, fmt.Errorf("observation of %s at %q: %w", o.CRN, at, err)
+		}
+		observations = append(observations, o)
+	}
+	return observations, rows.Err()
+}
@@ -0,0 +1,104 @@
+package tasks
+
+import (
+	"strings"
+	"sync"
+)
+
+// Account is the login every task for one username shares: one client and
+// cookie jar, and the Session they were logged in with. Only one task checks
+// or renews the login at a time, so an expired session is logged in again
+// once rather than by each task that notices.
+type Account struct {
+	Username string
+	Client   HTTPClient
+
+	// mu is held while a task checks or renews the login; session and
+	// restored are only touched with it held.
+	mu       sync.Mutex
+	session  Session
+	restored bool
+	// jarMu is held for reading by every request on Client and for writing
+	// while its cookie jar is replaced.
+	jarMu sync.RWMutex
+}
+
+// SessionManager hands out one Account per username.
+type SessionManager struct {
+	// NewClient makes the client for an account the first time it is used.
+	NewClient func() (HTTPClient, error)
+
+	mu       sync.Mutex
+	accounts map[string]*Account
+}
+
+// Account returns the account for username, creating it and its client the
+// first time. Usernames are matched case-insensitively.
+func (m *SessionManager) Account(username string) (*Account, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := strings.ToLower(username)
+	if account, ok := m.accounts[key]; ok {
+		return account, nil
+	}
+	client, err := m.NewClient()
+	if err != nil {
+		return nil, err
+	}
+	if m.accounts == nil {
+		m.accounts = make(map[string]*Account)
+	}
+	account := &Account{Username: username, Client: client}
+	m.accounts[key] = account
+	return account, nil
+}
+
+// Close closes the idle connections of every account's client.
+func (m *SessionManager) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, account := range m.accounts {
+		account.Client.CloseIdleConnections()
+	}
+}
+
+// loginMu returns the lock held while checking or renewing the login: the
+// account's when the task shares one, the task's own otherwise.
+func (t *Task) loginMu() *sync.Mutex {
+	if t.Account != nil {
+		return &t.Account.mu
+	}
+	return &t.sessionMu
+}
+
+// jarMu returns the lock that orders requests against replacing the
+// client's cookie jar: the account's when the task shares one, the task's
+// own otherwise.
+func (t *Task) jarMu() *sync.RWMutex {
+	if t.Account != nil {
+		return &t.Account.jarMu
+	}
+	return &t.ownJarMu
+}
+
+// adoptSession takes on the account's Session, which another task may have
+// renewed. It must be called with the login lock held.
+func (t *Task) adoptSession() {
+	if t.Account == nil || t.Account.session == (Session{}) {
+		return
+	}
+	t.sessionIDMu.Lock()
+	t.Session = t.Account.session
+	t.sessionIDMu.Unlock()
+}
+
+// shareSession hands the task's Session to the other tasks of its account.
+// It must be called with the login lock held.
+func (t *Task) shareSession() {
+	if t.Account == nil {
+		return
+	}
+	t.sessionIDMu.Lock()
+	t.Account.session = t.Session
+	t.sessionIDMu.Unlock()
+}
@@ -0,0 +1,150 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// subjectPageSize is how many subjects each get_subject lookup asks for.
+const subjectPageSize = 500
+
+// CatalogSnapshot is every section offered in a term at one point in time,
+// with all the fields Banner's class search returns.
+type CatalogSnapshot struct {
+	Term     string          `json:"term"`
+	TakenAt  time.Time       `json:"takenAt"`
+	Subjects []Subject       `json:"subjects"`
+	Sections []CourseSection `json:"sections"`
+}
+
+// GetSubjects lists every subject offered in the term, using the lookup
+// behind the class search subject box.
+func (t *Task) GetSubjects(ctx context.Context) ([]Subject, error) {
+	headers := [][2]string{
+		{"accept", "application/json"},
+		{"accept-language", "en-US,en;q=0.9"},
+		{"user-agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/137.0.0.0 Safari/537.36"},
+	}
+
+	var subjects []Subject
+	for page := 1; ; page++ {
+		values := url.Values{
+			"searchTerm":      {""},
+			"term":            {t.TermID},
+			"offset":          {strconv.Itoa(page)},
+			"max":             {strconv.Itoa(subjectPageSize)},
+			"uniqueSessionId": {t.sessionID()},
+			"_":               {strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10)},
+		}
+		response, err := t.DoReq(t.MakeReq(ctx, "GET", t.regURL("/StudentRegistrationSsb/ssb/classSearch/get_subject?"+values.Encode()), headers, nil), "Getting Subjects", true)
+		if err != nil {
+			discardResp(response)
+			return nil, err
+		}
+		body, _ := readBody(response)
+		var batch []Subject
+		if err := json.Unmarshal(body, &batch); err != nil {
+			return nil, err
+		}
+		for _, subject := range batch {
+			subject.Description = html.UnescapeString(subject.Description)
+			subjects = append(subjects, subject)
+		}
+		if len(batch) < subjectPageSize {
+			return subjects, nil
+		}
+	}
+}
+
+// ResetSearch clears the class search form. Banner keeps returning the
+// previous results until it is reset, so it must be called before searching
+// for another subject in the same session.
+func (t *Task) ResetSearch(ctx context.Context) error {
+	headers := [][2]string{
+		{"accept", "*/*"},
+		{"accept-language", "en-US,en;q=0.9"},
+		{"user-agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/137.0.0.0 Safari/537.36"},
+	}
+
+	response, err := t.DoReq(t.MakeReq(ctx, "POST", t.regURL("/StudentRegistrationSsb/ssb/classSearch/resetDataForm"), headers, nil), "Resetting Search", true)
+	if err != nil {
+		discardResp(response)
+		return err
+	}
+	discardResp(response)
+	return nil
+}
+
+// TakeCatalogSnapshot searches every subject in the term and combines the
+// sections into one snapshot. Search filters still apply.
+func (t *Task) TakeCatalogSnapshot(ctx context.Context) (CatalogSnapshot, error) {
+	snapshot := CatalogSnapshot{Term: t.TermID, TakenAt: time.Now()}
+
+	subjects, err := t.GetSubjects(ctx)
+	if err != nil {
+		return snapshot, err
+	}
+	sort.Slice(subjects, func(i, j int) bool {
+		return subjects[i].Code < subjects[j].Code
+	})
+	snapshot.Subjects = subjects
+
+	seen := make(map[string]bool)
+	for i, subject := range subjects {
+		if err := t.ResetSearch(ctx); err != nil {
+			return snapshot, err
+		}
+		sections, err := t.SearchSections(ctx, subject.Code)
+		if err != nil {
+			return snapshot, err
+		}
+		t.logf("[%d/%d] %s: %d section(s)\n", i+1, len(subjects), subject.Code, len(sections))
+		t.recordSections(sections)
+		for _, section := range sections {
+			if seen[section.CourseReferenceNumber] || !t.Filters.match(section) {
+				continue
+			}
+			seen[section.CourseReferenceNumber] = true
+			snapshot.Sections = append(snapshot.Sections, section)
+		}
+	}
+	return snapshot, nil
+}
+
+func (t *Task) ExportCatalog(snapshot CatalogSnapshot) error {
+	fileName := t.outputPath(fmt.Sprintf("catalog-%s-%s.json", snapshot.Term, snapshot.TakenAt.Format("2006-01-02_15-04-05")))
+	file, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	t.logf("Writing %s\n", fileName)
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(snapshot); err != nil {
+		return err
+	}
+	t.record("File", fileName)
+	t.logf("Exported %d Sections in %d Subjects\n", len(snapshot.Sections), len(snapshot.Subjects))
+	return nil
+}
+
+func (t *Task) Catalog(ctx context.Context) error {
+	t.GenSessionId()
+	if err := t.SubmitTerm(ctx); err != nil {
+		return err
+	}
+	snapshot, err := t.TakeCatalogSnapshot(ctx)
+	if err != nil {
+		return err
+	}
+	return t.ExportCatalog(snapshot)
+}
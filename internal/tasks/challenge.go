@@ -0,0 +1,214 @@
+package tasks
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Kinds of login step, as found in a *ChallengeError.
+const (
+	ChallengePasscode        = "passcode"
+	ChallengePush            = "push"
+	ChallengeConsent         = "consent"
+	ChallengePasswordExpired = "password-expired"
+	ChallengeUnknown         = "unknown"
+)
+
+// DefaultPushTimeout is how long login waits for a push to be approved
+// when Task.PushTimeout is zero.
+const DefaultPushTimeout = 2 * time.Minute
+
+// maxLoginSteps bounds how many pages login walks through after the
+// password, so a page that keeps coming back cannot loop forever.
+const maxLoginSteps = 5
+
+// pushPollInterval is how often login asks the IdP whether a push was
+// approved. Polls post the step's form without an event, which only shows
+// the step again, since proceeding would send another push.
+const pushPollInterval = 3 * time.Second
+
+var (
+	executionPattern     = regexp.MustCompile(`execution=(e\d+s\d+)`)
+	passcodeFieldPattern = regexp.MustCompile(`(?i)otp|passcode|token|code`)
+)
+
+// loginStep is a page the IdP shows between the password and the
+// SAMLResponse, read from its first form.
+type loginStep struct {
+	Kind      string
+	Action    string
+	Execution string
+	Message   string
+	Fields    url.Values
+	// CodeField is the input a passcode goes in.
+	CodeField string
+	// Proceed means the page is only a notice, such as a password that
+	// expires soon, and can be clicked through.
+	Proceed bool
+}
+
+// readLoginStep works out what a page after the password asks for. page is
+// the URL it was fetched from, which its form's action is relative to.
+func readLoginStep(document *goquery.Document, page string) loginStep {
+	form := document.Find("form").First()
+	action, _ := form.Attr("action")
+	step := loginStep{Kind: ChallengeUnknown, Action: page, Fields: url.Values{}}
+	if base, err := url.Parse(page); err == nil {
+		if ref, err := url.Parse(action); err == nil {
+			step.Action = base.ResolveReference(ref).String()
+		}
+	}
+	if match := executionPattern.FindStringSubmatch(step.Action); match != nil {
+		step.Execution = match[1]
+	}
+	form.Find("input").Each(func(index int, input *goquery.Selection) {
+		name, _ := input.Attr("name")
+		kind, _ := input.Attr("type")
+		value, _ := input.Attr("value")
+		switch {
+		case name == "":
+		case kind == "hidden":
+			step.Fields.Add(name, value)
+		case step.CodeField == "" && passcodeFieldPattern.MatchString(name) && (kind == "" || kind == "text" || kind == "number" || kind == "tel" || kind == "password"):
+			step.CodeField = name
+		}
+	})
+	step.Message = strings.Join(strings.Fields(document.Find(".alert, .form-error").First().Text()), " ")
+
+	text := strings.ToLower(document.Find("body").Text())
+	switch {
+	case document.Find("input[name='_shib_idp_consentIds'], input[name='_shib_idp_consentOptions']").Length() > 0:
+		step.Kind = ChallengeConsent
+	case strings.Contains(text, "password has expired") || strings.Contains(text, "must change your password"):
+		step.Kind = ChallengePasswordExpired
+	case strings.Contains(text, "password will expire") || strings.Contains(text, "password is expiring"):
+		step.Proceed = true
+	case step.CodeField != "":
+		step.Kind = ChallengePasscode
+	case document.Find("#duo_iframe, iframe[data-sig-request], iframe[data-host]").Length() > 0 || form.Find("input[name='sig_request'], input[name='_eventId_push'], button[name='_eventId_push']").Length() > 0:
+		// Duo's prompt frame, or a form that only offers to push
+		step.Kind = ChallengePush
+	}
+	return step
+}
+
+// passChallenges walks the pages the IdP shows after the password until
+// one carries the SAMLResponse. Notices are clicked through, a passcode is
+// asked for with Prompt and a push is waited on until it is approved;
+// anything else is a *ChallengeError.
+func (t *Task) passChallenges(ctx context.Context, document *goquery.Document, page string) error {
+	for steps := 0; ; steps++ {
+		if response := getSelectorAttr(document, "input[name='SAMLResponse']", "value"); response != "" {
+			t.Session.RelayState = getSelectorAttr(document, "input[name='RelayState']", "value")
+			t.Session.SAMLResponse = response
+			return nil
+		}
+
+		step := readLoginStep(document, page)
+		if steps >= maxLoginSteps {
+			return &ChallengeError{Kind: step.Kind, Execution: step.Execution, Message: fmt.Sprintf("still not signed in after %d steps", steps)}
+		}
+		var err error
+		switch {
+		case step.Proceed:
+			t.logf("SSO Notice at %s, Continuing\n", step.Execution)
+			document, err = t.submitLoginStep(ctx, step, "Submitting SSO Step", true)
+		case step.Kind == ChallengePasscode:
+			document, err = t.answerPasscode(ctx, step)
+		case step.Kind == ChallengePush:
+			document, err = t.awaitPush(ctx, step)
+		default:
+			t.logf("SSO Stopped at %s (%s)\n", step.Execution, step.Kind)
+			return &ChallengeError{Kind: step.Kind, Execution: step.Execution, Message: step.Message}
+		}
+		if err != nil {
+			return err
+		}
+		page = step.Action
+	}
+}
+
+// answerPasscode asks for a one-time passcode with Prompt and submits it.
+// A wrong code comes back as the same step, which asks again.
+func (t *Task) answerPasscode(ctx context.Context, step loginStep) (*goquery.Document, error) {
+	if t.Prompt == nil {
+		return nil, &ChallengeError{Kind: step.Kind, Execution: step.Execution, Message: "a passcode is required and there is no prompt to ask for one"}
+	}
+	if step.Message != "" {
+		t.logln(step.Message)
+	}
+	t.logf("SSO Asking for a Passcode at %s\n", step.Execution)
+	code, err := t.Prompt(fmt.Sprintf("Passcode for %s: ", t.Username))
+	if err != nil {
+		return nil, &ChallengeError{Kind: step.Kind, Execution: step.Execution, Err: err}
+	}
+	step.Fields.Set(step.CodeField, strings.TrimSpace(code))
+	return t.submitLoginStep(ctx, step, "Submitting SSO Passcode", true)
+}
+
+// awaitPush waits for a push to be approved on the student's device,
+// polling the IdP every pushPollInterval until it moves on or PushTimeout
+// passes. A poll that fails is tried again at the next interval.
+func (t *Task) awaitPush(ctx context.Context, step loginStep) (*goquery.Document, error) {
+	timeout := t.PushTimeout
+	if timeout <= 0 {
+		timeout = DefaultPushTimeout
+	}
+	t.logf("SSO Waiting for Push Approval for %s\n", t.Username)
+	deadline := time.Now().Add(timeout)
+	for {
+		if err := sleepCtx(ctx, pushPollInterval); err != nil {
+			return nil, err
+		}
+		document, err := t.submitLoginStep(ctx, step, "Checking SSO Push", false)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			t.logf("Error Checking SSO Push: %v\n", err)
+		} else if getSelectorAttr(document, "input[name='SAMLResponse']", "value") != "" {
+			t.logln("SSO Push Approved")
+			return document, nil
+		} else if next := readLoginStep(document, step.Action); next.Kind != ChallengePush {
+			return document, nil
+		} else {
+			step = next
+		}
+		if time.Now().After(deadline) {
+			return nil, &ChallengeError{Kind: step.Kind, Execution: step.Execution, Message: fmt.Sprintf("push not approved within %s", timeout)}
+		}
+	}
+}
+
+// submitLoginStep posts a step's form, with its hidden fields, as stage
+// and returns the page that comes back. proceed adds the event that moves
+// the login on; without it the IdP only shows the step again.
+func (t *Task) submitLoginStep(ctx context.Context, step loginStep, stage string, proceed bool) (*goquery.Document, error) {
+	headers := [][2]string{
+		{"accept", "*/*"},
+		{"accept-language", "en-US,en;q=0.9"},
+		{"content-type", "application/x-www-form-urlencoded"},
+		{"user-agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/137.0.0.0 Safari/537.36"},
+	}
+
+	values := url.Values{}
+	for name, value := range step.Fields {
+		values[name] = value
+	}
+	if proceed {
+		values.Set("_eventId_proceed", "")
+	}
+	response, err := t.DoReq(t.MakeReq(ctx, "POST", step.Action, headers, []byte(values.Encode())), stage, true)
+	if err != nil {
+		discardResp(response)
+		return nil, err
+	}
+	body, _ := readBody(response)
+	return goquery.NewDocumentFromReader(strings.NewReader(string(body)))
+}
@@ -1,16 +1,19 @@
 package tasks
 
 import (
-	"encoding/csv"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/url"
-	"os"
+	"sort"
 	"strconv"
-	"time"
+	"sync"
+
+	"register-bot/internal/export"
 )
 
-func (t *Task) SubmitTerm() error {
+func (t *Task) SubmitTerm(ctx context.Context) error {
 	headers := [][2]string{
 		{"accept", "*/*"},
 		{"accept-language", "en-US,en;q=0.9"},
@@ -23,10 +26,10 @@ func (t *Task) SubmitTerm() error {
 		"studyPath":       {},
 		"startDatepicker": {},
 		"endDatepicker":   {},
-		"uniqueSessionId": {t.Session.UniqueSessionId},
+		"uniqueSessionId": {t.sessionID()},
 	}
 
-	response, err := t.DoReq(t.MakeReq("POST", "https://reg.oci.fhda.edu/StudentRegistrationSsb/ssb/term/search?mode=search", headers, []byte(values.Encode())), "Submitting Term", true)
+	response, err := t.DoReq(t.MakeReq(ctx, "POST", t.regURL("/StudentRegistrationSsb/ssb/term/search?mode=search"), headers, []byte(values.Encode())), "Submitting Term", true)
 	if err != nil {
 		discardResp(response)
 		return err
@@ -35,32 +38,162 @@ func (t *Task) SubmitTerm() error {
 	return nil
 }
 
-func (t *Task) GetCourses() error {
+// searchPageSize is how many sections each searchResults request asks for.
+const searchPageSize = 100
+
+// maxSearchRequests bounds how many result pages are requested at once.
+const maxSearchRequests = 4
+
+func (t *Task) GetCoursePage(ctx context.Context, subject string, offset int) (Courses, error) {
 	headers := [][2]string{
 		{"accept", "application/json"},
 		{"accept-language", "en-US,en;q=0.9"},
 		{"user-agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/122.0.0.0 Safari/537.36"},
 	}
 
-	url := fmt.Sprintf("https://reg.oci.fhda.edu/StudentRegistrationSsb/ssb/searchResults/searchResults?txt_subject=%s&txt_term=%s&startDatepicker=&endDatepicker=&pageOffset=0&pageMaxSize=100&sortColumn=subjectDescription&sortDirection=asc", t.Subject, t.TermID)
-	response, err := t.DoReq(t.MakeReq("POST", url, headers, nil), fmt.Sprintf("Getting Courses (%s)", t.Subject), true)
+	values := url.Values{
+		"txt_subject":     {subject},
+		"txt_term":        {t.TermID},
+		"startDatepicker": {""},
+		"endDatepicker":   {""},
+		"pageOffset":      {strconv.Itoa(offset)},
+		"pageMaxSize":     {strconv.Itoa(searchPageSize)},
+		"sortColumn":      {"subjectDescription"},
+		"sortDirection":   {"asc"},
+	}
+	t.Filters.values(values)
+
+	courses := Courses{}
+	searchURL := t.regURL("/StudentRegistrationSsb/ssb/searchResults/searchResults?" + values.Encode())
+	response, err := t.DoReq(t.MakeReq(ctx, "POST", searchURL, headers, nil), fmt.Sprintf("Getting Courses (%s, offset %d)", subject, offset), true)
 	if err != nil {
 		discardResp(response)
-		return err
+		return courses, err
 	}
 	body, _ := readBody(response)
-	courses := Courses{}
-	if err := json.Unmarshal(body, &courses); err != nil {
+	err = json.Unmarshal(body, &courses)
+	return courses, err
+}
+
+// SearchSections pages through every search result for subject. The first page gives
+// TotalCount; the rest are requested concurrently, at most
+// maxSearchRequests at a time. Sections come back sorted by subject, course
+// number, sequence number and CRN, with any CRN repeated across pages kept
+// once.
+func (t *Task) SearchSections(ctx context.Context, subject string) ([]CourseSection, error) {
+	first, err := t.GetCoursePage(ctx, subject, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var offsets []int
+	for offset := searchPageSize; offset < first.TotalCount; offset += searchPageSize {
+		offsets = append(offsets, offset)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pages := make([]Courses, len(offsets))
+	errs := make([]error, len(offsets))
+	limit := make(chan struct{}, maxSearchRequests)
+	var waitGroup sync.WaitGroup
+	for i, offset := range offsets {
+		waitGroup.Add(1)
+		go func(i int, offset int) {
+			defer waitGroup.Done()
+			select {
+			case limit <- struct{}{}:
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+			defer func() { <-limit }()
+
+			pages[i], errs[i] = t.GetCoursePage(ctx, subject, offset)
+			if errs[i] != nil {
+				cancel()
+			}
+		}(i, offset)
+	}
+	waitGroup.Wait()
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var sections []CourseSection
+	for _, page := range append([]Courses{first}, pages...) {
+		for _, section := range page.Data {
+			if seen[section.CourseReferenceNumber] {
+				continue
+			}
+			seen[section.CourseReferenceNumber] = true
+			sections = append(sections, section)
+		}
+	}
+	if len(sections) < first.TotalCount {
+		t.logf("Expected %d sections but received %d\n", first.TotalCount, len(sections))
+	}
+
+	sort.SliceStable(sections, func(i, j int) bool {
+		a, b := sections[i], sections[j]
+		if a.Subject != b.Subject {
+			return a.Subject < b.Subject
+		}
+		if a.CourseNumber != b.CourseNumber {
+			return a.CourseNumber < b.CourseNumber
+		}
+		if a.SequenceNumber != b.SequenceNumber {
+			return a.SequenceNumber < b.SequenceNumber
+		}
+		return a.CourseReferenceNumber < b.CourseReferenceNumber
+	})
+	return sections, nil
+}
+
+func (t *Task) GetCourses(ctx context.Context) error {
+	sections, err := t.SearchSections(ctx, t.Subject)
+	if err != nil {
 		return err
 	}
+	t.recordSections(sections)
 
-	if courses.TotalCount == 0 {
-		fmt.Println("No Courses Found")
+	if len(sections) == 0 {
+		t.logln("No Courses Found")
 		return nil
 	}
 
+	var matches []CourseSection
+	for _, section := range sections {
+		if t.Filters.match(section) {
+			matches = append(matches, section)
+		}
+	}
+
+	// A lecture is listed with its labs, ahead of the next lecture.
+	links, err := t.sectionLinks(ctx, matches)
+	if err != nil {
+		return err
+	}
+	groups := linkGroups(matches, links)
+	first := make(map[string]int)
+	for i, section := range matches {
+		if _, ok := first[groups[section.CourseReferenceNumber]]; !ok {
+			first[groups[section.CourseReferenceNumber]] = i
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return first[groups[matches[i].CourseReferenceNumber]] < first[groups[matches[j].CourseReferenceNumber]]
+	})
+
 	var coursesInfo []CourseInfo
-	for _, section := range courses.Data {
+	for _, section := range matches {
 		for _, faculty := range section.Faculty {
 			for _, meetingfaculty := range section.MeetingsFaculty {
 				course := CourseInfo{
@@ -81,36 +214,50 @@ func (t *Task) GetCourses() error {
 					Enrollment:            section.Enrollment,
 					SeatsAvailable:        section.SeatsAvailable,
 					WaitAvailable:         section.WaitAvailable,
+					LinkedCRNs:            formatOptions(links[section.CourseReferenceNumber]),
+				}
+				if course.LinkedCRNs != "" {
+					course.LinkGroup = groups[section.CourseReferenceNumber]
 				}
 				coursesInfo = append(coursesInfo, course)
 			}
 		}
 	}
 
-	t.ExportCourseData(coursesInfo)
-	return nil
-}
-
-func (t *Task) ExportCourseData(courses []CourseInfo) error {
-	currentTime := time.Now()
-	fileName := fmt.Sprintf("%s.csv", currentTime.Format("2006-01-02_15-04-05"))
-	file, err := os.Create(fileName)
-	if err != nil {
-		fmt.Println(err)
+	if len(coursesInfo) == 0 {
+		t.logln("No Courses Match the Search Filters")
+		return nil
 	}
-	defer file.Close()
 
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+	return t.ExportCourseData(coursesInfo)
+}
 
-	header := []string{"Term", "Course Reference Number", "Subject", "Course Number", "Sequence Number", "Course Title", "Display Name", "Begin Time", "End Time", "Start Date", "End Date", "Meeting Type", "Room", "Maximum Enrollment", "Enrollment", "Seats Available", "Waitlist Available"}
-	fmt.Printf("Writing %s\n", fileName)
-	err = writer.Write(header)
-	if err != nil {
-		return err
-	}
+var courseColumns = []export.Column{
+	{Name: "Term", Key: "term"},
+	{Name: "Course Reference Number", Key: "crn"},
+	{Name: "Subject", Key: "subject"},
+	{Name: "Course Number", Key: "course_number"},
+	{Name: "Sequence Number", Key: "sequence_number"},
+	{Name: "Course Title", Key: "course_title"},
+	{Name: "Display Name", Key: "instructor"},
+	{Name: "Begin Time", Key: "begin_time"},
+	{Name: "End Time", Key: "end_time"},
+	{Name: "Start Date", Key: "start_date"},
+	{Name: "End Date", Key: "end_date"},
+	{Name: "Meeting Type", Key: "meeting_type"},
+	{Name: "Room", Key: "room"},
+	{Name: "Maximum Enrollment", Key: "maximum_enrollment"},
+	{Name: "Enrollment", Key: "enrollment"},
+	{Name: "Seats Available", Key: "seats_available"},
+	{Name: "Waitlist Available", Key: "wait_available"},
+	{Name: "Link Group", Key: "link_group"},
+	{Name: "Linked CRNs", Key: "linked_crns"},
+}
+
+func (t *Task) ExportCourseData(courses []CourseInfo) error {
+	dataset := export.Dataset{Name: "courses", Columns: courseColumns}
 	for _, course := range courses {
-		record := []string{
+		dataset.Rows = append(dataset.Rows, []any{
 			course.TermDesc,
 			course.CourseReferenceNumber,
 			course.Subject,
@@ -124,23 +271,25 @@ func (t *Task) ExportCourseData(courses []CourseInfo) error {
 			course.EndDate,
 			course.MeetingType,
 			course.Room,
-			strconv.Itoa(course.MaximumEnrollment),
-			strconv.Itoa(course.Enrollment),
-			strconv.Itoa(course.SeatsAvailable),
-			strconv.Itoa(course.WaitAvailable),
-		}
-		err = writer.Write(record)
-		if err != nil {
-			return err
-		}
+			course.MaximumEnrollment,
+			course.Enrollment,
+			course.SeatsAvailable,
+			course.WaitAvailable,
+			course.LinkGroup,
+			course.LinkedCRNs,
+		})
+	}
+	if err := t.writeExport("", dataset); err != nil {
+		return err
 	}
-	fmt.Println("Exported Search Data")
+	t.logln("Exported Search Data")
 	return nil
 }
 
-func (t *Task) Classes() error {
+func (t *Task) Classes(ctx context.Context) error {
 	t.GenSessionId()
-	t.SubmitTerm()
-	t.GetCourses()
-	return nil
+	if err := t.SubmitTerm(ctx); err != nil {
+		return err
+	}
+	return t.GetCourses(ctx)
 }
@@ -0,0 +1,186 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// meetingDateLayout is how Banner writes a meeting's start and end dates.
+const meetingDateLayout = "01/02/2006"
+
+// Conflict is two sections that meet at the same time. From and To bound
+// the dates both sections meet, so a conflict between a full-term and a
+// late-start section only covers the weeks they share; they are zero when
+// Banner gave no dates.
+type Conflict struct {
+	CRNs  [2]string
+	Days  string
+	Begin string
+	End   string
+	From  time.Time
+	To    time.Time
+}
+
+func (c Conflict) String() string {
+	overlap := fmt.Sprintf("%s and %s both meet %s %s-%s", c.CRNs[0], c.CRNs[1], c.Days, c.Begin, c.End)
+	if !c.From.IsZero() {
+		overlap += fmt.Sprintf(" from %s to %s", c.From.Format(meetingDateLayout), c.To.Format(meetingDateLayout))
+	}
+	return overlap
+}
+
+// GetMeetingTimes returns every meeting of a section, the same data the
+// class search shows under "Instructor/Meeting Times".
+func (t *Task) GetMeetingTimes(ctx context.Context, CRN string) ([]MeetingTime, error) {
+	headers := [][2]string{
+		{"accept", "application/json, text/javascript, */*; q=0.01"},
+		{"accept-language", "en-US,en;q=0.9"},
+		{"user-agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/137.0.0.0 Safari/537.36"},
+	}
+
+	values := url.Values{
+		"term":                  {t.TermID},
+		"courseReferenceNumber": {CRN},
+	}
+	response, err := t.DoReq(t.MakeReq(ctx, "GET", t.regURL("/StudentRegistrationSsb/ssb/searchResults/getFacultyMeetingTimes?"+values.Encode()), headers, nil), fmt.Sprintf("Getting Meeting Times (%s)", CRN), true)
+	if err != nil {
+		discardResp(response)
+		return nil, err
+	}
+
+	body, _ := readBody(response)
+	var meetingTimes FacultyMeetingTimes
+	if err := json.Unmarshal(body, &meetingTimes); err != nil {
+		return nil, err
+	}
+	var meetings []MeetingTime
+	for _, meeting := range meetingTimes.Fmt {
+		meetings = append(meetings, meeting.MeetingTime)
+	}
+	return meetings, nil
+}
+
+// FindConflicts compares the meetings of every pair of CRNs. Meetings with
+// no days or times, like asynchronous online sections, never conflict, and
+// a class ending at the minute another begins does not either.
+func FindConflicts(CRNs []string, meetings map[string][]MeetingTime) []Conflict {
+	var conflicts []Conflict
+	for i, first := range CRNs {
+		for _, second := range CRNs[i+1:] {
+			for _, a := range meetings[first] {
+				for _, b := range meetings[second] {
+					if conflict, ok := overlap(a, b); ok {
+						conflict.CRNs = [2]string{first, second}
+						conflicts = append(conflicts, conflict)
+					}
+				}
+			}
+		}
+	}
+	return conflicts
+}
+
+func overlap(a MeetingTime, b MeetingTime) (Conflict, bool) {
+	var conflict Conflict
+	for _, day := range meetingDays(a) {
+		if strings.ContainsRune(meetingDays(b), day) {
+			conflict.Days += string(day)
+		}
+	}
+	if conflict.Days == "" {
+		return conflict, false
+	}
+
+	aBegin, aErr := strconv.Atoi(a.BeginTime)
+	aEnd, aEndErr := strconv.Atoi(a.EndTime)
+	bBegin, bErr := strconv.Atoi(b.BeginTime)
+	bEnd, bEndErr := strconv.Atoi(b.EndTime)
+	if aErr != nil || aEndErr != nil || bErr != nil || bEndErr != nil {
+		return conflict, false
+	}
+	begin, end := max(aBegin, bBegin), min(aEnd, bEnd)
+	if begin >= end {
+		return conflict, false
+	}
+	conflict.Begin, conflict.End = fmt.Sprintf("%04d", begin), fmt.Sprintf("%04d", end)
+
+	aStart, aStartErr := time.Parse(meetingDateLayout, a.StartDate)
+	aFinish, aFinishErr := time.Parse(meetingDateLayout, a.EndDate)
+	bStart, bStartErr := time.Parse(meetingDateLayout, b.StartDate)
+	bFinish, bFinishErr := time.Parse(meetingDateLayout, b.EndDate)
+	if aStartErr != nil || aFinishErr != nil || bStartErr != nil || bFinishErr != nil {
+		// Without dates assume both run the whole term.
+		return conflict, true
+	}
+	conflict.From, conflict.To = aStart, aFinish
+	if bStart.After(aStart) {
+		conflict.From = bStart
+	}
+	if bFinish.Before(aFinish) {
+		conflict.To = bFinish
+	}
+	return conflict, meetsBetween(conflict.Days, conflict.From, conflict.To)
+}
+
+// meetsBetween reports whether any date from from to to, inclusive, falls on
+// one of days. Only a shared stretch shorter than a week can miss them all.
+func meetsBetween(days string, from time.Time, to time.Time) bool {
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		if strings.IndexByte(days, "UMTWRFS"[day.Weekday()]) >= 0 {
+			return true
+		}
+		if day.Sub(from) >= 6*24*time.Hour {
+			break
+		}
+	}
+	return false
+}
+
+// Validate fetches the meeting times of the task's CRNs and reports any that
+// overlap before anything is submitted. Conflicts are returned as a
+// *ConflictError unless OnConflict is "warn".
+func (t *Task) Validate(ctx context.Context) error {
+	return t.checkConflicts(ctx, t.CRNs)
+}
+
+// checkConflicts is Validate for any set of CRNs.
+func (t *Task) checkConflicts(ctx context.Context, CRNs []string) error {
+	if len(CRNs) < 2 {
+		return nil
+	}
+
+	meetings := make(map[string][]MeetingTime)
+	var errs []error
+	for _, CRN := range CRNs {
+		meetingTimes, err := t.GetMeetingTimes(ctx, CRN)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		meetings[CRN] = meetingTimes
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	conflicts := FindConflicts(CRNs, meetings)
+	if len(conflicts) == 0 {
+		t.logf("No Schedule Conflicts Between %s\n", strings.Join(CRNs, ", "))
+		return nil
+	}
+	t.logln("Schedule Conflicts:")
+	for _, conflict := range conflicts {
+		t.logf("  %s\n", conflict)
+	}
+	if t.OnConflict == "warn" {
+		t.logln("Continuing Despite Schedule Conflicts")
+		return nil
+	}
+	return &ConflictError{Conflicts: conflicts}
+}
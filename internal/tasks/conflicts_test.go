@@ -0,0 +1,120 @@
+package tasks
+
+import (
+	"strings"
+	"testing"
+)
+
+// meeting builds a MeetingTime on days, any of "UMTWRFS", from begin to
+// end as "HHMM". Dates are left out unless the test sets them.
+func meeting(days string, begin string, end string) MeetingTime {
+	return MeetingTime{
+		BeginTime: begin,
+		EndTime:   end,
+		Sunday:    strings.ContainsRune(days, 'U'),
+		Monday:    strings.ContainsRune(days, 'M'),
+		Tuesday:   strings.ContainsRune(days, 'T'),
+		Wednesday: strings.ContainsRune(days, 'W'),
+		Thursday:  strings.ContainsRune(days, 'R'),
+		Friday:    strings.ContainsRune(days, 'F'),
+		Saturday:  strings.ContainsRune(days, 'S'),
+	}
+}
+
+func dated(meeting MeetingTime, start string, end string) MeetingTime {
+	meeting.StartDate, meeting.EndDate = start, end
+	return meeting
+}
+
+func TestFindConflicts(t *testing.T) {
+	tests := []struct {
+		name     string
+		meetings map[string][]MeetingTime
+		want     []string
+	}{
+		{
+			name: "overlapping",
+			meetings: map[string][]MeetingTime{
+				"1": {meeting("MW", "0930", "1045")},
+				"2": {meeting("MW", "1000", "1150")},
+			},
+			want: []string{"1 and 2 both meet MW 1000-1045"},
+		},
+		{
+			name: "only shared days count",
+			meetings: map[string][]MeetingTime{
+				"1": {meeting("MTW", "0930", "1045")},
+				"2": {meeting("WF", "0900", "1000")},
+			},
+			want: []string{"1 and 2 both meet W 0930-1000"},
+		},
+		{
+			name: "back to back",
+			meetings: map[string][]MeetingTime{
+				"1": {meeting("MW", "0930", "1045")},
+				"2": {meeting("MW", "1045", "1200")},
+			},
+		},
+		{
+			name: "different days",
+			meetings: map[string][]MeetingTime{
+				"1": {meeting("MW", "0930", "1045")},
+				"2": {meeting("TR", "0930", "1045")},
+			},
+		},
+		{
+			name: "asynchronous online",
+			meetings: map[string][]MeetingTime{
+				"1": {meeting("MW", "0930", "1045")},
+				"2": {meeting("", "", "")},
+			},
+		},
+		{
+			name: "separate parts of the term",
+			meetings: map[string][]MeetingTime{
+				"1": {dated(meeting("MW", "0930", "1045"), "01/05/2026", "02/13/2026")},
+				"2": {dated(meeting("MW", "1000", "1150"), "02/16/2026", "03/27/2026")},
+			},
+		},
+		{
+			name: "shared stretch with the days in it",
+			meetings: map[string][]MeetingTime{
+				"1": {dated(meeting("MW", "0930", "1045"), "01/05/2026", "02/18/2026")},
+				"2": {dated(meeting("MW", "1000", "1150"), "02/16/2026", "03/27/2026")},
+			},
+			want: []string{"1 and 2 both meet MW 1000-1045 from 02/16/2026 to 02/18/2026"},
+		},
+		{
+			name: "shared stretch missing the days",
+			meetings: map[string][]MeetingTime{
+				"1": {dated(meeting("MW", "0930", "1045"), "01/05/2026", "02/17/2026")},
+				"2": {dated(meeting("MW", "1000", "1150"), "02/17/2026", "03/27/2026")},
+			},
+		},
+		{
+			name: "every pair",
+			meetings: map[string][]MeetingTime{
+				"1": {meeting("M", "0900", "1000")},
+				"2": {meeting("M", "0930", "1030")},
+				"3": {meeting("M", "0945", "1100")},
+			},
+			want: []string{
+				"1 and 2 both meet M 0930-1000",
+				"1 and 3 both meet M 0945-1000",
+				"2 and 3 both meet M 0945-1030",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			CRNs := []string{"1", "2", "3"}[:len(test.meetings)]
+			var got []string
+			for _, conflict := range FindConflicts(CRNs, test.meetings) {
+				got = append(got, conflict.String())
+			}
+			if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+				t.Errorf("FindConflicts = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"text/tabwriter"
+
+	"register-bot/internal/export"
+)
+
+// RegisteredCourse is one class on the student's schedule for the term.
+type RegisteredCourse struct {
+	CRN          string
+	Subject      string
+	CourseNumber string
+	Section      string
+	Title        string
+	// Status is Banner's description of the registration, e.g.
+	// "Registered" or "Waitlisted".
+	Status  string
+	Credits float64
+	// WaitlistPosition is the place in line of a waitlisted class, else 0.
+	WaitlistPosition int
+}
+
+// GetCurrentSchedule returns the classes the student is registered or
+// waitlisted in for the term, from Banner's registration history. Classes
+// that were dropped are left out.
+func (t *Task) GetCurrentSchedule(ctx context.Context) ([]RegisteredCourse, error) {
+	headers := [][2]string{
+		{"accept", "application/json, text/javascript, */*; q=0.01"},
+		{"accept-language", "en-US,en;q=0.9"},
+		{"user-agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/137.0.0.0 Safari/537.36"},
+	}
+
+	values := url.Values{"term": {t.TermID}}
+	response, err := t.DoReq(t.MakeReq(ctx, "GET", t.regURL("/StudentRegistrationSsb/ssb/registrationHistory/reset?"+values.Encode()), headers, nil), "Getting Current Schedule", true)
+	if err != nil {
+		discardResp(response)
+		return nil, err
+	}
+
+	body, _ := readBody(response)
+	var history RegistrationHistory
+	if err := json.Unmarshal(body, &history); err != nil {
+		return nil, err
+	}
+	var courses []RegisteredCourse
+	for _, registration := range history.Data.Registrations {
+		switch registration.CourseRegistrationStatusDescription {
+		case "Deleted", "Dropped", "Web Drop":
+			continue
+		}
+		courses = append(courses, RegisteredCourse{
+			CRN:              registration.CourseReferenceNumber,
+			Subject:          registration.Subject,
+			CourseNumber:     registration.CourseNumber,
+			Section:          registration.SequenceNumber,
+			Title:            registration.CourseTitle,
+			Status:           registration.CourseRegistrationStatusDescription,
+			Credits:          registration.CreditHour,
+			WaitlistPosition: registration.WaitlistPosition,
+		})
+	}
+	return courses, nil
+}
+
+var currentColumns = []export.Column{
+	{Name: "Course Reference Number", Key: "crn"},
+	{Name: "Course", Key: "course"},
+	{Name: "Title", Key: "title"},
+	{Name: "Status", Key: "status"},
+	{Name: "Credits", Key: "credits"},
+	{Name: "Waitlist Position", Key: "waitlist_position"},
+}
+
+// ShowCurrentSchedule logs in and lists the classes on the student's
+// schedule for the term, exporting them when Output or Format is set.
+func (t *Task) ShowCurrentSchedule(ctx context.Context) error {
+	t.HomepageURL = t.regURL("/StudentRegistrationSsb/saml/login")
+	defer t.Client.CloseIdleConnections()
+
+	if err := t.CheckAuthSession(ctx); err != nil {
+		return err
+	}
+	courses, err := t.GetCurrentSchedule(ctx)
+	if err != nil {
+		return err
+	}
+	if len(courses) == 0 {
+		t.logf("Not Registered in Any Classes for %s\n", t.TermID)
+		return nil
+	}
+
+	dataset := export.Dataset{Name: "current", Columns: currentColumns}
+	table := tabwriter.NewWriter(t.logWriter(), 0, 0, 2, ' ', 0)
+	var credits float64
+	for _, course := range courses {
+		name := fmt.Sprintf("%s %s-%s", course.Subject, course.CourseNumber, course.Section)
+		status := course.Status
+		if course.WaitlistPosition > 0 {
+			status = fmt.Sprintf("%s (#%d)", course.Status, course.WaitlistPosition)
+		}
+		fmt.Fprintf(table, "  %s\t%s\t%s\t%s\t%g credit(s)\n", course.CRN, name, course.Title, status, course.Credits)
+		dataset.Rows = append(dataset.Rows, []any{course.CRN, name, course.Title, course.Status, course.Credits, course.WaitlistPosition})
+		if course.Status == "Registered" {
+			credits += course.Credits
+		}
+	}
+	t.logf("Current Schedule for %s\n", t.TermID)
+	table.Flush()
+	t.logf("%g Registered Credit(s)\n", credits)
+
+	if t.Output == "" && t.Format == "" {
+		return nil
+	}
+	return t.writeExport("current-", dataset)
+}
+
+// ReconcilePlan is what it takes to turn the current schedule into the
+// wanted one: CRNs to register in, to join the waitlist of, and to drop.
+// CRNs already registered or waitlisted are kept as they are.
+type ReconcilePlan struct {
+	Adds     []string
+	Waitlist []string
+	Drops    []string
+	Keep     []RegisteredCourse
+}
+
+// Empty reports whether the schedule already matches.
+func (p ReconcilePlan) Empty() bool {
+	return len(p.Adds) == 0 && len(p.Waitlist) == 0 && len(p.Drops) == 0
+}
+
+// PlanReconcile compares the wanted CRNs with the current schedule. A wanted
+// CRN is waitlisted instead of added when enrollment shows it has no seats
+// but a waitlist spot; enrollment is keyed by CRN and may leave CRNs out.
+func PlanReconcile(wanted []string, current []RegisteredCourse, enrollment map[string]Enrollment) ReconcilePlan {
+	var plan ReconcilePlan
+	have := make(map[string]bool)
+	for _, course := range current {
+		have[course.CRN] = true
+		if slices.Contains(wanted, course.CRN) {
+			plan.Keep = append(plan.Keep, course)
+		} else {
+			plan.Drops = append(plan.Drops, course.CRN)
+		}
+	}
+	for _, CRN := range wanted {
+		if have[CRN] {
+			continue
+		}
+		if seats, ok := enrollment[CRN]; ok && !seats.HasSeat() && seats.HasWaitlistSpot() {
+			plan.Waitlist = append(plan.Waitlist, CRN)
+		} else {
+			plan.Adds = append(plan.Adds, CRN)
+		}
+	}
+	return plan
+}
+
+// Reconcile makes the schedule match the task's CRNs: linked sections and
+// conflicts are checked as for Signup, then only the CRNs missing from the
+// current schedule are added or waitlisted and the classes not wanted are
+// dropped, all in one batch. With DryRun the batch is rehearsed instead.
+func (t *Task) Reconcile(ctx context.Context) error {
+	t.HomepageURL = t.regURL("/StudentRegistrationSsb/saml/login")
+	defer t.Client.CloseIdleConnections()
+
+	if err := t.CheckSignup(ctx); err != nil {
+		return err
+	}
+	if err := t.CheckAuthSession(ctx); err != nil {
+		return err
+	}
+	current, err := t.GetCurrentSchedule(ctx)
+	if err != nil {
+		return err
+	}
+	enrollment := make(map[string]Enrollment)
+	for _, CRN := range t.CRNs {
+		if slices.ContainsFunc(current, func(course RegisteredCourse) bool { return course.CRN == CRN }) {
+			continue
+		}
+		seats, err := t.CheckEnrollmentData(ctx, CRN)
+		if err != nil {
+			return err
+		}
+		t.recordEnrollment(seats)
+		enrollment[CRN] = seats
+	}
+
+	plan := PlanReconcile(t.CRNs, current, enrollment)
+	for _, course := range plan.Keep {
+		t.logf("[%s] - Keeping (%s)\n", course.CRN, course.Status)
+	}
+	for _, CRN := range plan.Adds {
+		t.logf("[%s] - Will Register (RW)\n", CRN)
+	}
+	for _, CRN := range plan.Waitlist {
+		t.logf("[%s] - Will Waitlist (WL)\n", CRN)
+	}
+	for _, CRN := range plan.Drops {
+		t.logf("[%s] - Will Drop (DW)\n", CRN)
+	}
+	if plan.Empty() {
+		t.logln("Schedule Already Matches")
+		return nil
+	}
+
+	registration := &Registration{
+		CRNs:         append(append([]string(nil), plan.Adds...), plan.Waitlist...),
+		DropCRNs:     plan.Drops,
+		WaitlistCRNs: plan.Waitlist,
+	}
+	if t.DryRun {
+		return t.rehearse(ctx, registration)
+	}
+	return t.register(ctx, registration)
+}
@@ -0,0 +1,61 @@
+package tasks
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPlanReconcile(t *testing.T) {
+	full := Enrollment{SeatsAvailable: 0, WaitlistCapacity: 10, WaitlistActual: 3, WaitlistSeatsAvailable: 7}
+	closed := Enrollment{SeatsAvailable: 0, WaitlistCapacity: 10, WaitlistActual: 10}
+	open := Enrollment{SeatsAvailable: 5}
+	tests := []struct {
+		name       string
+		wanted     []string
+		current    []string
+		enrollment map[string]Enrollment
+		adds       []string
+		waitlist   []string
+		drops      []string
+		keep       []string
+	}{
+		{name: "already matches", wanted: []string{"1", "2"}, current: []string{"2", "1"}, keep: []string{"2", "1"}},
+		{name: "empty schedule", wanted: []string{"1", "2"}, adds: []string{"1", "2"}},
+		{name: "drop everything", current: []string{"1", "2"}, drops: []string{"1", "2"}},
+		{
+			name:       "swap one",
+			wanted:     []string{"1", "3"},
+			current:    []string{"1", "2"},
+			enrollment: map[string]Enrollment{"3": open},
+			adds:       []string{"3"},
+			drops:      []string{"2"},
+			keep:       []string{"1"},
+		},
+		{
+			name:       "full with a waitlist",
+			wanted:     []string{"1", "2", "3", "4"},
+			enrollment: map[string]Enrollment{"1": full, "2": closed, "3": open},
+			adds:       []string{"2", "3", "4"},
+			waitlist:   []string{"1"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var current []RegisteredCourse
+			for _, CRN := range test.current {
+				current = append(current, RegisteredCourse{CRN: CRN, Status: "Registered"})
+			}
+			plan := PlanReconcile(test.wanted, current, test.enrollment)
+			var keep []string
+			for _, course := range plan.Keep {
+				keep = append(keep, course.CRN)
+			}
+			if !slices.Equal(plan.Adds, test.adds) || !slices.Equal(plan.Waitlist, test.waitlist) || !slices.Equal(plan.Drops, test.drops) || !slices.Equal(keep, test.keep) {
+				t.Errorf("PlanReconcile = adds %v, waitlist %v, drops %v, keep %v; want %v, %v, %v, %v", plan.Adds, plan.Waitlist, plan.Drops, keep, test.adds, test.waitlist, test.drops, test.keep)
+			}
+			if empty := len(test.adds)+len(test.waitlist)+len(test.drops) == 0; plan.Empty() != empty {
+				t.Errorf("Empty() = %v, want %v", plan.Empty(), empty)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxNotificationLength keeps a diff inside a Discord embed description.
+const maxNotificationLength = 4000
+
+// SectionChange is one field of a section that differs between snapshots.
+type SectionChange struct {
+	CRN    string
+	Course string
+	Field  string
+	Old    string
+	New    string
+}
+
+// CatalogDiff is what changed in a term's catalog between two snapshots.
+type CatalogDiff struct {
+	Term    string
+	From    time.Time
+	To      time.Time
+	Added   []CourseSection
+	Removed []CourseSection
+	Changed []SectionChange
+}
+
+func LoadCatalogSnapshot(path string) (CatalogSnapshot, error) {
+	var snapshot CatalogSnapshot
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return snapshot, err
+	}
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return snapshot, fmt.Errorf("%s: %w", path, err)
+	}
+	return snapshot, nil
+}
+
+// DiffCatalogs compares an earlier snapshot of a term with a later one.
+// Sections are matched by CRN and compared on meeting times, rooms,
+// instructors, capacity and instructional method.
+func DiffCatalogs(from CatalogSnapshot, to CatalogSnapshot) (CatalogDiff, error) {
+	diff := CatalogDiff{Term: to.Term, From: from.TakenAt, To: to.TakenAt}
+	if from.Term != to.Term {
+		return diff, fmt.Errorf("snapshots are for different terms (%s and %s)", from.Term, to.Term)
+	}
+
+	before := make(map[string]CourseSection, len(from.Sections))
+	for _, section := range from.Sections {
+		before[section.CourseReferenceNumber] = section
+	}
+	after := make(map[string]CourseSection, len(to.Sections))
+	for _, section := range to.Sections {
+		after[section.CourseReferenceNumber] = section
+	}
+
+	for _, section := range to.Sections {
+		previous, ok := before[section.CourseReferenceNumber]
+		if !ok {
+			diff.Added = append(diff.Added, section)
+			continue
+		}
+		for _, field := range sectionFields {
+			oldValue, newValue := field.value(previous), field.value(section)
+			if oldValue != newValue {
+				diff.Changed = append(diff.Changed, SectionChange{
+					CRN:    section.CourseReferenceNumber,
+					Course: courseName(section),
+					Field:  field.name,
+					Old:    oldValue,
+					New:    newValue,
+				})
+			}
+		}
+	}
+	for _, section := range from.Sections {
+		if _, ok := after[section.CourseReferenceNumber]; !ok {
+			diff.Removed = append(diff.Removed, section)
+		}
+	}
+
+	byCRN := func(sections []CourseSection) {
+		sort.Slice(sections, func(i, j int) bool {
+			return sections[i].CourseReferenceNumber < sections[j].CourseReferenceNumber
+		})
+	}
+	byCRN(diff.Added)
+	byCRN(diff.Removed)
+	sort.SliceStable(diff.Changed, func(i, j int) bool {
+		return diff.Changed[i].CRN < diff.Changed[j].CRN
+	})
+	return diff, nil
+}
+
+var sectionFields = []struct {
+	name  string
+	value func(CourseSection) string
+}{
+	{"Meeting Time", meetingSummary},
+	{"Room", func(section CourseSection) string {
+		var rooms []string
+		for _, meeting := range section.MeetingsFaculty {
+			if meeting.MeetingTime.Room != "" {
+				rooms = append(rooms, strings.TrimSpace(meeting.MeetingTime.Building+" "+meeting.MeetingTime.Room))
+			}
+		}
+		return joinOrNone(rooms)
+	}},
+	{"Instructor", instructorNames},
+	{"Capacity", func(section CourseSection) string {
+		return strconv.Itoa(section.MaximumEnrollment)
+	}},
+	{"Method", func(section CourseSection) string {
+		return section.InstructionalMethodDescription
+	}},
+}
+
+// meetingSummary lists a section's meetings as days and times, e.g.
+// "MW 0930-1045".
+func meetingSummary(section CourseSection) string {
+	var meetings []string
+	for _, meeting := range section.MeetingsFaculty {
+		meetingTime := meeting.MeetingTime
+		days := meetingDays(meetingTime)
+		if days == "" && meetingTime.BeginTime == "" {
+			continue
+		}
+		meetings = append(meetings, strings.TrimSpace(fmt.Sprintf("%s %s-%s", days, meetingTime.BeginTime, meetingTime.EndTime)))
+	}
+	return joinOrNone(meetings)
+}
+
+func instructorNames(section CourseSection) string {
+	var instructors []string
+	for _, faculty := range section.Faculty {
+		instructors = append(instructors, faculty.DisplayName)
+	}
+	return joinOrNone(instructors)
+}
+
+func joinOrNone(values []string) string {
+	if len(values) == 0 {
+		return "none"
+	}
+	return strings.Join(values, "; ")
+}
+
+func courseName(section CourseSection) string {
+	return fmt.Sprintf("%s %s-%s %s", section.Subject, section.CourseNumber, section.SequenceNumber, section.CourseTitle)
+}
+
+func (d CatalogDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// String renders the diff as a plain-text report, one line per change.
+func (d CatalogDiff) String() string {
+	var report strings.Builder
+	fmt.Fprintf(&report, "Term %s: %s -> %s\n", d.Term, d.From.Local().Format(time.RFC1123), d.To.Local().Format(time.RFC1123))
+	if d.Empty() {
+		report.WriteString("No changes\n")
+		return report.String()
+	}
+	fmt.Fprintf(&report, "%d added, %d removed, %d change(s)\n", len(d.Added), len(d.Removed), len(d.Changed))
+	for _, section := range d.Added {
+		fmt.Fprintf(&report, "+ [%s] %s\n", section.CourseReferenceNumber, courseName(section))
+	}
+	for _, section := range d.Removed {
+		fmt.Fprintf(&report, "- [%s] %s\n", section.CourseReferenceNumber, courseName(section))
+	}
+	for _, change := range d.Changed {
+		fmt.Fprintf(&report, "~ [%s] %s: %s %s -> %s\n", change.CRN, change.Course, change.Field, change.Old, change.New)
+	}
+	return report.String()
+}
+
+// NotifyDiff sends the diff through the task's webhooks, cut short to fit in
+// one notification.
+func (t *Task) NotifyDiff(ctx context.Context, diff CatalogDiff) error {
+	message := diff.String()
+	if len(message) > maxNotificationLength {
+		cut := strings.LastIndex(message[:maxNotificationLength], "\n")
+		if cut < 0 {
+			cut = maxNotificationLength
+		}
+		message = message[:cut] + "\n... (truncated)"
+	}
+	return t.SendNotification(ctx, "Catalog Changes", message)
+}
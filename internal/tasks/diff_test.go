@@ -0,0 +1,91 @@
+package tasks
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestDiffCatalogs(t *testing.T) {
+	withRoom := func(section CourseSection, room string) CourseSection {
+		section.MeetingsFaculty[0].MeetingTime.Building, section.MeetingsFaculty[0].MeetingTime.Room = "S", room
+		return section
+	}
+	withCapacity := func(section CourseSection, capacity int) CourseSection {
+		section.MaximumEnrollment = capacity
+		return section
+	}
+	calculus := withCapacity(withRoom(testSection("41846", "MATH 1C", 5, meeting("MW", "0930", "1045")), "44"), 40)
+	english := testSection("38894", "ENGL 1B", 5, meeting("W", "1330", "1520"))
+	discrete := testSection("45210", "MATH 22", 5, meeting("MW", "1000", "1150"))
+	online := testSection("44412", "MATH 1C", 5, meeting("", "", ""))
+
+	tests := []struct {
+		name    string
+		from    []CourseSection
+		to      []CourseSection
+		added   []string
+		removed []string
+		changed []string
+	}{
+		{name: "unchanged", from: []CourseSection{calculus, english}, to: []CourseSection{english, calculus}},
+		{
+			name:    "added and removed",
+			from:    []CourseSection{calculus, english},
+			to:      []CourseSection{discrete, calculus, online},
+			added:   []string{"44412", "45210"},
+			removed: []string{"38894"},
+		},
+		{
+			name: "changed fields",
+			from: []CourseSection{calculus, english},
+			to: []CourseSection{
+				withCapacity(withRoom(testSection("41846", "MATH 1C", 0, meeting("TR", "0930", "1045")), "46"), 45),
+				english,
+			},
+			changed: []string{
+				"41846 Meeting Time: MW 0930-1045 -> TR 0930-1045",
+				"41846 Room: S 44 -> S 46",
+				"41846 Capacity: 40 -> 45",
+			},
+		},
+		{
+			name:    "went online",
+			from:    []CourseSection{english},
+			to:      []CourseSection{testSection("38894", "ENGL 1B", 5, meeting("", "", ""))},
+			changed: []string{"38894 Meeting Time: W 1330-1520 -> none"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			from := CatalogSnapshot{Term: "202632", TakenAt: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), Sections: test.from}
+			to := CatalogSnapshot{Term: "202632", TakenAt: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC), Sections: test.to}
+			diff, err := DiffCatalogs(from, to)
+			if err != nil {
+				t.Fatalf("DiffCatalogs: %v", err)
+			}
+			crns := func(sections []CourseSection) []string {
+				var crns []string
+				for _, section := range sections {
+					crns = append(crns, section.CourseReferenceNumber)
+				}
+				return crns
+			}
+			var changed []string
+			for _, change := range diff.Changed {
+				changed = append(changed, fmt.Sprintf("%s %s: %s -> %s", change.CRN, change.Field, change.Old, change.New))
+			}
+			if !slices.Equal(crns(diff.Added), test.added) || !slices.Equal(crns(diff.Removed), test.removed) || !slices.Equal(changed, test.changed) {
+				t.Errorf("DiffCatalogs = added %v, removed %v, changed %q; want %v, %v, %q", crns(diff.Added), crns(diff.Removed), changed, test.added, test.removed, test.changed)
+			}
+			if empty := test.added == nil && test.removed == nil && test.changed == nil; diff.Empty() != empty {
+				t.Errorf("Empty() = %v, want %v", diff.Empty(), empty)
+			}
+		})
+	}
+
+	if _, err := DiffCatalogs(CatalogSnapshot{Term: "202632"}, CatalogSnapshot{Term: "202642"}); err == nil {
+		t.Error("DiffCatalogs compared snapshots of different terms")
+	}
+}
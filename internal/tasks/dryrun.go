@@ -0,0 +1,79 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"text/tabwriter"
+)
+
+// ClearWorksheet removes everything AddCourse and DropCourse queued on the
+// registration worksheet without submitting it.
+func (t *Task) ClearWorksheet(ctx context.Context) error {
+	headers := [][2]string{
+		{"accept", "*/*"},
+		{"accept-language", "en-US,en;q=0.9"},
+		{"user-agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/137.0.0.0 Safari/537.36"},
+	}
+
+	response, err := t.DoReq(t.MakeReq(ctx, "POST", t.regURL("/StudentRegistrationSsb/ssb/classRegistration/resetDataForm"), headers, nil), "Clearing Worksheet", true)
+	if err != nil {
+		discardResp(response)
+		return err
+	}
+	discardResp(response)
+	return nil
+}
+
+// rehearse goes through a signup attempt like register, checking
+// eligibility and every CRN and queueing the worksheet models, but prints
+// the batch it would submit and clears the worksheet instead. The worksheet
+// is cleared however queueing ends, so a dry run never leaves sections on it.
+func (t *Task) rehearse(ctx context.Context, registration *Registration) (err error) {
+	if err := t.CheckAuthSession(ctx); err != nil {
+		return err
+	}
+	if err := t.GetRegistrationStatus(ctx); err != nil {
+		return err
+	}
+	if err := t.VisitClassRegistration(ctx); err != nil {
+		return err
+	}
+	var errs []error
+	for _, CRN := range append(append([]string(nil), registration.CRNs...), registration.DropCRNs...) {
+		if err := t.CheckCRN(ctx, CRN); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	defer func() {
+		err = errors.Join(err, t.ClearWorksheet(context.WithoutCancel(ctx)))
+	}()
+	queueErr := t.AddCourses(ctx, registration)
+	if queueErr != nil && !errors.Is(queueErr, ErrCRNRejected) {
+		return queueErr
+	}
+	if len(registration.Models) == 0 {
+		t.logln("Dry Run: Nothing Would Be Submitted")
+	} else {
+		t.logln("Dry Run: Would Submit")
+		table := tabwriter.NewWriter(t.logWriter(), 0, 0, 2, ' ', 0)
+		for _, model := range registration.Models {
+			fmt.Fprintf(table, "  %s\t%s\t%s %s-%s\t%s\n", modelField(model, "selectedAction"), modelField(model, "courseReferenceNumber"), modelField(model, "subject"), modelField(model, "courseNumber"), modelField(model, "sequenceNumber"), modelField(model, "courseTitle"))
+		}
+		table.Flush()
+	}
+	return queueErr
+}
+
+// modelField formats one field of a worksheet model, or "" if it is missing.
+func modelField(model map[string]interface{}, key string) string {
+	value, ok := model[key]
+	if !ok || value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,80 @@
+package tasks
+
+import (
+	"strings"
+
+	http "github.com/bogdanfinn/fhttp"
+)
+
+// HTTPClient is the transport every Task step sends its requests through.
+// tls_client.HttpClient satisfies it, and so does the client handed out by
+// the offline fake server in internal/fakebanner.
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+	CloseIdleConnections()
+}
+
+// Endpoints holds the base URL (scheme and host, no trailing slash) of every
+// service the bot talks to. Empty fields fall back to DefaultEndpoints.
+type Endpoints struct {
+	Registration string
+	SSO          string
+	EIS          string
+	DegreeWorks  string
+}
+
+var DefaultEndpoints = Endpoints{
+	Registration: "https://reg.oci.fhda.edu",
+	SSO:          "https://ssoshib.fhda.edu",
+	EIS:          "https://eis-prod.ec.fhda.edu",
+	DegreeWorks:  "https://dw-prod.ec.fhda.edu",
+}
+
+// SingleHostEndpoints points every service at the same base URL, which is how
+// the fake server is addressed.
+func SingleHostEndpoints(base string) Endpoints {
+	base = strings.TrimRight(base, "/")
+	return Endpoints{
+		Registration: base,
+		SSO:          base,
+		EIS:          base,
+		DegreeWorks:  base,
+	}
+}
+
+// Or fills every empty field of e from fallback.
+func (e Endpoints) Or(fallback Endpoints) Endpoints {
+	if e.Registration == "" {
+		e.Registration = fallback.Registration
+	}
+	if e.SSO == "" {
+		e.SSO = fallback.SSO
+	}
+	if e.EIS == "" {
+		e.EIS = fallback.EIS
+	}
+	if e.DegreeWorks == "" {
+		e.DegreeWorks = fallback.DegreeWorks
+	}
+	return e
+}
+
+func (e Endpoints) withDefaults() Endpoints {
+	return e.Or(DefaultEndpoints)
+}
+
+func (t *Task) regURL(path string) string {
+	return t.Endpoints.withDefaults().Registration + path
+}
+
+func (t *Task) ssoURL(path string) string {
+	return t.Endpoints.withDefaults().SSO + path
+}
+
+func (t *Task) eisURL(path string) string {
+	return t.Endpoints.withDefaults().EIS + path
+}
+
+func (t *Task) dwURL(path string) string {
+	return t.Endpoints.withDefaults().DegreeWorks + path
+}
@@ -0,0 +1,131 @@
+package tasks
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrBadSession         = errors.New("bad SSO session")
+	ErrNotLoggedIn        = errors.New("not logged in")
+	ErrRegistrationClosed = errors.New("registration is closed")
+	ErrCRNRejected        = errors.New("CRN rejected")
+	ErrNothingToSubmit    = errors.New("no courses to add or drop")
+	ErrScheduleConflict   = errors.New("schedule conflict")
+	ErrLinkedSection      = errors.New("linked section required")
+	ErrSwapRollback       = errors.New("dropped class could not be re-added")
+	ErrLoginChallenge     = errors.New("SSO login needs another step")
+)
+
+// EligibilityError carries the studentEligFailures Banner returned when
+// registration is not open for this student.
+type EligibilityError struct {
+	Failures []string
+}
+
+func (e *EligibilityError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrRegistrationClosed, strings.Join(e.Failures, "; "))
+}
+
+func (e *EligibilityError) Unwrap() error {
+	return ErrRegistrationClosed
+}
+
+// CRNError reports a CRN that could not be added to the worksheet or that
+// the registration batch refused.
+type CRNError struct {
+	CRN      string
+	Messages []string
+}
+
+func (e *CRNError) Error() string {
+	return fmt.Sprintf("%s (%s): %s", ErrCRNRejected, e.CRN, strings.Join(e.Messages, "; "))
+}
+
+func (e *CRNError) Unwrap() error {
+	return ErrCRNRejected
+}
+
+// ConflictError lists the overlapping meetings that stopped a signup.
+type ConflictError struct {
+	Conflicts []Conflict
+}
+
+func (e *ConflictError) Error() string {
+	conflicts := make([]string, len(e.Conflicts))
+	for i, conflict := range e.Conflicts {
+		conflicts[i] = conflict.String()
+	}
+	return fmt.Sprintf("%s: %s", ErrScheduleConflict, strings.Join(conflicts, "; "))
+}
+
+func (e *ConflictError) Unwrap() error {
+	return ErrScheduleConflict
+}
+
+// LinkError reports a CRN, such as a lecture, that needs one of several
+// linked sections, such as its labs, and was given none of them.
+type LinkError struct {
+	CRN     string
+	Options [][]string
+}
+
+func (e *LinkError) Error() string {
+	return fmt.Sprintf("%s (%s): add %s", ErrLinkedSection, e.CRN, formatOptions(e.Options))
+}
+
+func (e *LinkError) Unwrap() error {
+	return ErrLinkedSection
+}
+
+// SwapError reports a safe swap whose add failed after Banner had already
+// dropped the class it was replacing, and the attempt to re-add that class
+// failed too.
+type SwapError struct {
+	CRN     string
+	DropCRN string
+	Err     error
+}
+
+func (e *SwapError) Error() string {
+	return fmt.Sprintf("%s (%s, replaced by %s): %v", ErrSwapRollback, e.DropCRN, e.CRN, e.Err)
+}
+
+func (e *SwapError) Unwrap() []error {
+	return []error{ErrSwapRollback, e.Err}
+}
+
+// ChallengeError reports a step the IdP put between the password and the
+// SAMLResponse that login could not get through: a second factor, an
+// attribute release consent, an expired password, or a page it does not
+// recognize. Execution is the Shibboleth flow state, e.g. "e1s2".
+type ChallengeError struct {
+	Kind      string
+	Execution string
+	Message   string
+	Err       error
+}
+
+func (e *ChallengeError) Error() string {
+	message := fmt.Sprintf("%s (%s", ErrLoginChallenge, e.Kind)
+	if e.Execution != "" {
+		message += " at " + e.Execution
+	}
+	message += ")"
+	if e.Message != "" {
+		message += ": " + e.Message
+	}
+	if e.Err != nil {
+		message += ": " + e.Err.Error()
+	}
+	return message
+}
+
+func (e *ChallengeError) Unwrap() []error {
+	if e.Err == nil {
+		return []error{ErrLoginChallenge}
+	}
+	return []error{ErrLoginChallenge, e.Err}
+}
@@ -0,0 +1,554 @@
+package tasks_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	http "github.com/bogdanfinn/fhttp"
+	"github.com/bogdanfinn/fhttp/cookiejar"
+
+	"register-bot/internal/fakebanner"
+	"register-bot/internal/tasks"
+)
+
+const testTerm = "2026 Winter De Anza"
+
+// testLog sends a task's log to the test's, so it is shown when a test fails.
+type testLog struct{ t *testing.T }
+
+func (l testLog) Write(p []byte) (int, error) {
+	l.t.Log(strings.TrimRight(string(p), "\n"))
+	return len(p), nil
+}
+
+// newServer starts a fake Banner with the demo catalog, enrolled in the
+// given CRNs, and stops it when the test ends.
+func newServer(t *testing.T, enrolled ...string) *fakebanner.Server {
+	t.Helper()
+	server, err := fakebanner.New(fakebanner.DemoSections()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+	for _, CRN := range enrolled {
+		server.Enroll(CRN, "Registered")
+	}
+	return server
+}
+
+// newTask returns a task for mode against server, with its term looked up.
+func newTask(t *testing.T, server *fakebanner.Server, mode string, CRNs ...string) *tasks.Task {
+	t.Helper()
+	task := &tasks.Task{
+		Log:         testLog{t},
+		Endpoints:   server.Endpoints(),
+		Client:      server.Client(),
+		Username:    "student",
+		Password:    "secret",
+		Mode:        mode,
+		CRNs:        CRNs,
+		RetryPolicy: &tasks.RetryPolicy{MaxAttempts: 1},
+	}
+	if err := task.GetTermByName(context.Background(), testTerm); err != nil {
+		t.Fatal(err)
+	}
+	return task
+}
+
+func TestLogin(t *testing.T) {
+	tests := []struct {
+		name      string
+		challenge string
+		password  string
+		prompt    []string
+		wantErr   error
+		wantKind  string
+		pushes    int
+	}{
+		{name: "password", password: "secret"},
+		{name: "wrong password", password: "guess", wantErr: tasks.ErrInvalidCredentials},
+		{name: "passcode", challenge: "passcode", password: "secret", prompt: []string{"000000", "123456"}},
+		{name: "passcode without prompt", challenge: "passcode", password: "secret", wantKind: tasks.ChallengePasscode},
+		{name: "push", challenge: "push", password: "secret", pushes: 1},
+		{name: "expiring password", challenge: "expiring", password: "secret"},
+		{name: "consent", challenge: "consent", password: "secret", wantKind: tasks.ChallengeConsent},
+		{name: "expired password", challenge: "expired", password: "secret", wantKind: tasks.ChallengePasswordExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newServer(t)
+			server.Accounts = map[string]string{"student": "secret"}
+			server.Challenge = tt.challenge
+			server.Passcode = "123456"
+			task := newTask(t, server, "Status")
+			task.Password = tt.password
+			if tt.prompt != nil {
+				answers := tt.prompt
+				task.Prompt = func(label string) (string, error) {
+					if len(answers) == 0 {
+						return "", errors.New("no more answers")
+					}
+					answer := answers[0]
+					answers = answers[1:]
+					return answer, nil
+				}
+			}
+
+			_, err := task.Run(context.Background())
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Run() error = %v, want %v", err, tt.wantErr)
+				}
+			case tt.wantKind != "":
+				var challenge *tasks.ChallengeError
+				if !errors.As(err, &challenge) {
+					t.Fatalf("Run() error = %v, want a *ChallengeError", err)
+				}
+				if challenge.Kind != tt.wantKind {
+					t.Errorf("ChallengeError.Kind = %q, want %q", challenge.Kind, tt.wantKind)
+				}
+				if !errors.Is(err, tasks.ErrLoginChallenge) {
+					t.Errorf("Run() error = %v, want it to wrap ErrLoginChallenge", err)
+				}
+			case err != nil:
+				t.Fatalf("Run() error = %v", err)
+			}
+			if got := server.PushesSent("student"); got != tt.pushes {
+				t.Errorf("PushesSent() = %d, want %d", got, tt.pushes)
+			}
+		})
+	}
+}
+
+func TestSignup(t *testing.T) {
+	tests := []struct {
+		name       string
+		enrolled   []string
+		CRNs       []string
+		alternates map[string][]string
+		swaps      map[string]string
+		wantErr    error
+		registered []string
+		dropped    []string
+		schedule   map[string]string
+	}{
+		{
+			name:       "open section",
+			CRNs:       []string{"45210"},
+			registered: []string{"45210"},
+			schedule:   map[string]string{"45210": "Registered"},
+		},
+		{
+			name:     "full section",
+			CRNs:     []string{"41846"},
+			wantErr:  tasks.ErrCRNRejected,
+			schedule: map[string]string{},
+		},
+		{
+			name:       "full section with an alternate",
+			CRNs:       []string{"41846"},
+			alternates: map[string][]string{"41846": {"44412"}},
+			registered: []string{"44412"},
+			schedule:   map[string]string{"44412": "Registered"},
+		},
+		{
+			name:     "conflicting sections",
+			CRNs:     []string{"41846", "45210"},
+			wantErr:  tasks.ErrScheduleConflict,
+			schedule: map[string]string{},
+		},
+		{
+			name:     "lab whose lecture is full",
+			CRNs:     []string{"47532"},
+			wantErr:  tasks.ErrCRNRejected,
+			schedule: map[string]string{},
+		},
+		{
+			name:     "lecture with a choice of labs",
+			CRNs:     []string{"47520"},
+			wantErr:  tasks.ErrLinkedSection,
+			schedule: map[string]string{},
+		},
+		{
+			name:       "swap into an open section",
+			enrolled:   []string{"38894"},
+			CRNs:       []string{"45210"},
+			swaps:      map[string]string{"45210": "38894"},
+			registered: []string{"45210"},
+			dropped:    []string{"38894"},
+			schedule:   map[string]string{"45210": "Registered", "38894": "Deleted"},
+		},
+		{
+			name:       "swap to another section of the same course",
+			enrolled:   []string{"41846"},
+			CRNs:       []string{"44412"},
+			swaps:      map[string]string{"44412": "41846"},
+			registered: []string{"44412"},
+			dropped:    []string{"41846"},
+			schedule:   map[string]string{"44412": "Registered", "41846": "Deleted"},
+		},
+		{
+			name:       "swap into a section meeting at the same time",
+			enrolled:   []string{"41846"},
+			CRNs:       []string{"45210"},
+			swaps:      map[string]string{"45210": "41846"},
+			registered: []string{"45210"},
+			dropped:    []string{"41846"},
+			schedule:   map[string]string{"45210": "Registered", "41846": "Deleted"},
+		},
+		{
+			name:     "swap into a full section rolls back the drop",
+			enrolled: []string{"38894"},
+			CRNs:     []string{"41846"},
+			swaps:    map[string]string{"41846": "38894"},
+			wantErr:  tasks.ErrCRNRejected,
+			schedule: map[string]string{"38894": "Registered"},
+		},
+		{
+			name:       "swap into an alternate",
+			enrolled:   []string{"38894"},
+			CRNs:       []string{"41846"},
+			alternates: map[string][]string{"41846": {"44412"}},
+			swaps:      map[string]string{"41846": "38894"},
+			registered: []string{"44412"},
+			dropped:    []string{"38894"},
+			schedule:   map[string]string{"44412": "Registered", "38894": "Deleted"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newServer(t, tt.enrolled...)
+			task := newTask(t, server, "Signup", tt.CRNs...)
+			task.Alternates = tt.alternates
+			task.Swaps = tt.swaps
+			for _, drop := range tt.swaps {
+				task.DropCRNs = append(task.DropCRNs, drop)
+			}
+
+			result, err := task.Run(context.Background())
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Run() error = %v, want %v", err, tt.wantErr)
+			}
+			checkCRNs(t, "Registered", result.Registered, tt.registered)
+			checkCRNs(t, "Dropped", result.Dropped, tt.dropped)
+			checkSchedule(t, server, tt.schedule)
+		})
+	}
+}
+
+func TestDryRunClearsWorksheet(t *testing.T) {
+	tests := []struct {
+		name     string
+		CRNs     []string
+		failNext string
+		wantErr  bool
+	}{
+		{name: "queued", CRNs: []string{"45210", "44412"}},
+		{
+			name:     "queueing fails",
+			CRNs:     []string{"45210", "44412"},
+			failNext: "/StudentRegistrationSsb/ssb/classRegistration/addRegistrationItem?term=202632&courseReferenceNumber=44412&olr=false",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newServer(t)
+			task := newTask(t, server, "Signup", tt.CRNs...)
+			task.DryRun = true
+			if tt.failNext != "" {
+				server.FailNext(tt.failNext, http.StatusInternalServerError, 1, "")
+			}
+
+			_, err := task.Run(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Run() error = %v, want error: %v", err, tt.wantErr)
+			}
+			if worksheet := server.Worksheet(); len(worksheet) > 0 {
+				t.Errorf("Worksheet() = %v, want it cleared", worksheet)
+			}
+			checkSchedule(t, server, map[string]string{})
+		})
+	}
+}
+
+func TestSignupAfterCheckSignup(t *testing.T) {
+	tests := []struct {
+		name    string
+		CRNs    []string
+		wantErr bool
+	}{
+		{name: "same CRNs", CRNs: []string{"45210", "38894"}},
+		{name: "CRNs changed", CRNs: []string{"45210", "44412"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newServer(t)
+			task := newTask(t, server, "Signup", "45210", "38894")
+			if err := task.CheckSignup(context.Background()); err != nil {
+				t.Fatalf("CheckSignup() error = %v", err)
+			}
+			// Only a second look-up would see these failures.
+			server.FailNext("/StudentRegistrationSsb/ssb/searchResults/fetchLinkedSections", http.StatusInternalServerError, 1, "")
+			server.FailNext("/StudentRegistrationSsb/ssb/searchResults/getFacultyMeetingTimes", http.StatusInternalServerError, 1, "")
+			task.CRNs = tt.CRNs
+
+			_, err := task.Run(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Run() error = %v, want error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		enrolled   []string
+		CRNs       []string
+		drops      []string
+		seats      int
+		waitSeats  int
+		failNext   string
+		registered []string
+		waitlisted []string
+		dropped    []string
+		schedule   map[string]string
+	}{
+		{
+			name:       "seat opens",
+			CRNs:       []string{"41846"},
+			seats:      1,
+			registered: []string{"41846"},
+			schedule:   map[string]string{"41846": "Registered"},
+		},
+		{
+			name:       "waitlist spot opens",
+			CRNs:       []string{"41846"},
+			waitSeats:  1,
+			waitlisted: []string{"41846"},
+			schedule:   map[string]string{"41846": "Waitlisted"},
+		},
+		{
+			name:       "seat opens with a drop",
+			enrolled:   []string{"38894"},
+			CRNs:       []string{"41846"},
+			drops:      []string{"38894"},
+			seats:      1,
+			registered: []string{"41846"},
+			dropped:    []string{"38894"},
+			schedule:   map[string]string{"41846": "Registered", "38894": "Deleted"},
+		},
+		{
+			name:       "signup fails once",
+			CRNs:       []string{"41846"},
+			seats:      1,
+			failNext:   "/StudentRegistrationSsb/ssb/classRegistration/submitRegistration/batch",
+			registered: []string{"41846"},
+			schedule:   map[string]string{"41846": "Registered"},
+		},
+		{
+			name:       "linked section lookup fails once",
+			CRNs:       []string{"47520"},
+			seats:      1,
+			failNext:   "/StudentRegistrationSsb/ssb/searchResults/fetchLinkedSections",
+			registered: []string{"47520", "47532"},
+			schedule:   map[string]string{"47520": "Registered", "47532": "Registered"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newServer(t, tt.enrolled...)
+			task := newTask(t, server, "Watch", tt.CRNs...)
+			for _, CRN := range tt.CRNs {
+				server.SetSeats(CRN, 0, 0)
+			}
+			if tt.failNext != "" {
+				server.FailNext(tt.failNext, http.StatusServiceUnavailable, 1, "")
+			}
+			task.DropCRNs = tt.drops
+			task.WatchOptions = tasks.WatchOptions{
+				Interval:    20 * time.Millisecond,
+				Jitter:      -1,
+				MaxDuration: 10 * time.Second,
+			}
+
+			// Open the seat once the watch has seen the section full
+			go func() {
+				time.Sleep(100 * time.Millisecond)
+				for _, CRN := range tt.CRNs {
+					server.SetSeats(CRN, tt.seats, tt.waitSeats)
+				}
+			}()
+
+			result, err := task.Run(context.Background())
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			checkCRNs(t, "Registered", result.Registered, tt.registered)
+			checkCRNs(t, "Waitlisted", result.Waitlisted, tt.waitlisted)
+			checkCRNs(t, "Dropped", result.Dropped, tt.dropped)
+			checkSchedule(t, server, tt.schedule)
+		})
+	}
+}
+
+func TestWatchStopsAfterMaxDuration(t *testing.T) {
+	server := newServer(t)
+	task := newTask(t, server, "Watch", "41846")
+	task.WatchOptions = tasks.WatchOptions{
+		Interval:    20 * time.Millisecond,
+		Jitter:      -1,
+		MaxDuration: 200 * time.Millisecond,
+	}
+
+	start := time.Now()
+	result, err := task.Run(context.Background())
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Run() took %s, want it to stop after MaxDuration", elapsed)
+	}
+	if err != nil {
+		t.Errorf("Run() error = %v, want nil", err)
+	}
+	checkCRNs(t, "Registered", result.Registered, nil)
+	checkSchedule(t, server, map[string]string{})
+}
+
+func TestLoginWhileAnotherTaskOfTheAccountWatches(t *testing.T) {
+	server := newServer(t)
+	// Without keep-alives no shared connection pool orders the two tasks'
+	// requests, so go test -race sees them as they are
+	sessions := &tasks.SessionManager{NewClient: func() (tasks.HTTPClient, error) {
+		jar, _ := cookiejar.New(nil)
+		return &http.Client{Jar: jar, Transport: &http.Transport{DisableKeepAlives: true}}, nil
+	}}
+	account, err := sessions.Account("student")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	watcher := newTask(t, server, "Watch", "41846")
+	watcher.Client, watcher.Account = account.Client, account
+	watcher.WatchOptions = tasks.WatchOptions{
+		Interval:    5 * time.Millisecond,
+		Jitter:      -1,
+		MaxDuration: 300 * time.Millisecond,
+	}
+	done := make(chan error)
+	go func() {
+		_, err := watcher.Run(context.Background())
+		done <- err
+	}()
+
+	// Each Status task logs in again on the client the watch is polling with
+	for i := 0; i < 3; i++ {
+		server.ExpireSessions()
+		status := newTask(t, server, "Status")
+		status.Client, status.Account = account.Client, account
+		if _, err := status.Run(context.Background()); err != nil {
+			t.Errorf("Status Run() error = %v", err)
+		}
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Watch Run() error = %v", err)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	tests := []struct {
+		name       string
+		enrolled   []string
+		CRNs       []string
+		wantErr    error
+		registered []string
+		dropped    []string
+		schedule   map[string]string
+	}{
+		{
+			name:       "adds and drops",
+			enrolled:   []string{"38894"},
+			CRNs:       []string{"45210", "44412"},
+			registered: []string{"44412", "45210"},
+			dropped:    []string{"38894"},
+			schedule:   map[string]string{"45210": "Registered", "44412": "Registered", "38894": "Deleted"},
+		},
+		{
+			name:       "keeps what is already registered",
+			enrolled:   []string{"38894"},
+			CRNs:       []string{"38894", "45210"},
+			registered: []string{"45210"},
+			schedule:   map[string]string{"45210": "Registered", "38894": "Registered"},
+		},
+		{
+			name:     "already matches",
+			enrolled: []string{"38894"},
+			CRNs:     []string{"38894"},
+			schedule: map[string]string{"38894": "Registered"},
+		},
+		{
+			name:     "conflict with a kept class",
+			enrolled: []string{"41846"},
+			CRNs:     []string{"41846", "45210"},
+			wantErr:  tasks.ErrScheduleConflict,
+			schedule: map[string]string{"41846": "Registered"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newServer(t, tt.enrolled...)
+			task := newTask(t, server, "Reconcile", tt.CRNs...)
+
+			result, err := task.Run(context.Background())
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Run() error = %v, want %v", err, tt.wantErr)
+			}
+			checkCRNs(t, "Registered", result.Registered, tt.registered)
+			checkCRNs(t, "Dropped", result.Dropped, tt.dropped)
+			checkSchedule(t, server, tt.schedule)
+		})
+	}
+}
+
+// checkCRNs compares CRNs from a Result, ignoring their order.
+func checkCRNs(t *testing.T, field string, got []string, want []string) {
+	t.Helper()
+	got = slices.Clone(got)
+	want = slices.Clone(want)
+	slices.Sort(got)
+	slices.Sort(want)
+	if !slices.Equal(got, want) {
+		t.Errorf("Result.%s = %v, want %v", field, got, want)
+	}
+}
+
+// checkSchedule compares the fake server's registrations with want.
+func checkSchedule(t *testing.T, server *fakebanner.Server, want map[string]string) {
+	t.Helper()
+	got := server.Registered()
+	if len(got) != len(want) {
+		t.Errorf("Registered() = %v, want %v", got, want)
+		return
+	}
+	for CRN, status := range want {
+		if got[CRN] != status {
+			t.Errorf("Registered()[%s] = %q, want %q", CRN, got[CRN], status)
+		}
+	}
+}
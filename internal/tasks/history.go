@@ -0,0 +1,58 @@
+package tasks
+
+import (
+	"time"
+
+	"register-bot/internal/history"
+)
+
+// recordSections adds the seat counts of searched sections to the task's
+// history, if it keeps one. History is best effort and never fails a task.
+func (t *Task) recordSections(sections []CourseSection) {
+	if t.History == nil || len(sections) == 0 {
+		return
+	}
+	now := time.Now()
+	observations := make([]history.Observation, 0, len(sections))
+	for _, section := range sections {
+		observations = append(observations, history.Observation{
+			Term:              section.Term,
+			CRN:               section.CourseReferenceNumber,
+			At:                now,
+			Subject:           section.Subject,
+			CourseNumber:      section.CourseNumber,
+			SequenceNumber:    section.SequenceNumber,
+			Title:             section.CourseTitle,
+			MaximumEnrollment: section.MaximumEnrollment,
+			Enrollment:        section.Enrollment,
+			SeatsAvailable:    section.SeatsAvailable,
+			WaitCapacity:      section.WaitCapacity,
+			WaitCount:         section.WaitCount,
+			WaitAvailable:     section.WaitAvailable,
+		})
+	}
+	if err := t.History.Record(observations); err != nil {
+		t.logln("Error Recording Seat History:", err)
+	}
+}
+
+// recordEnrollment adds one Watch poll to the task's history.
+func (t *Task) recordEnrollment(enrollment Enrollment) {
+	if t.History == nil {
+		return
+	}
+	err := t.History.Record([]history.Observation{{
+		Term:              t.TermID,
+		CRN:               enrollment.CRN,
+		At:                time.Now(),
+		MaximumEnrollment: enrollment.EnrollmentMaximum,
+		Enrollment:        enrollment.EnrollmentActual,
+		SeatsAvailable:    enrollment.SeatsAvailable,
+		WaitCapacity:      enrollment.WaitlistCapacity,
+		WaitCount:         enrollment.WaitlistActual,
+		WaitAvailable:     enrollment.WaitlistSeatsAvailable,
+	}})
+	if err != nil {
+		t.logln("Error Recording Seat History:", err)
+	}
+}
@@ -0,0 +1,364 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// GetLinkedSections returns the sections a CRN must be taken with, such as a
+// lecture's labs. Each option is one set of CRNs that completes the CRN; a
+// section that isn't linked has none.
+func (t *Task) GetLinkedSections(ctx context.Context, CRN string) ([][]string, error) {
+	headers := [][2]string{
+		{"accept", "application/json, text/javascript, */*; q=0.01"},
+		{"accept-language", "en-US,en;q=0.9"},
+		{"user-agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/137.0.0.0 Safari/537.36"},
+	}
+
+	values := url.Values{
+		"term":                  {t.TermID},
+		"courseReferenceNumber": {CRN},
+	}
+	response, err := t.DoReq(t.MakeReq(ctx, "GET", t.regURL("/StudentRegistrationSsb/ssb/searchResults/fetchLinkedSections?"+values.Encode()), headers, nil), fmt.Sprintf("Getting Linked Sections (%s)", CRN), true)
+	if err != nil {
+		discardResp(response)
+		return nil, err
+	}
+
+	body, _ := readBody(response)
+	var linked LinkedSections
+	if err := json.Unmarshal(body, &linked); err != nil {
+		return nil, err
+	}
+	var options [][]string
+	for _, sections := range linked.LinkedData {
+		var option []string
+		for _, section := range sections {
+			if section.CourseReferenceNumber != CRN {
+				option = append(option, section.CourseReferenceNumber)
+			}
+		}
+		if len(option) > 0 {
+			options = append(options, option)
+		}
+	}
+	return options, nil
+}
+
+// maxLinkedRequests bounds how many fetchLinkedSections requests are made at
+// once.
+const maxLinkedRequests = 4
+
+// linkedOptions looks up the linked sections of every CRN, at most
+// maxLinkedRequests at a time, leaving out the ones that aren't linked.
+func (t *Task) linkedOptions(ctx context.Context, CRNs []string) (map[string][][]string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	found := make([][][]string, len(CRNs))
+	errs := make([]error, len(CRNs))
+	limit := make(chan struct{}, maxLinkedRequests)
+	var waitGroup sync.WaitGroup
+	for i, CRN := range CRNs {
+		waitGroup.Add(1)
+		go func(i int, CRN string) {
+			defer waitGroup.Done()
+			select {
+			case limit <- struct{}{}:
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+			defer func() { <-limit }()
+
+			found[i], errs[i] = t.GetLinkedSections(ctx, CRN)
+			if errs[i] != nil {
+				cancel()
+			}
+		}(i, CRN)
+	}
+	waitGroup.Wait()
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return nil, err
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	links := make(map[string][][]string)
+	for i, CRN := range CRNs {
+		if len(found[i]) > 0 {
+			links[CRN] = found[i]
+		}
+	}
+	return links, nil
+}
+
+// formatOptions writes linked section options as "47531 or 47532+47533".
+func formatOptions(options [][]string) string {
+	formatted := make([]string, len(options))
+	for i, option := range options {
+		formatted[i] = strings.Join(option, "+")
+	}
+	return strings.Join(formatted, " or ")
+}
+
+// sectionLinks looks up the linked sections of every section Banner marks
+// as linked.
+func (t *Task) sectionLinks(ctx context.Context, sections []CourseSection) (map[string][][]string, error) {
+	var CRNs []string
+	for _, section := range sections {
+		if section.IsSectionLinked {
+			CRNs = append(CRNs, section.CourseReferenceNumber)
+		}
+	}
+	return t.linkedOptions(ctx, CRNs)
+}
+
+// linkGroups labels each section with the earliest section it is linked to,
+// directly or through others, so a lecture and all its labs share a label.
+func linkGroups(sections []CourseSection, links map[string][][]string) map[string]string {
+	position := make(map[string]int)
+	for i, section := range sections {
+		if _, ok := position[section.CourseReferenceNumber]; !ok {
+			position[section.CourseReferenceNumber] = i
+		}
+	}
+	earlier := func(a string, b string) bool {
+		aPosition, aOK := position[a]
+		bPosition, bOK := position[b]
+		if aOK != bOK {
+			return aOK
+		}
+		if aPosition != bPosition {
+			return aPosition < bPosition
+		}
+		return a < b
+	}
+
+	parent := make(map[string]string)
+	var find func(CRN string) string
+	find = func(CRN string) string {
+		next, ok := parent[CRN]
+		if !ok || next == CRN {
+			return CRN
+		}
+		root := find(next)
+		parent[CRN] = root
+		return root
+	}
+	for CRN, options := range links {
+		for _, option := range options {
+			for _, linked := range option {
+				a, b := find(CRN), find(linked)
+				if a == b {
+					continue
+				}
+				if earlier(b, a) {
+					a, b = b, a
+				}
+				parent[b] = a
+			}
+		}
+	}
+
+	groups := make(map[string]string)
+	for _, section := range sections {
+		groups[section.CourseReferenceNumber] = find(section.CourseReferenceNumber)
+	}
+	return groups
+}
+
+// ResolveLinkedCRNs makes sure every linked CRN goes in with one of its
+// options. A CRN whose only option is missing gets it added right after
+// it; one with several options and none of them chosen is a *LinkError.
+func ResolveLinkedCRNs(CRNs []string, links map[string][][]string) ([]string, error) {
+	chosen := make(map[string]bool)
+	for _, CRN := range CRNs {
+		chosen[CRN] = true
+	}
+	complete := func(option []string) bool {
+		for _, CRN := range option {
+			if !chosen[CRN] {
+				return false
+			}
+		}
+		return true
+	}
+
+	var resolved []string
+	for _, CRN := range CRNs {
+		resolved = append(resolved, CRN)
+		options := links[CRN]
+		if len(options) == 0 {
+			continue
+		}
+		satisfied := false
+		for _, option := range options {
+			satisfied = satisfied || complete(option)
+		}
+		if satisfied {
+			continue
+		}
+		if len(options) > 1 {
+			return CRNs, &LinkError{CRN: CRN, Options: options}
+		}
+		for _, linked := range options[0] {
+			if !chosen[linked] {
+				chosen[linked] = true
+				resolved = append(resolved, linked)
+			}
+		}
+	}
+	return resolved, nil
+}
+
+// resolveLinked is ResolveLinkedCRNs, logging the linked sections it adds
+// after each CRN.
+func (t *Task) resolveLinked(CRNs []string, links map[string][][]string) ([]string, error) {
+	resolved, err := ResolveLinkedCRNs(CRNs, links)
+	if err != nil {
+		return resolved, err
+	}
+	asked := make(map[string]bool)
+	for _, CRN := range CRNs {
+		asked[CRN] = true
+	}
+	for i := 0; i < len(resolved); {
+		j := i + 1
+		for j < len(resolved) && !asked[resolved[j]] {
+			j++
+		}
+		if j > i+1 {
+			t.logf("[%s] - Adding Linked Section(s) %s\n", resolved[i], strings.Join(resolved[i+1:j], ", "))
+		}
+		i = j
+	}
+	return resolved, nil
+}
+
+// CheckSignup gets the task's CRNs ready to submit: linked sections are
+// filled in or reported, then the schedule is checked for conflicts. CRNs
+// that already passed are not checked again.
+func (t *Task) CheckSignup(ctx context.Context) error {
+	if t.checked != nil && slices.Equal(t.checked, t.CRNs) {
+		return nil
+	}
+	links, err := t.linkedOptions(ctx, t.CRNs)
+	if err != nil {
+		return err
+	}
+	CRNs, err := t.resolveLinked(t.CRNs, links)
+	if err != nil {
+		return err
+	}
+	t.CRNs = CRNs
+	if err := t.Validate(ctx); err != nil {
+		return err
+	}
+	t.checked = slices.Clone(CRNs)
+	return nil
+}
+
+// checkAlternate runs CheckSignup's checks on an alternate about to be
+// queued alongside others: its linked sections are filled in, and together
+// they must not conflict with others. It returns the alternate followed by
+// any linked sections it needs.
+func (t *Task) checkAlternate(ctx context.Context, alternate string, others []string) ([]string, error) {
+	links, err := t.linkedOptions(ctx, []string{alternate})
+	if err != nil {
+		return nil, err
+	}
+	resolved, err := t.resolveLinked(append(append([]string(nil), others...), alternate), links)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.checkConflicts(ctx, resolved); err != nil {
+		return nil, err
+	}
+	return resolved[len(others):], nil
+}
+
+// watchLinks works out which watched CRNs need a linked section. A watched
+// CRN that completes an earlier one, like a lab listed after its lecture, is
+// signed up for with it rather than watched on its own, and narrows the
+// earlier CRN's options to the ones it is part of.
+func watchLinks(CRNs []string, links map[string][][]string) ([]string, map[string][][]string) {
+	listed := make(map[string]bool)
+	for _, CRN := range CRNs {
+		listed[CRN] = true
+	}
+
+	companion := make(map[string]bool)
+	var watching []string
+	narrowed := make(map[string][][]string)
+	for _, CRN := range CRNs {
+		if companion[CRN] {
+			continue
+		}
+		watching = append(watching, CRN)
+		options := links[CRN]
+		if len(options) == 0 {
+			continue
+		}
+
+		var preferred [][]string
+		for _, option := range options {
+			for _, linked := range option {
+				if listed[linked] {
+					preferred = append(preferred, option)
+					break
+				}
+			}
+		}
+		if len(preferred) > 0 {
+			options = preferred
+		}
+		for _, option := range options {
+			for _, linked := range option {
+				if listed[linked] {
+					companion[linked] = true
+				}
+			}
+		}
+		narrowed[CRN] = options
+	}
+	return watching, narrowed
+}
+
+// openLinkedOption finds an option whose sections all have room of the kind
+// the opening offers: a seat, or a waitlist spot when waitlisting.
+func (t *Task) openLinkedOption(ctx context.Context, opening Enrollment, options [][]string) ([]string, error) {
+	checked := make(map[string]Enrollment)
+	for _, option := range options {
+		open := true
+		for _, CRN := range option {
+			enrollment, ok := checked[CRN]
+			if !ok {
+				var err error
+				enrollment, err = t.CheckEnrollmentData(ctx, CRN)
+				if err != nil {
+					return nil, err
+				}
+				t.recordEnrollment(enrollment)
+				checked[CRN] = enrollment
+			}
+			if opening.HasSeat() && !enrollment.HasSeat() || !opening.HasSeat() && !enrollment.HasWaitlistSpot() {
+				open = false
+				break
+			}
+		}
+		if open {
+			return option, nil
+		}
+	}
+	return nil, nil
+}
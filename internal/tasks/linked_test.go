@@ -0,0 +1,53 @@
+package tasks
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestResolveLinkedCRNs(t *testing.T) {
+	links := map[string][][]string{
+		// A lecture with a choice of labs
+		"47520": {{"47531"}, {"47532"}},
+		"47531": {{"47520"}},
+		"47532": {{"47520"}},
+		// A lecture with one lab and discussion that go together
+		"50000": {{"50001", "50002"}},
+	}
+	tests := []struct {
+		name    string
+		CRNs    []string
+		want    []string
+		options [][]string
+	}{
+		{name: "unlinked", CRNs: []string{"41846", "38894"}, want: []string{"41846", "38894"}},
+		{name: "lecture and lab", CRNs: []string{"47520", "47532"}, want: []string{"47520", "47532"}},
+		{name: "lab after its lecture", CRNs: []string{"47532", "47520"}, want: []string{"47532", "47520"}},
+		{name: "only option added", CRNs: []string{"47531", "41846"}, want: []string{"47531", "47520", "41846"}},
+		{name: "whole option added", CRNs: []string{"50000"}, want: []string{"50000", "50001", "50002"}},
+		{name: "part of option already chosen", CRNs: []string{"50002", "50000"}, want: []string{"50002", "50000", "50001"}},
+		{name: "several options", CRNs: []string{"47520", "41846"}, options: [][]string{{"47531"}, {"47532"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ResolveLinkedCRNs(test.CRNs, links)
+			if test.options != nil {
+				var linkError *LinkError
+				if !errors.As(err, &linkError) || !errors.Is(err, ErrLinkedSection) {
+					t.Fatalf("ResolveLinkedCRNs = %v, %v, want a *LinkError", got, err)
+				}
+				if linkError.CRN != test.CRNs[0] || len(linkError.Options) != len(test.options) {
+					t.Errorf("LinkError = %+v, want options %v for %s", linkError, test.options, test.CRNs[0])
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ResolveLinkedCRNs: %v", err)
+			}
+			if !slices.Equal(got, test.want) {
+				t.Errorf("ResolveLinkedCRNs = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,262 @@
+package tasks
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	http "github.com/bogdanfinn/fhttp"
+	"github.com/bogdanfinn/fhttp/cookiejar"
+	tls_client "github.com/bogdanfinn/tls-client"
+	"golang.org/x/crypto/scrypt"
+)
+
+// sessionFileVersion is bumped whenever the saved session format changes;
+// files of another version are ignored.
+const sessionFileVersion = 2
+
+// savedSession is what SaveSession encrypts: the login state and the
+// cookies of every host the login goes through.
+type savedSession struct {
+	Username string
+	SavedAt  time.Time
+	Session  Session
+	Cookies  []savedCookie
+}
+
+// savedCookie is a cookie as the jar returned it for URL. Path, Domain and
+// Expires are empty when the jar does not report them.
+type savedCookie struct {
+	URL     string
+	Name    string
+	Value   string
+	Path    string
+	Domain  string
+	Expires time.Time
+}
+
+// sessionFile is a saved session as written to disk. Data is the sealed
+// savedSession; the key is derived from the username and password, so the
+// file is useless without the credentials that made it.
+type sessionFile struct {
+	Version int    `json:"version"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+// cookieJar returns the cookie jar of the task's client, if it exposes one.
+func (t *Task) cookieJar() http.CookieJar {
+	mu := t.jarMu()
+	mu.RLock()
+	defer mu.RUnlock()
+	switch client := t.Client.(type) {
+	case interface{ GetCookieJar() http.CookieJar }:
+		return client.GetCookieJar()
+	case *http.Client:
+		return client.Jar
+	}
+	return nil
+}
+
+// sessionURLs are the places the login leaves cookies for.
+func (t *Task) sessionURLs() []string {
+	return []string{
+		t.regURL("/StudentRegistrationSsb/"),
+		t.ssoURL("/idp/"),
+		t.eisURL("/"),
+		t.dwURL("/responsiveDashboard/"),
+	}
+}
+
+func (t *Task) sessionCipher(salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(t.Username+"\x00"+t.Password), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// SaveSession encrypts the login state and cookies into SessionFile, so the
+// next run can pick up the session instead of logging in again. It does
+// nothing when SessionFile is empty or the client has no cookie jar.
+func (t *Task) SaveSession() error {
+	jar := t.cookieJar()
+	if t.SessionFile == "" || jar == nil {
+		return nil
+	}
+
+	saved := savedSession{Username: t.Username, SavedAt: time.Now(), Session: t.Session}
+	for _, raw := range t.sessionURLs() {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return err
+		}
+		for _, cookie := range jar.Cookies(u) {
+			saved.Cookies = append(saved.Cookies, savedCookie{
+				URL:     raw,
+				Name:    cookie.Name,
+				Value:   cookie.Value,
+				Path:    cookie.Path,
+				Domain:  cookie.Domain,
+				Expires: cookie.Expires,
+			})
+		}
+	}
+	plain, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+
+	file := sessionFile{Version: sessionFileVersion, Salt: make([]byte, 16)}
+	if _, err := rand.Read(file.Salt); err != nil {
+		return err
+	}
+	aead, err := t.sessionCipher(file.Salt)
+	if err != nil {
+		return err
+	}
+	file.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(file.Nonce); err != nil {
+		return err
+	}
+	file.Data = aead.Seal(nil, file.Nonce, plain, nil)
+	data, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so a reader never sees half a session.
+	if err := os.MkdirAll(filepath.Dir(t.SessionFile), 0o700); err != nil {
+		return err
+	}
+	temp, err := os.CreateTemp(filepath.Dir(t.SessionFile), filepath.Base(t.SessionFile)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), t.SessionFile)
+}
+
+// LoadSession restores the login state and cookies saved by SaveSession. It
+// reports false, with no error, when there is no saved session for this
+// username, or it was saved by another version or with another password.
+func (t *Task) LoadSession() (bool, error) {
+	saved, err := t.loadSession()
+	return saved != nil, err
+}
+
+func (t *Task) loadSession() (*savedSession, error) {
+	jar := t.cookieJar()
+	if t.SessionFile == "" || jar == nil {
+		return nil, nil
+	}
+	data, err := os.ReadFile(t.SessionFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var file sessionFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("reading saved session: %w", err)
+	}
+	if file.Version != sessionFileVersion {
+		return nil, nil
+	}
+	aead, err := t.sessionCipher(file.Salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(file.Nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("reading saved session: bad nonce")
+	}
+	plain, err := aead.Open(nil, file.Nonce, file.Data, nil)
+	if err != nil {
+		// Sealed with other credentials
+		return nil, nil
+	}
+	var saved savedSession
+	if err := json.Unmarshal(plain, &saved); err != nil {
+		return nil, fmt.Errorf("reading saved session: %w", err)
+	}
+	if !strings.EqualFold(saved.Username, t.Username) {
+		return nil, nil
+	}
+
+	for _, cookie := range saved.Cookies {
+		u, err := url.Parse(cookie.URL)
+		if err != nil || (!cookie.Expires.IsZero() && cookie.Expires.Before(time.Now())) {
+			continue
+		}
+		// A jar that doesn't report paths was most likely given "/"
+		path := cookie.Path
+		if path == "" {
+			path = "/"
+		}
+		jar.SetCookies(u, []*http.Cookie{{Name: cookie.Name, Value: cookie.Value, Path: path, Domain: cookie.Domain, Expires: cookie.Expires}})
+	}
+	t.sessionIDMu.Lock()
+	t.Session = saved.Session
+	t.sessionIDMu.Unlock()
+	return &saved, nil
+}
+
+// clearCookies gives the task's client an empty cookie jar, so a login
+// never carries over cookies from an expired or restored session. It waits
+// for requests already being sent on the client, which may be another
+// task's, so none of them has its cookies swapped out from under it. It
+// must be called with the login lock held.
+func (t *Task) clearCookies() {
+	mu := t.jarMu()
+	mu.Lock()
+	defer mu.Unlock()
+	switch client := t.Client.(type) {
+	case interface{ SetCookieJar(http.CookieJar) }:
+		client.SetCookieJar(tls_client.NewCookieJar())
+	case *http.Client:
+		client.Jar, _ = cookiejar.New(nil)
+	}
+}
+
+// restoreSession loads the saved session the first time the task, or its
+// account, needs to be logged in, and returns it if it did. It must be
+// called with the login lock held.
+func (t *Task) restoreSession() *savedSession {
+	restored := &t.sessionRestored
+	if t.Account != nil {
+		restored = &t.Account.restored
+	}
+	if *restored {
+		return nil
+	}
+	*restored = true
+	saved, err := t.loadSession()
+	if err != nil {
+		t.logf("Ignoring Saved Session: %v\n", err)
+	} else if saved != nil {
+		t.logln("Restored Saved Session")
+	}
+	return saved
+}
@@ -0,0 +1,91 @@
+package tasks
+
+import (
+	"context"
+	"sync"
+)
+
+// SubmitOrder makes tasks that submit at the same moment go in priority
+// order. Tasks join a slot, such as their registration time, and a task only
+// submits its first batch once every task of higher priority in its slot has
+// submitted or finished. Tasks of equal priority submit together.
+type SubmitOrder struct {
+	mu sync.Mutex
+	// pending counts, per slot and priority, the tasks yet to submit.
+	pending map[string]map[int]int
+	// changed is closed and replaced whenever a task leaves.
+	changed chan struct{}
+}
+
+// Join counts a task that will submit in slot at priority. Every task must
+// join before any of them runs, and leaves through Task.Submitted.
+func (o *SubmitOrder) Join(slot string, priority int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.pending == nil {
+		o.pending = make(map[string]map[int]int)
+		o.changed = make(chan struct{})
+	}
+	if o.pending[slot] == nil {
+		o.pending[slot] = make(map[int]int)
+	}
+	o.pending[slot][priority]++
+}
+
+// ahead reports whether a task of higher priority in slot has yet to submit,
+// and the channel closed when that may have changed.
+func (o *SubmitOrder) ahead(slot string, priority int) (bool, chan struct{}) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for other, count := range o.pending[slot] {
+		if other > priority && count > 0 {
+			return true, o.changed
+		}
+	}
+	return false, nil
+}
+
+func (o *SubmitOrder) leave(slot string, priority int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.pending[slot][priority] > 0 {
+		o.pending[slot][priority]--
+	}
+	close(o.changed)
+	o.changed = make(chan struct{})
+}
+
+// awaitSubmitTurn blocks until the task may submit its batch.
+func (t *Task) awaitSubmitTurn(ctx context.Context) error {
+	if t.SubmitOrder == nil {
+		return nil
+	}
+	logged := false
+	for {
+		waiting, changed := t.SubmitOrder.ahead(t.SubmitSlot, t.Priority)
+		if !waiting {
+			return nil
+		}
+		if !logged {
+			t.logln("Waiting for Higher Priority Tasks to Submit")
+			logged = true
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// Submitted lets lower priority tasks in the slot go ahead. Run calls it
+// when it returns; a caller that gives up on a task before running it must
+// call it too. Only the first call counts.
+func (t *Task) Submitted() {
+	if t.SubmitOrder == nil {
+		return
+	}
+	t.submitOnce.Do(func() {
+		t.SubmitOrder.leave(t.SubmitSlot, t.Priority)
+	})
+}
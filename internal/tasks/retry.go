@@ -0,0 +1,141 @@
+package tasks
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	http "github.com/bogdanfinn/fhttp"
+)
+
+// RetryPolicy decides how DoReq retries a failed request.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of tries, including the first one.
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	// Jitter spreads each delay by up to this fraction in either direction.
+	Jitter float64
+	// RetryableStatus lists the status codes worth retrying. Any other 4xx or
+	// 5xx fails immediately.
+	RetryableStatus []int
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:     5,
+	BaseDelay:       2 * time.Second,
+	MaxDelay:        30 * time.Second,
+	Jitter:          0.2,
+	RetryableStatus: []int{408, 425, 429, 500, 502, 503, 504},
+}
+
+// HTTPError is returned by DoReq when a stage fails with an error status, or
+// with no response at all, and is not, or is no longer, retried. Err is the
+// transport error when there was no response; StatusCode is then zero.
+type HTTPError struct {
+	Stage      string
+	StatusCode int
+	Message    string
+	Attempts   int
+	Err        error
+}
+
+func (e *HTTPError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v after %d attempt(s)", e.Stage, e.Err, e.Attempts)
+	}
+	if e.Message == "" {
+		return fmt.Sprintf("%s: HTTP %d after %d attempt(s)", e.Stage, e.StatusCode, e.Attempts)
+	}
+	return fmt.Sprintf("%s: HTTP %d after %d attempt(s): %s", e.Stage, e.StatusCode, e.Attempts, e.Message)
+}
+
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// stageName drops the "(CRN)" style suffix so per-stage policies can be
+// keyed by the plain stage name, e.g. "Adding Course".
+func stageName(stage string) string {
+	if i := strings.Index(stage, " ("); i >= 0 {
+		return stage[:i]
+	}
+	return stage
+}
+
+// onceStages are sent at most once whatever the policy says, since sending
+// them again would replay a one-time passcode or push.
+var onceStages = map[string]bool{
+	"Submitting SSO Passcode": true,
+	"Checking SSO Push":       true,
+}
+
+func (t *Task) retryPolicy(stage string) RetryPolicy {
+	if onceStages[stageName(stage)] {
+		return RetryPolicy{MaxAttempts: 1}
+	}
+	if policy, ok := t.RetryPolicies[stageName(stage)]; ok {
+		return policy
+	}
+	if t.RetryPolicy != nil {
+		return *t.RetryPolicy
+	}
+	return DefaultRetryPolicy
+}
+
+func (p RetryPolicy) retryable(status int) bool {
+	for _, code := range p.RetryableStatus {
+		if code == status {
+			return true
+		}
+	}
+	return false
+}
+
+// delay returns how long to wait before the given retry (1 for the first
+// retry), honouring a Retry-After header when the server sent one. Neither
+// waits longer than MaxDelay, so a server cannot stall a task indefinitely.
+func (p RetryPolicy) delay(retry int, resp *http.Response) time.Duration {
+	if wait, ok := retryAfter(resp); ok {
+		if p.MaxDelay > 0 && wait > p.MaxDelay {
+			return p.MaxDelay
+		}
+		return wait
+	}
+
+	delay := float64(p.BaseDelay) * math.Pow(2, float64(retry-1))
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+	if p.Jitter > 0 {
+		delay += delay * p.Jitter * (2*rand.Float64() - 1)
+	}
+	if delay < 0 {
+		delay = 0
+	}
+	return time.Duration(delay)
+}
+
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	value := strings.TrimSpace(resp.Header.Get("Retry-After"))
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
@@ -0,0 +1,108 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	http "github.com/bogdanfinn/fhttp"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 2 * time.Second, MaxDelay: 30 * time.Second}
+	retryAfter := func(value string) *http.Response {
+		return &http.Response{Header: http.Header{"Retry-After": {value}}}
+	}
+	tests := []struct {
+		name  string
+		retry int
+		resp  *http.Response
+		want  time.Duration
+	}{
+		{name: "first retry", retry: 1, want: 2 * time.Second},
+		{name: "doubles", retry: 3, want: 8 * time.Second},
+		{name: "capped", retry: 10, want: 30 * time.Second},
+		{name: "no header", retry: 2, resp: &http.Response{Header: http.Header{}}, want: 4 * time.Second},
+		{name: "retry after seconds", retry: 4, resp: retryAfter("7"), want: 7 * time.Second},
+		{name: "retry after capped", retry: 1, resp: retryAfter("86400"), want: 30 * time.Second},
+		{name: "retry after zero", retry: 4, resp: retryAfter("0"), want: 0},
+		{name: "retry after in the past", retry: 4, resp: retryAfter("Mon, 02 Jan 2006 15:04:05 GMT"), want: 0},
+		{name: "retry after garbage", retry: 1, resp: retryAfter("soon"), want: 2 * time.Second},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := policy.delay(test.retry, test.resp); got != test.want {
+				t.Errorf("delay(%d) = %s, want %s", test.retry, got, test.want)
+			}
+		})
+	}
+
+	t.Run("retry after date", func(t *testing.T) {
+		got := policy.delay(1, retryAfter(time.Now().Add(20*time.Second).UTC().Format(http.TimeFormat)))
+		if got < 18*time.Second || got > 20*time.Second {
+			t.Errorf("delay = %s, want about 20s", got)
+		}
+	})
+
+	t.Run("jitter", func(t *testing.T) {
+		policy := RetryPolicy{BaseDelay: 10 * time.Second, Jitter: 0.2}
+		for i := 0; i < 100; i++ {
+			if got := policy.delay(1, nil); got < 8*time.Second || got > 12*time.Second {
+				t.Fatalf("delay = %s, want within 20%% of 10s", got)
+			}
+		}
+	})
+}
+
+func TestRetryPolicyForStage(t *testing.T) {
+	custom := RetryPolicy{MaxAttempts: 2}
+	task := &Task{
+		RetryPolicy:   &custom,
+		RetryPolicies: map[string]RetryPolicy{"Adding Course": {MaxAttempts: 9}, "Submitting SSO Passcode": {MaxAttempts: 9}},
+	}
+	tests := []struct {
+		stage string
+		want  int
+	}{
+		{"Adding Course (41846)", 9},
+		{"Submitting Batch Update", 2},
+		// Never replayed, whatever the policies say
+		{"Submitting SSO Passcode", 1},
+		{"Checking SSO Push", 1},
+	}
+	for _, test := range tests {
+		if got := task.retryPolicy(test.stage).MaxAttempts; got != test.want {
+			t.Errorf("retryPolicy(%q).MaxAttempts = %d, want %d", test.stage, got, test.want)
+		}
+	}
+	if got := (&Task{}).retryPolicy("Adding Course").MaxAttempts; got != DefaultRetryPolicy.MaxAttempts {
+		t.Errorf("default MaxAttempts = %d, want %d", got, DefaultRetryPolicy.MaxAttempts)
+	}
+}
+
+func TestDoReqWrapsTransportErrors(t *testing.T) {
+	// Nothing listens on a port that was just closed
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := "http://" + listener.Addr().String() + "/"
+	listener.Close()
+
+	task := &Task{
+		Log:         io.Discard,
+		Client:      &http.Client{},
+		RetryPolicy: &RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond},
+	}
+	_, err = task.DoReq(task.MakeReq(context.Background(), "GET", url, nil, nil), "Getting Terms", true)
+	var httpError *HTTPError
+	if !errors.As(err, &httpError) {
+		t.Fatalf("DoReq() error = %v, want an *HTTPError", err)
+	}
+	if httpError.Err == nil || httpError.StatusCode != 0 || httpError.Attempts != 2 {
+		t.Errorf("DoReq() error = %+v, want the transport error after 2 attempts", httpError)
+	}
+}
@@ -0,0 +1,463 @@
+package tasks
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"math/bits"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"register-bot/internal/export"
+)
+
+// defaultScheduleLimit is how many schedules are listed when no limit is set.
+const defaultScheduleLimit = 10
+
+// SchedulePreferences rank the schedules BuildSchedules finds. Unlike
+// SearchFilters they never rule a section out; a schedule that misses one
+// just ranks lower. Empty fields don't count.
+type SchedulePreferences struct {
+	// StartsAfter and EndsBefore are the preferred earliest start and latest
+	// end of a class day, as "HHMM" like Banner's beginTime and endTime.
+	StartsAfter string
+	EndsBefore  string
+	// DaysOff lists days to keep free of classes, any of "UMTWRFS".
+	DaysOff string
+	// Campus and Method match the campus and instructional method
+	// descriptions, e.g. "De Anza" and "Online", ignoring case.
+	Campus string
+	Method string
+	// Instructors each match part of an instructor's name, ignoring case.
+	Instructors []string
+	// OpenSeats prefers sections with seats over ones you'd be waitlisted in.
+	OpenSeats bool
+}
+
+// penalty scores how far a section is from the preferences; lower is better.
+// Each meeting day outside the preferred hours costs 1, each class on a day
+// off 3, a full section 3, and any other miss 2.
+func (p SchedulePreferences) penalty(section CourseSection) int {
+	penalty := 0
+	for _, meeting := range section.MeetingsFaculty {
+		meetingTime := meeting.MeetingTime
+		days := meetingDays(meetingTime)
+		if p.StartsAfter != "" && meetingTime.BeginTime != "" && meetingTime.BeginTime < p.StartsAfter {
+			penalty += len(days)
+		}
+		if p.EndsBefore != "" && meetingTime.EndTime != "" && meetingTime.EndTime > p.EndsBefore {
+			penalty += len(days)
+		}
+		for _, day := range days {
+			if strings.ContainsRune(strings.ToUpper(p.DaysOff), day) {
+				penalty += 3
+			}
+		}
+	}
+	if p.Campus != "" && !strings.EqualFold(section.CampusDescription, p.Campus) {
+		penalty += 2
+	}
+	if p.Method != "" && !strings.EqualFold(section.InstructionalMethodDescription, p.Method) {
+		penalty += 2
+	}
+	if len(p.Instructors) > 0 && !taughtBy(section, p.Instructors) {
+		penalty += 2
+	}
+	if p.OpenSeats && section.SeatsAvailable <= 0 {
+		penalty += 3
+	}
+	return penalty
+}
+
+func taughtBy(section CourseSection, instructors []string) bool {
+	for _, faculty := range section.Faculty {
+		for _, instructor := range instructors {
+			if strings.Contains(strings.ToLower(faculty.DisplayName), strings.ToLower(instructor)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Schedule is one section of every wanted course, none of them meeting at
+// the same time.
+type Schedule struct {
+	Sections []CourseSection
+	// Penalty sums how far each section is from the preferences.
+	Penalty int
+}
+
+// CRNs lists the schedule's sections in the order the courses were asked for.
+func (s Schedule) CRNs() []string {
+	crns := make([]string, len(s.Sections))
+	for i, section := range s.Sections {
+		crns[i] = section.CourseReferenceNumber
+	}
+	return crns
+}
+
+// Days returns the days the schedule has classes on, as "UMTWRFS" letters.
+func (s Schedule) Days() string {
+	var days strings.Builder
+	for _, day := range "UMTWRFS" {
+		for _, section := range s.Sections {
+			if strings.ContainsRune(sectionDays(section), day) {
+				days.WriteRune(day)
+				break
+			}
+		}
+	}
+	return days.String()
+}
+
+func sectionDays(section CourseSection) string {
+	var days string
+	for _, meeting := range section.MeetingsFaculty {
+		days += meetingDays(meeting.MeetingTime)
+	}
+	return days
+}
+
+// courseKey normalises a course such as "math  1c" to "MATH 1C".
+func courseKey(course string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(course), " "))
+}
+
+// splitCourse splits a course such as "MATH 1C" into subject and course
+// number.
+func splitCourse(course string) (string, string) {
+	key := courseKey(course)
+	cut := strings.LastIndex(key, " ")
+	if cut < 0 {
+		return key, ""
+	}
+	return key[:cut], key[cut+1:]
+}
+
+func bundlesConflict(a []CourseSection, b []CourseSection) bool {
+	for _, first := range a {
+		for _, second := range b {
+			if sectionsConflict(first, second) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sectionsConflict reports whether any meetings of two sections overlap.
+func sectionsConflict(a CourseSection, b CourseSection) bool {
+	for _, first := range a.MeetingsFaculty {
+		for _, second := range b.MeetingsFaculty {
+			if _, ok := overlap(first.MeetingTime, second.MeetingTime); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// courseBundles lists the ways to take a course: a section on its own, or a
+// linked section together with one of its options, like a lecture and one
+// of its labs.
+func courseBundles(course string, sections []CourseSection, links map[string][][]string) [][]CourseSection {
+	subject, number := splitCourse(course)
+	byCRN := make(map[string]CourseSection)
+	for _, section := range sections {
+		byCRN[section.CourseReferenceNumber] = section
+	}
+
+	var bundles [][]CourseSection
+	seen := make(map[string]bool)
+	add := func(bundle []CourseSection) {
+		crns := make([]string, len(bundle))
+		for i, section := range bundle {
+			crns[i] = section.CourseReferenceNumber
+		}
+		sort.Strings(crns)
+		if key := strings.Join(crns, "+"); !seen[key] {
+			seen[key] = true
+			bundles = append(bundles, bundle)
+		}
+	}
+	for _, section := range sections {
+		if !strings.EqualFold(section.Subject, subject) || !strings.EqualFold(section.CourseNumber, number) {
+			continue
+		}
+		options, linked := links[section.CourseReferenceNumber]
+		if !linked {
+			add([]CourseSection{section})
+			continue
+		}
+	options:
+		for _, option := range options {
+			bundle := []CourseSection{section}
+			for _, CRN := range option {
+				companion, ok := byCRN[CRN]
+				if !ok {
+					continue options
+				}
+				bundle = append(bundle, companion)
+			}
+			add(bundle)
+		}
+	}
+	return bundles
+}
+
+// scheduleBundle is one way to take a course with what ranking needs
+// worked out up front.
+type scheduleBundle struct {
+	id       int
+	sections []CourseSection
+	penalty  int
+	days     uint8
+}
+
+// rankedSchedule is a complete schedule with its sort keys computed once.
+type rankedSchedule struct {
+	schedule Schedule
+	days     int
+	crns     string
+}
+
+// before ranks schedules: lowest penalty first, then fewest days on campus,
+// then by CRNs so the order is stable.
+func (a rankedSchedule) before(b rankedSchedule) bool {
+	if a.schedule.Penalty != b.schedule.Penalty {
+		return a.schedule.Penalty < b.schedule.Penalty
+	}
+	if a.days != b.days {
+		return a.days < b.days
+	}
+	return a.crns < b.crns
+}
+
+// scheduleHeap keeps the best schedules found so far with the worst on top,
+// so it can be replaced when a better one turns up.
+type scheduleHeap []rankedSchedule
+
+func (h scheduleHeap) Len() int           { return len(h) }
+func (h scheduleHeap) Less(i, j int) bool { return h[j].before(h[i]) }
+func (h scheduleHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *scheduleHeap) Push(x any)        { *h = append(*h, x.(rankedSchedule)) }
+func (h *scheduleHeap) Pop() any {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return last
+}
+
+// dayMask sets a bit for each day, in "UMTWRFS" order, a section meets.
+func dayMask(sections []CourseSection) uint8 {
+	var mask uint8
+	for _, section := range sections {
+		days := sectionDays(section)
+		for i, day := range "UMTWRFS" {
+			if strings.ContainsRune(days, day) {
+				mask |= 1 << i
+			}
+		}
+	}
+	return mask
+}
+
+// BuildSchedules picks one section of each course, e.g. "MATH 1C", in every
+// combination whose meetings don't overlap, and returns the best limit of
+// them ranked by preferences: lowest penalty first, then fewest days on
+// campus. A linked section is only picked together with one of its options
+// from links. The search drops a partial schedule as soon as it conflicts,
+// or can no longer beat the limit schedules already found.
+func BuildSchedules(courses []string, sections []CourseSection, links map[string][][]string, preferences SchedulePreferences, limit int) ([]Schedule, error) {
+	if limit <= 0 {
+		limit = defaultScheduleLimit
+	}
+	options := make([][]scheduleBundle, len(courses))
+	ids := 0
+	for i, course := range courses {
+		for _, bundle := range courseBundles(course, sections, links) {
+			penalty := 0
+			for _, section := range bundle {
+				penalty += preferences.penalty(section)
+			}
+			options[i] = append(options[i], scheduleBundle{id: ids, sections: bundle, penalty: penalty, days: dayMask(bundle)})
+			ids++
+		}
+		if len(options[i]) == 0 {
+			return nil, fmt.Errorf("no sections of %s found", courseKey(course))
+		}
+	}
+
+	// Choosing the courses with the fewest sections first prunes conflicting
+	// combinations soonest.
+	order := make([]int, len(courses))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return len(options[order[i]]) < len(options[order[j]])
+	})
+	// cheapest[depth] is the least penalty the courses from depth on can add.
+	cheapest := make([]int, len(order)+1)
+	for depth := len(order) - 1; depth >= 0; depth-- {
+		least := options[order[depth]][0].penalty
+		for _, bundle := range options[order[depth]][1:] {
+			least = min(least, bundle.penalty)
+		}
+		cheapest[depth] = cheapest[depth+1] + least
+	}
+
+	conflicts := make(map[[2]int]bool)
+	conflict := func(a scheduleBundle, b scheduleBundle) bool {
+		key := [2]int{min(a.id, b.id), max(a.id, b.id)}
+		found, ok := conflicts[key]
+		if !ok {
+			found = bundlesConflict(a.sections, b.sections)
+			conflicts[key] = found
+		}
+		return found
+	}
+
+	best := &scheduleHeap{}
+	chosen := make([]scheduleBundle, len(courses))
+	var choose func(depth int, penalty int, days uint8)
+	choose = func(depth int, penalty int, days uint8) {
+		if best.Len() == limit {
+			worst := (*best)[0]
+			bound := penalty + cheapest[depth]
+			if bound > worst.schedule.Penalty || bound == worst.schedule.Penalty && bits.OnesCount8(days) > worst.days {
+				return
+			}
+		}
+		if depth == len(order) {
+			ranked := rankedSchedule{schedule: Schedule{Penalty: penalty}, days: bits.OnesCount8(days)}
+			for _, bundle := range chosen {
+				ranked.schedule.Sections = append(ranked.schedule.Sections, bundle.sections...)
+			}
+			ranked.crns = strings.Join(ranked.schedule.CRNs(), ",")
+			if best.Len() < limit {
+				heap.Push(best, ranked)
+			} else if ranked.before((*best)[0]) {
+				(*best)[0] = ranked
+				heap.Fix(best, 0)
+			}
+			return
+		}
+		course := order[depth]
+		for _, bundle := range options[course] {
+			compatible := true
+			for _, earlier := range order[:depth] {
+				if conflict(bundle, chosen[earlier]) {
+					compatible = false
+					break
+				}
+			}
+			if compatible {
+				chosen[course] = bundle
+				choose(depth+1, penalty+bundle.penalty, days|bundle.days)
+			}
+		}
+	}
+	choose(0, 0, 0)
+
+	ranked := []rankedSchedule(*best)
+	sort.Slice(ranked, func(i, j int) bool {
+		return ranked[i].before(ranked[j])
+	})
+	schedules := make([]Schedule, len(ranked))
+	for i, schedule := range ranked {
+		schedules[i] = schedule.schedule
+	}
+	return schedules, nil
+}
+
+// GetCourseSections searches every subject of the wanted courses, recording
+// seat history along the way.
+func (t *Task) GetCourseSections(ctx context.Context, courses []string) ([]CourseSection, error) {
+	var subjects []string
+	seen := make(map[string]bool)
+	for _, course := range courses {
+		subject, _ := splitCourse(course)
+		if !seen[subject] {
+			seen[subject] = true
+			subjects = append(subjects, subject)
+		}
+	}
+
+	var sections []CourseSection
+	for i, subject := range subjects {
+		if i > 0 {
+			if err := t.ResetSearch(ctx); err != nil {
+				return nil, err
+			}
+		}
+		subjectSections, err := t.SearchSections(ctx, subject)
+		if err != nil {
+			return nil, err
+		}
+		t.recordSections(subjectSections)
+		sections = append(sections, subjectSections...)
+	}
+	return sections, nil
+}
+
+var scheduleColumns = []export.Column{
+	{Name: "Rank", Key: "rank"},
+	{Name: "Penalty", Key: "penalty"},
+	{Name: "CRNs", Key: "crns"},
+	{Name: "Course Reference Number", Key: "crn"},
+	{Name: "Course", Key: "course"},
+	{Name: "Meeting Time", Key: "meeting_time"},
+	{Name: "Instructor", Key: "instructor"},
+	{Name: "Campus", Key: "campus"},
+	{Name: "Method", Key: "method"},
+	{Name: "Seats Available", Key: "seats_available"},
+}
+
+// PlanSchedules lists the best conflict-free schedules for the wanted
+// Courses. Each comes with a CRN list that can be used as is in a Signup or
+// Watch task.
+func (t *Task) PlanSchedules(ctx context.Context) error {
+	t.GenSessionId()
+	if err := t.SubmitTerm(ctx); err != nil {
+		return err
+	}
+	sections, err := t.GetCourseSections(ctx, t.Courses)
+	if err != nil {
+		return err
+	}
+	links, err := t.sectionLinks(ctx, sections)
+	if err != nil {
+		return err
+	}
+	schedules, err := BuildSchedules(t.Courses, sections, links, t.Preferences, t.ScheduleLimit)
+	if err != nil {
+		return err
+	}
+	if len(schedules) == 0 {
+		t.logln("No Conflict-Free Schedules")
+		return nil
+	}
+	t.logf("Best %d Conflict-Free Schedule(s)\n", len(schedules))
+
+	dataset := export.Dataset{Name: "schedules", Columns: scheduleColumns}
+	for i, schedule := range schedules {
+		crns := strings.Join(schedule.CRNs(), ",")
+		t.logf("\n#%d  crns: %s  (penalty %d, classes on %s)\n", i+1, crns, schedule.Penalty, schedule.Days())
+		table := tabwriter.NewWriter(t.logWriter(), 0, 0, 2, ' ', 0)
+		for _, section := range schedule.Sections {
+			meetingTime := meetingSummary(section)
+			instructor := instructorNames(section)
+			fmt.Fprintf(table, "  %s\t%s\t%s\t%s\t%s\t%s\t%d seat(s)\n", section.CourseReferenceNumber, courseName(section), meetingTime, instructor, section.CampusDescription, section.InstructionalMethodDescription, section.SeatsAvailable)
+			dataset.Rows = append(dataset.Rows, []any{i + 1, schedule.Penalty, crns, section.CourseReferenceNumber, courseName(section), meetingTime, instructor, section.CampusDescription, section.InstructionalMethodDescription, section.SeatsAvailable})
+		}
+		table.Flush()
+	}
+
+	if t.Output == "" && t.Format == "" {
+		return nil
+	}
+	return t.writeExport("schedules-", dataset)
+}
@@ -0,0 +1,190 @@
+package tasks
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// testSection builds a section of course, e.g. "MATH 1C", with seats open
+// and meetings.
+func testSection(CRN string, course string, seats int, meetings ...MeetingTime) CourseSection {
+	subject, number := splitCourse(course)
+	section := CourseSection{CourseReferenceNumber: CRN, Subject: subject, CourseNumber: number, SeatsAvailable: seats}
+	// MeetingsFaculty is a slice of an anonymous struct, easiest filled in
+	// the way Banner fills it.
+	var data struct {
+		MeetingsFaculty []map[string]MeetingTime `json:"meetingsFaculty"`
+	}
+	for _, meeting := range meetings {
+		data.MeetingsFaculty = append(data.MeetingsFaculty, map[string]MeetingTime{"meetingTime": meeting})
+	}
+	raw, _ := json.Marshal(data)
+	json.Unmarshal(raw, &section)
+	return section
+}
+
+func scheduleCRNs(schedules []Schedule) []string {
+	var CRNs []string
+	for _, schedule := range schedules {
+		CRNs = append(CRNs, strings.Join(schedule.CRNs(), ","))
+	}
+	return CRNs
+}
+
+func TestBuildSchedules(t *testing.T) {
+	sections := []CourseSection{
+		testSection("11", "MATH 1C", 5, meeting("MW", "0930", "1045")),
+		testSection("12", "MATH 1C", 0, meeting("TR", "0930", "1045")),
+		testSection("13", "MATH 1C", 5, meeting("F", "0800", "1150")),
+		testSection("21", "ENGL 1B", 5, meeting("MW", "1000", "1150")),
+		testSection("22", "ENGL 1B", 5, meeting("TR", "1330", "1520")),
+		// A lecture that needs one of two labs
+		testSection("31", "PHYS 4A", 5, meeting("TR", "1130", "1320")),
+		testSection("32", "PHYS 4A", 5, meeting("R", "1400", "1650")),
+		testSection("33", "PHYS 4A", 5, meeting("F", "0900", "1150")),
+	}
+	links := map[string][][]string{"31": {{"32"}, {"33"}}, "32": {{"31"}}, "33": {{"31"}}}
+	tests := []struct {
+		name        string
+		courses     []string
+		preferences SchedulePreferences
+		limit       int
+		want        []string
+	}{
+		{
+			name:    "fewest days first",
+			courses: []string{"MATH 1C", "ENGL 1B"},
+			want:    []string{"12,22", "13,21", "13,22", "11,22", "12,21"},
+		},
+		{
+			name:        "preferences first",
+			courses:     []string{"MATH 1C", "ENGL 1B"},
+			preferences: SchedulePreferences{DaysOff: "F", OpenSeats: true},
+			want:        []string{"11,22", "12,22", "13,21", "13,22", "12,21"},
+		},
+		{
+			name:    "limit",
+			courses: []string{"math  1c", "ENGL 1B"},
+			limit:   2,
+			want:    []string{"12,22", "13,21"},
+		},
+		{
+			name:    "linked sections go together",
+			courses: []string{"PHYS 4A", "MATH 1C"},
+			want:    []string{"31,32,12", "31,32,13", "31,33,12", "31,32,11", "31,33,11"},
+		},
+		{
+			name:    "a lab that conflicts is left out",
+			courses: []string{"PHYS 4A", "ENGL 1B"},
+			want:    []string{"31,33,22", "31,32,21", "31,33,21"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			schedules, err := BuildSchedules(test.courses, sections, links, test.preferences, test.limit)
+			if err != nil {
+				t.Fatalf("BuildSchedules: %v", err)
+			}
+			if got := scheduleCRNs(schedules); !slices.Equal(got, test.want) {
+				t.Errorf("BuildSchedules = %v, want %v", got, test.want)
+			}
+		})
+	}
+
+	if _, err := BuildSchedules([]string{"MATH 1C", "CHEM 1A"}, sections, links, SchedulePreferences{}, 0); err == nil {
+		t.Error("BuildSchedules found schedules for a course with no sections")
+	}
+}
+
+// TestBuildSchedulesMatchesExhaustiveSearch checks the pruned search against
+// ranking every combination of random sections.
+func TestBuildSchedulesMatchesExhaustiveSearch(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	courses := []string{"MATH 1C", "ENGL 1B", "PHYS 4A", "CHEM 1A", "HIST 17A"}
+	preferences := SchedulePreferences{StartsAfter: "0900", EndsBefore: "1500", DaysOff: "F", OpenSeats: true}
+	for round := 0; round < 20; round++ {
+		var sections []CourseSection
+		for i, course := range courses {
+			for j := 0; j < 2+random.Intn(4); j++ {
+				var days string
+				for _, day := range "MTWRF" {
+					if random.Intn(3) == 0 {
+						days += string(day)
+					}
+				}
+				begin := (7+random.Intn(9))*60 + 30*random.Intn(2)
+				end := begin + 50 + 10*random.Intn(7)
+				sections = append(sections, testSection(fmt.Sprintf("%d%d", i, j), course, random.Intn(3), meeting(days, clock(begin), clock(end))))
+			}
+		}
+		limit := 1 + random.Intn(6)
+
+		schedules, err := BuildSchedules(courses, sections, nil, preferences, limit)
+		if err != nil {
+			t.Fatalf("BuildSchedules: %v", err)
+		}
+		if got, want := scheduleCRNs(schedules), exhaustiveSchedules(courses, sections, preferences, limit); !slices.Equal(got, want) {
+			t.Fatalf("round %d: BuildSchedules = %v, want %v", round, got, want)
+		}
+	}
+}
+
+// clock formats minutes after midnight as "HHMM".
+func clock(minutes int) string {
+	return fmt.Sprintf("%02d%02d", minutes/60, minutes%60)
+}
+
+func exhaustiveSchedules(courses []string, sections []CourseSection, preferences SchedulePreferences, limit int) []string {
+	type candidate struct {
+		crns    string
+		penalty int
+		days    int
+	}
+	var candidates []candidate
+	var walk func(chosen []CourseSection)
+	walk = func(chosen []CourseSection) {
+		if len(chosen) == len(courses) {
+			schedule := Schedule{Sections: chosen}
+			for _, section := range chosen {
+				schedule.Penalty += preferences.penalty(section)
+			}
+			candidates = append(candidates, candidate{strings.Join(schedule.CRNs(), ","), schedule.Penalty, len(schedule.Days())})
+			return
+		}
+		subject, number := splitCourse(courses[len(chosen)])
+	sections:
+		for _, section := range sections {
+			if section.Subject != subject || section.CourseNumber != number {
+				continue
+			}
+			for _, earlier := range chosen {
+				if sectionsConflict(earlier, section) {
+					continue sections
+				}
+			}
+			walk(append(slices.Clone(chosen), section))
+		}
+	}
+	walk(nil)
+
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.penalty != b.penalty {
+			return a.penalty < b.penalty
+		}
+		if a.days != b.days {
+			return a.days < b.days
+		}
+		return a.crns < b.crns
+	})
+	var best []string
+	for _, candidate := range candidates[:min(limit, len(candidates))] {
+		best = append(best, candidate.crns)
+	}
+	return best
+}
@@ -0,0 +1,107 @@
+package tasks
+
+import (
+	"net/url"
+	"strings"
+)
+
+// SearchFilters narrow a class search beyond subject and term. Empty fields
+// don't filter.
+type SearchFilters struct {
+	CourseNumber string
+	// Instructor matches part of any instructor's name, ignoring case.
+	Instructor string
+	OpenOnly   bool
+	// Campus and Method match the campus and instructional method
+	// descriptions, e.g. "De Anza" and "Online", ignoring case.
+	Campus string
+	Method string
+	// Days lists the days a section may meet, any of "UMTWRFS". Sections that
+	// meet on any other day are left out.
+	Days string
+	// StartsAfter and EndsBefore bound meeting times, as "HHMM" like Banner's
+	// beginTime and endTime.
+	StartsAfter string
+	EndsBefore  string
+}
+
+// values adds the filters Banner applies itself to a searchResults query.
+// Banner wants codes rather than names for campus, method and instructor,
+// so those are only applied by match.
+func (f SearchFilters) values(values url.Values) {
+	if f.CourseNumber != "" {
+		values.Set("txt_courseNumber", f.CourseNumber)
+	}
+	if f.OpenOnly {
+		values.Set("chk_open_only", "true")
+	}
+}
+
+// match reports whether a section passes every filter. Meetings without days
+// or times, such as asynchronous online sections, pass the day and time
+// filters.
+func (f SearchFilters) match(section CourseSection) bool {
+	if f.CourseNumber != "" && !strings.EqualFold(section.CourseNumber, f.CourseNumber) {
+		return false
+	}
+	if f.OpenOnly && !section.OpenSection && section.SeatsAvailable <= 0 {
+		return false
+	}
+	if f.Campus != "" && !strings.EqualFold(section.CampusDescription, f.Campus) {
+		return false
+	}
+	if f.Method != "" && !strings.EqualFold(section.InstructionalMethodDescription, f.Method) {
+		return false
+	}
+
+	if f.Instructor != "" {
+		found := false
+		for _, faculty := range section.Faculty {
+			if strings.Contains(strings.ToLower(faculty.DisplayName), strings.ToLower(f.Instructor)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	for _, meeting := range section.MeetingsFaculty {
+		meetingTime := meeting.MeetingTime
+		for _, day := range meetingDays(meetingTime) {
+			if f.Days != "" && !strings.ContainsRune(strings.ToUpper(f.Days), day) {
+				return false
+			}
+		}
+		if f.StartsAfter != "" && meetingTime.BeginTime != "" && meetingTime.BeginTime < f.StartsAfter {
+			return false
+		}
+		if f.EndsBefore != "" && meetingTime.EndTime != "" && meetingTime.EndTime > f.EndsBefore {
+			return false
+		}
+	}
+	return true
+}
+
+// meetingDays returns the days a meeting is held as "UMTWRFS" letters.
+func meetingDays(meetingTime MeetingTime) string {
+	var days strings.Builder
+	for _, day := range []struct {
+		letter rune
+		meets  bool
+	}{
+		{'U', meetingTime.Sunday},
+		{'M', meetingTime.Monday},
+		{'T', meetingTime.Tuesday},
+		{'W', meetingTime.Wednesday},
+		{'R', meetingTime.Thursday},
+		{'F', meetingTime.Friday},
+		{'S', meetingTime.Saturday},
+	} {
+		if day.meets {
+			days.WriteRune(day.letter)
+		}
+	}
+	return days.String()
+}
@@ -1,6 +1,8 @@
 package tasks
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/url"
 	"strings"
@@ -10,7 +12,9 @@ import (
 )
 
 type Session struct {
-	LoginAttempts   int
+	// LoginAttempts numbers the SSO form posts of one login, so it is
+	// reset by each login and never saved with the session.
+	LoginAttempts   int `json:"-"`
 	SAMLResponse    string
 	RelayState      string
 	SignupSession   SignupSession
@@ -19,11 +23,20 @@ type Session struct {
 }
 
 func (t *Task) GenSessionId() error {
-	t.Session.UniqueSessionId = fmt.Sprintf("%s%v", strings.ToLower(generateRandomString(5)), time.Now().UnixNano()/int64(time.Millisecond))
+	id := fmt.Sprintf("%s%v", strings.ToLower(generateRandomString(5)), time.Now().UnixNano()/int64(time.Millisecond))
+	t.sessionIDMu.Lock()
+	t.Session.UniqueSessionId = id
+	t.sessionIDMu.Unlock()
 	return nil
 }
 
-func (t *Task) VisitHomepage() error {
+func (t *Task) sessionID() string {
+	t.sessionIDMu.Lock()
+	defer t.sessionIDMu.Unlock()
+	return t.Session.UniqueSessionId
+}
+
+func (t *Task) VisitHomepage(ctx context.Context) error {
 
 	headers := [][2]string{
 		{"accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8"},
@@ -31,7 +44,7 @@ func (t *Task) VisitHomepage() error {
 		{"user-agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/137.0.0.0 Safari/537.36"},
 	}
 
-	response, err := t.DoReq(t.MakeReq("GET", t.HomepageURL, headers, nil), "Gen Session", true)
+	response, err := t.DoReq(t.MakeReq(ctx, "GET", t.HomepageURL, headers, nil), "Gen Session", true)
 	if err != nil {
 		discardResp(response)
 		return err
@@ -50,7 +63,7 @@ func (t *Task) VisitHomepage() error {
 	return nil
 }
 
-func (t *Task) PreLoginSSO() error {
+func (t *Task) PreLoginSSO(ctx context.Context) error {
 	headers := [][2]string{
 		{"accept", "*/*"},
 		{"accept-language", "en-US,en;q=0.9"},
@@ -62,7 +75,7 @@ func (t *Task) PreLoginSSO() error {
 		"SAMLRequest": {t.Session.SAMLRequest},
 	}
 
-	response, err := t.DoReq(t.MakeReq("POST", "https://ssoshib.fhda.edu/idp/profile/SAML2/POST/SSO", headers, []byte(values.Encode())), "Submitting SSO Request", true)
+	response, err := t.DoReq(t.MakeReq(ctx, "POST", t.ssoURL("/idp/profile/SAML2/POST/SSO"), headers, []byte(values.Encode())), "Submitting SSO Request", true)
 	if err != nil {
 		discardResp(response)
 		return err
@@ -70,7 +83,9 @@ func (t *Task) PreLoginSSO() error {
 	return nil
 }
 
-func (t *Task) Login() error {
+const maxLoginAttempts = 3
+
+func (t *Task) Login(ctx context.Context) error {
 	headers := [][2]string{
 		{"accept", "*/*"},
 		{"accept-language", "en-US,en;q=0.9"},
@@ -78,57 +93,58 @@ func (t *Task) Login() error {
 		{"user-agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/137.0.0.0 Safari/537.36"},
 	}
 
-	t.Session.LoginAttempts++
-
-	values := url.Values{}
-	values.Set("j_username", t.Username)
-	values.Set("j_password", t.Password)
-	values.Set("_eventId_proceed", "")
-	response, err := t.DoReq(t.MakeReq("POST", fmt.Sprintf("https://ssoshib.fhda.edu/idp/profile/SAML2/POST/SSO?execution=e1s%d", t.Session.LoginAttempts), headers, []byte(values.Encode())), "Logging In", true)
-	if err != nil {
-		discardResp(response)
-		return err
-	}
-
-	body, _ := readBody(response)
-	reader := strings.NewReader(string(body))
-	document, err := goquery.NewDocumentFromReader(reader)
-	if err != nil {
-		discardResp(response)
-		return err
+	for attempt := 1; ; attempt++ {
+		t.Session.LoginAttempts++
+
+		values := url.Values{}
+		values.Set("j_username", t.Username)
+		values.Set("j_password", t.Password)
+		values.Set("_eventId_proceed", "")
+		page := t.ssoURL(fmt.Sprintf("/idp/profile/SAML2/POST/SSO?execution=e1s%d", t.Session.LoginAttempts))
+		response, err := t.DoReq(t.MakeReq(ctx, "POST", page, headers, []byte(values.Encode())), "Logging In", true)
+		if err != nil {
+			discardResp(response)
+			return err
+		}
+
+		body, _ := readBody(response)
+		reader := strings.NewReader(string(body))
+		document, err := goquery.NewDocumentFromReader(reader)
+		if err != nil {
+			discardResp(response)
+			return err
+		}
+		var message string
+		document.Find("div[class='alert alert-danger']").Each(func(index int, element *goquery.Selection) {
+			message = strings.TrimSpace(element.Text())
+		})
+
+		switch message {
+		case "The username you entered cannot be identified.":
+			t.logln("Invalid Username")
+			return fmt.Errorf("%w: %s", ErrInvalidCredentials, message)
+		case "The password you entered was incorrect.":
+			t.logln("Invalid Password")
+			return fmt.Errorf("%w: %s", ErrInvalidCredentials, message)
+		case "You may be seeing this page because you used the Back button while browsing a secure web site or application. Alternatively, you may have mistakenly bookmarked the web login form instead of the actual web site you wanted to bookmark or used a link created by somebody else who made the same mistake.  Left unchecked, this can cause errors on some browsers or result in you returning to the web site you tried to leave, so this page is presented instead.":
+			t.logln("Bad Session")
+			return ErrBadSession
+		case "":
+			// Usually the SAMLResponse, but the IdP may ask for more first
+			return t.passChallenges(ctx, document, page)
+		default:
+			t.logln(message)
+			if attempt >= maxLoginAttempts {
+				return fmt.Errorf("login failed after %d attempts: %s", attempt, message)
+			}
+			if err := sleepCtx(ctx, 2*time.Second); err != nil {
+				return err
+			}
+		}
 	}
-	var message string
-	document.Find("div[class='alert alert-danger']").Each(func(index int, element *goquery.Selection) {
-		message = strings.TrimSpace(element.Text())
-	})
-
-	switch message {
-	case "The username you entered cannot be identified.":
-		fmt.Println("Invalid Username")
-	case "The password you entered was incorrect.":
-		fmt.Println("Invalid Password")
-		time.Sleep(2 * time.Second)
-		t.Login()
-	case "You may be seeing this page because you used the Back button while browsing a secure web site or application. Alternatively, you may have mistakenly bookmarked the web login form instead of the actual web site you wanted to bookmark or used a link created by somebody else who made the same mistake.  Left unchecked, this can cause errors on some browsers or result in you returning to the web site you tried to leave, so this page is presented instead.":
-		fmt.Println("Bad Session")
-		t.GenSession()
-	case "":
-		break
-	default:
-		fmt.Println(message)
-		time.Sleep(2 * time.Second)
-		t.Login()
-	}
-
-	relayState := getSelectorAttr(document, "input[name='RelayState']", "value")
-	samlResponse := getSelectorAttr(document, "input[name='SAMLResponse']", "value")
-
-	t.Session.RelayState = relayState
-	t.Session.SAMLResponse = samlResponse
-	return nil
 }
 
-func (t *Task) SubmitSSOManager() error {
+func (t *Task) SubmitSSOManager(ctx context.Context) error {
 	headers := [][2]string{
 		{"accept", "*/*"},
 		{"accept-language", "en-US,en;q=0.9"},
@@ -140,7 +156,7 @@ func (t *Task) SubmitSSOManager() error {
 		"SAMLResponse": {t.Session.SAMLResponse},
 	}
 
-	response, err := t.DoReq(t.MakeReq("POST", "https://reg.oci.fhda.edu/StudentRegistrationSsb/saml/SSO", headers, []byte(values.Encode())), "Submitting SSO Manager", true)
+	response, err := t.DoReq(t.MakeReq(ctx, "POST", t.regURL("/StudentRegistrationSsb/saml/SSO"), headers, []byte(values.Encode())), "Submitting SSO Manager", true)
 	if err != nil {
 		discardResp(response)
 		return err
@@ -148,7 +164,7 @@ func (t *Task) SubmitSSOManager() error {
 	return nil
 }
 
-func (t *Task) Check() error {
+func (t *Task) Check(ctx context.Context) error {
 
 	headers := [][2]string{
 		{"accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8"},
@@ -156,7 +172,7 @@ func (t *Task) Check() error {
 		{"user-agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/137.0.0.0 Safari/537.36"},
 	}
 
-	response, err := t.DoReq(t.MakeReq("GET", "https://reg.oci.fhda.edu/StudentRegistrationSsb/ssb/registration", headers, nil), "Checking Session", true)
+	response, err := t.DoReq(t.MakeReq(ctx, "GET", t.regURL("/StudentRegistrationSsb/ssb/registration"), headers, nil), "Checking Session", true)
 	if err != nil {
 		discardResp(response)
 		return err
@@ -171,15 +187,57 @@ func (t *Task) Check() error {
 	}
 
 	fullName := getSelectorAttr(document, "meta[name='fullName']", "content")
-	fmt.Printf("Welcome to Register Bot, %s.\n", fullName)
+	if fullName == "" {
+		return ErrNotLoggedIn
+	}
+	t.logf("Welcome to Register Bot, %s.\n", fullName)
+	return nil
+}
+
+func (t *Task) GenSession(ctx context.Context) error {
+	mu := t.loginMu()
+	mu.Lock()
+	defer mu.Unlock()
+	if err := t.genSessionWithRetries(ctx); err != nil {
+		return err
+	}
+	t.shareSession()
+	return nil
+}
+
+// genSessionWithRetries must be called with the login lock held. A successful
+// login is saved to SessionFile for the next run.
+func (t *Task) genSessionWithRetries(ctx context.Context) error {
+	var err error
+	for attempt := 1; attempt <= maxLoginAttempts; attempt++ {
+		if err = t.genSession(ctx); !errors.Is(err, ErrBadSession) {
+			break
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if err := t.SaveSession(); err != nil {
+		t.logf("Could Not Save Session: %v\n", err)
+	}
 	return nil
 }
 
-func (t *Task) GenSession() {
+func (t *Task) genSession(ctx context.Context) error {
+	t.clearCookies()
+	t.Session.LoginAttempts = 0
 	t.GenSessionId()
-	t.VisitHomepage()
-	t.PreLoginSSO()
-	t.Login()
-	t.SubmitSSOManager()
-	t.Check()
+	steps := []func(context.Context) error{
+		t.VisitHomepage,
+		t.PreLoginSSO,
+		t.Login,
+		t.SubmitSSOManager,
+		t.Check,
+	}
+	for _, step := range steps {
+		if err := step(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -1,11 +1,13 @@
 package tasks
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/url"
 	"regexp"
+	"slices"
 	"strings"
 	"time"
 
@@ -14,10 +16,110 @@ import (
 
 type SignupSession struct {
 	SAMLRequest string
-	Models      []map[string]interface{}
 }
 
-func (t *Task) CheckAuthSession() error {
+// Registration is a single signup attempt: the CRNs it adds and drops and the
+// worksheet models queued for its batch. Every attempt gets its own, so
+// attempts running at the same time on one Task never share a batch.
+type Registration struct {
+	CRNs     []string
+	DropCRNs []string
+	Waitlist bool
+	// WaitlistCRNs are waitlisted even when Waitlist is off.
+	WaitlistCRNs []string
+	// Alternates lists, for a CRN in CRNs, the other sections of its course
+	// to fall back on, in order, when it is rejected.
+	Alternates map[string][]string
+	// Swaps pairs a CRN in CRNs with the CRN in DropCRNs it replaces. That
+	// drop is only queued when the CRN is, and is rolled back if Banner
+	// drops it without adding the CRN. A paired CRN missing from DropCRNs
+	// was already dropped by an earlier batch of the same attempt.
+	Swaps  map[string]string
+	Models []map[string]interface{}
+	// Statuses holds the batch's statusDescription for each submitted CRN.
+	Statuses map[string]string
+}
+
+func (r *Registration) dropped(CRN string) bool {
+	switch r.Statuses[CRN] {
+	case "Deleted", "Dropped", "Web Drop":
+		return true
+	}
+	return false
+}
+
+func (t *Task) newRegistration() *Registration {
+	registration := &Registration{
+		CRNs:       append([]string(nil), t.CRNs...),
+		DropCRNs:   append([]string(nil), t.DropCRNs...),
+		Waitlist:   t.WaitlistTask,
+		Alternates: make(map[string][]string),
+		Swaps:      make(map[string]string),
+	}
+	for CRN, alternates := range t.Alternates {
+		registration.Alternates[CRN] = append([]string(nil), alternates...)
+	}
+	for CRN, drop := range t.Swaps {
+		registration.Swaps[CRN] = drop
+	}
+	return registration
+}
+
+// fallBack replaces the i-th CRN with its next alternate, which inherits
+// the alternates after it and the drop it is swapped for. It reports false
+// when there are none left.
+func (r *Registration) fallBack(i int) (string, bool) {
+	current := r.CRNs[i]
+	alternates := r.Alternates[current]
+	if len(alternates) == 0 {
+		return "", false
+	}
+	next := alternates[0]
+	delete(r.Alternates, current)
+	r.CRNs[i] = next
+	r.Alternates[next] = alternates[1:]
+	if drop, ok := r.Swaps[current]; ok {
+		delete(r.Swaps, current)
+		r.Swaps[next] = drop
+	}
+	return next, true
+}
+
+// nextAlternate replaces the i-th CRN with its next alternate that passes
+// checkAlternate against others, skipping ones that need a linked section
+// that can't be picked or that conflict. It returns the alternate followed
+// by the linked sections it needs, or nil when no alternates are left.
+func (t *Task) nextAlternate(ctx context.Context, registration *Registration, i int, others []string) ([]string, error) {
+	for {
+		current := registration.CRNs[i]
+		next, ok := registration.fallBack(i)
+		if !ok {
+			return nil, nil
+		}
+		t.logf("[%s] - Falling Back to Alternate %s\n", current, next)
+		CRNs, err := t.checkAlternate(ctx, next, others)
+		if err == nil {
+			return CRNs, nil
+		}
+		if !errors.Is(err, ErrLinkedSection) && !errors.Is(err, ErrScheduleConflict) {
+			return nil, err
+		}
+		t.logf("[%s] - Skipping Alternate: %v\n", next, err)
+	}
+}
+
+// CheckAuthSession logs in again if the registration session has expired.
+// The first call picks up the session saved in SessionFile, if any, so a
+// still-valid login from an earlier run is reused. It holds the login lock,
+// shared by every task of the Account, so concurrent callers wait for a
+// single re-login instead of each starting their own, and then find the
+// session valid.
+func (t *Task) CheckAuthSession(ctx context.Context) error {
+	mu := t.loginMu()
+	mu.Lock()
+	defer mu.Unlock()
+	t.adoptSession()
+	restored := t.restoreSession()
 
 	headers := [][2]string{
 		{"accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8"},
@@ -25,26 +127,32 @@ func (t *Task) CheckAuthSession() error {
 		{"user-agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/137.0.0.0 Safari/537.36"},
 	}
 
-	response, err := t.DoReq(t.MakeReq("GET", "https://reg.oci.fhda.edu/StudentRegistrationSsb/login/authAjax", headers, nil), "Checking Auth Session", true)
+	response, err := t.DoReq(t.MakeReq(ctx, "GET", t.regURL("/StudentRegistrationSsb/login/authAjax"), headers, nil), "Checking Auth Session", true)
 	if err != nil {
 		discardResp(response)
 		return err
 	}
 	body, _ := readBody(response)
 	if strings.Contains(string(body), "userNotLoggedIn") {
-		t.GenSession()
+		if restored != nil {
+			t.logln("Saved Session Expired, Logging In")
+		}
+		if err := t.genSessionWithRetries(ctx); err != nil {
+			return err
+		}
 	}
+	t.shareSession()
 	return nil
 }
 
-func (t *Task) RegisterPostSignIn() error {
+func (t *Task) RegisterPostSignIn(ctx context.Context) error {
 	headers := [][2]string{
 		{"accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8"},
 		{"accept-language", "en-US,en;q=0.9"},
 		{"user-agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/137.0.0.0 Safari/537.36"},
 	}
 
-	response, err := t.DoReq(t.MakeReq("GET", "https://reg.oci.fhda.edu/StudentRegistrationSsb/ssb/registration/registerPostSignIn?mode=registration", headers, nil), "Register Post Sign In", true)
+	response, err := t.DoReq(t.MakeReq(ctx, "GET", t.regURL("/StudentRegistrationSsb/ssb/registration/registerPostSignIn?mode=registration"), headers, nil), "Register Post Sign In", true)
 	if err != nil {
 		discardResp(response)
 		return err
@@ -60,7 +168,7 @@ func (t *Task) RegisterPostSignIn() error {
 	return nil
 }
 
-func (t *Task) SubmitSamIsso() error {
+func (t *Task) SubmitSamIsso(ctx context.Context) error {
 
 	headers := [][2]string{
 		{"accept", "*/*"},
@@ -73,7 +181,7 @@ func (t *Task) SubmitSamIsso() error {
 		"SAMLRequest": {t.Session.SignupSession.SAMLRequest},
 	}
 
-	response, err := t.DoReq(t.MakeReq("POST", "https://eis-prod.ec.fhda.edu/samlsso", headers, []byte(values.Encode())), "Submitting Sam Isso", true)
+	response, err := t.DoReq(t.MakeReq(ctx, "POST", t.eisURL("/samlsso"), headers, []byte(values.Encode())), "Submitting Sam Isso", true)
 	if err != nil {
 		discardResp(response)
 		return err
@@ -90,7 +198,7 @@ func (t *Task) SubmitSamIsso() error {
 	return nil
 }
 
-func (t *Task) SubmitSSBSp() error {
+func (t *Task) SubmitSSBSp(ctx context.Context) error {
 	headers := [][2]string{
 		{"accept", "*/*"},
 		{"accept-language", "en-US,en;q=0.9"},
@@ -102,7 +210,7 @@ func (t *Task) SubmitSSBSp() error {
 		"SAMLResponse": {t.Session.SAMLResponse},
 	}
 
-	resp, err := t.DoReq(t.MakeReq("POST", "https://reg.oci.fhda.edu/StudentRegistrationSsb/saml/SSO/alias/registrationssb-prod-sp", headers, []byte(values.Encode())), "Submitting SSB SP", true)
+	resp, err := t.DoReq(t.MakeReq(ctx, "POST", t.regURL("/StudentRegistrationSsb/saml/SSO/alias/registrationssb-prod-sp"), headers, []byte(values.Encode())), "Submitting SSB SP", true)
 	if err != nil {
 		discardResp(resp)
 		return err
@@ -110,14 +218,14 @@ func (t *Task) SubmitSSBSp() error {
 	return nil
 }
 
-func (t *Task) CheckCRN(course string) error {
+func (t *Task) CheckCRN(ctx context.Context, course string) error {
 	headers := [][2]string{
 		{"accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8"},
 		{"accept-language", "en-US,en;q=0.9"},
 		{"user-agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/137.0.0.0 Safari/537.36"},
 	}
 
-	response, err := t.DoReq(t.MakeReq("GET", fmt.Sprintf("https://reg.oci.fhda.edu/StudentRegistrationSsb/ssb/classRegistration/getSectionDetailsFromCRN?courseReferenceNumber=%s&term=%s", course, t.TermID), headers, nil), fmt.Sprintf("Checking Course (%s)", course), true)
+	response, err := t.DoReq(t.MakeReq(ctx, "GET", t.regURL(fmt.Sprintf("/StudentRegistrationSsb/ssb/classRegistration/getSectionDetailsFromCRN?courseReferenceNumber=%s&term=%s", course, t.TermID)), headers, nil), fmt.Sprintf("Checking Course (%s)", course), true)
 	if err != nil {
 		discardResp(response)
 		return err
@@ -128,21 +236,26 @@ func (t *Task) CheckCRN(course string) error {
 		return err
 	}
 	if !courseData.Olr {
-		fmt.Println(courseData.ResponseDisplay)
+		t.logln(courseData.ResponseDisplay)
 	} else {
-		fmt.Printf("[%s] - Unable To Get Data\n", course)
+		t.logf("[%s] - Unable To Get Data\n", course)
 	}
 	return nil
 }
 
-func (t *Task) CheckCRNs() error {
+func (t *Task) CheckCRNs(ctx context.Context) error {
+	var errs []error
 	for _, course := range t.CRNs {
-		t.CheckCRN(course)
+		if err := t.CheckCRN(ctx, course); err != nil {
+			errs = append(errs, err)
+		}
 	}
-	return nil
+	return errors.Join(errs...)
 }
 
-func (t *Task) GetRegistrationStatus() error {
+// fetchRegistrationStatus asks Banner once whether this student may register
+// for the term.
+func (t *Task) fetchRegistrationStatus(ctx context.Context) (RegistrationStatus, error) {
 	headers := [][2]string{
 		{"accept", "*/*"},
 		{"accept-language", "en-US,en;q=0.9"},
@@ -155,94 +268,116 @@ func (t *Task) GetRegistrationStatus() error {
 		"studyPath":       {},
 		"startDatepicker": {},
 		"endDatepicker":   {},
-		"uniqueSessionId": {t.Session.UniqueSessionId},
+		"uniqueSessionId": {t.sessionID()},
 	}
 
-	response, err := t.DoReq(t.MakeReq("POST", "https://reg.oci.fhda.edu/StudentRegistrationSsb/ssb/term/search?mode=registration", headers, []byte(values.Encode())), "Getting Registration Status", true)
+	registrationStatus := RegistrationStatus{}
+	response, err := t.DoReq(t.MakeReq(ctx, "POST", t.regURL("/StudentRegistrationSsb/ssb/term/search?mode=registration"), headers, []byte(values.Encode())), "Getting Registration Status", true)
 	if err != nil {
 		discardResp(response)
-		return err
+		return registrationStatus, err
 	}
 
 	body, _ := readBody(response)
-	registrationStatus := RegistrationStatus{}
+	err = json.Unmarshal(body, &registrationStatus)
+	return registrationStatus, err
+}
 
-	if err := json.Unmarshal(body, &registrationStatus); err != nil {
-		return err
-	}
+func (t *Task) GetRegistrationStatus(ctx context.Context) error {
+	for {
+		registrationStatus, err := t.fetchRegistrationStatus(ctx)
+		if err != nil {
+			return err
+		}
 
-	var hasFailure, hasRegistrationTime bool
-	var timeFailure string
+		var hasFailure, hasRegistrationTime bool
+		var timeFailure string
 
-	for _, failure := range registrationStatus.StudentEligFailures {
-		fmt.Println(failure)
-		hasFailure = true
-		if strings.Contains(failure, "You can register from") {
-			hasRegistrationTime = true
-			timeFailure = failure
-			break
+		for _, failure := range registrationStatus.StudentEligFailures {
+			t.logln(failure)
+			hasFailure = true
+			if strings.Contains(failure, "You can register from") {
+				hasRegistrationTime = true
+				timeFailure = failure
+				break
+			}
 		}
-	}
 
-	if hasFailure && !hasRegistrationTime {
-		return errors.New(registrationStatus.StudentEligFailures[len(registrationStatus.StudentEligFailures)])
-	}
+		if hasFailure && !hasRegistrationTime {
+			return &EligibilityError{Failures: registrationStatus.StudentEligFailures}
+		}
+
+		if !hasFailure {
+			return nil
+		}
 
-	if hasFailure && hasRegistrationTime {
 		pattern := regexp.MustCompile(`\d{2}/\d{2}/\d{4} \d{2}:\d{2} [APM]{2}`)
 		matches := pattern.FindAllString(timeFailure, -1)
+		if len(matches) == 0 {
+			return &EligibilityError{Failures: registrationStatus.StudentEligFailures}
+		}
 
-		if len(matches) > 0 {
-			location, _ := time.LoadLocation("America/Los_Angeles")
-			targetTime, _ := time.ParseInLocation("01/02/2006 03:04 PM", matches[0], location)
-			now := time.Now().In(location)
-			saveRegistrationTime(matches[0])
-
-			if now.After(targetTime) {
-				time.Sleep(2 * time.Second)
-				return t.GetRegistrationStatus()
-			} else if now.Before(targetTime) {
-				t.CheckCRNs()
-				timeToWait := targetTime.Sub(now)
-
-				fmt.Printf("Waiting for Registration to open: %s\n", targetTime.Format(time.RFC1123))
-				fmt.Printf("Will continue in %s\n", formatDuration(targetTime.Sub(now)))
-				go func() {
-					ticker := time.NewTicker(5 * time.Minute)
-					defer ticker.Stop()
-
-					endTime := time.Now().Add(timeToWait)
-					for now := range ticker.C {
-						err := t.CheckAuthSession()
-						if err != nil {
-							fmt.Println(err)
-						}
-
-						if now.After(endTime) {
-							break
-						}
-					}
-				}()
+		location, _ := time.LoadLocation("America/Los_Angeles")
+		targetTime, _ := time.ParseInLocation("01/02/2006 03:04 PM", matches[0], location)
+		now := time.Now().In(location)
+		t.saveRegistrationTime(matches[0])
 
-				time.Sleep(targetTime.Sub(now))
-				err := t.CheckAuthSession()
-				if err != nil {
-					return err
-				}
-				return t.GetRegistrationStatus()
+		if !now.Before(targetTime) {
+			if err := sleepCtx(ctx, 2*time.Second); err != nil {
+				return err
+			}
+			continue
+		}
+		if t.DryRun {
+			t.logf("Dry Run: Registration Opens %s, Rehearsing Anyway\n", targetTime.Format(time.RFC1123))
+			return nil
+		}
+
+		if err := t.CheckCRNs(ctx); err != nil {
+			t.logln(err)
+		}
+
+		t.logf("Waiting for Registration to open: %s\n", targetTime.Format(time.RFC1123))
+		t.logf("Will continue in %s\n", formatDuration(targetTime.Sub(now)))
+		if err := t.keepAliveUntil(ctx, targetTime); err != nil {
+			return err
+		}
+		if err := t.CheckAuthSession(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// keepAliveUntil sleeps until target, probing the auth session every five
+// minutes so it does not expire while waiting.
+func (t *Task) keepAliveUntil(ctx context.Context, target time.Time) error {
+	ticker := time.NewTicker(5 * time.Minute)
+	defer ticker.Stop()
+
+	timer := time.NewTimer(time.Until(target))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+			return nil
+		case <-ticker.C:
+			if err := t.CheckAuthSession(ctx); err != nil {
+				t.logln(err)
 			}
 		}
 	}
-	return nil
 }
 
-func (t *Task) VisitClassRegistration() error {
+func (t *Task) VisitClassRegistration(ctx context.Context) error {
 	headers := [][2]string{
 		{"accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8"},
 		{"accept-language", "en-US,en;q=0.9"},
 		{"user-agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/137.0.0.0 Safari/537.36"},
 	}
-	response, err := t.DoReq(t.MakeReq("HEAD", "https://reg.oci.fhda.edu/StudentRegistrationSsb/ssb/classRegistration/classRegistration", headers, nil), "Visiting Class Registration", true)
+	response, err := t.DoReq(t.MakeReq(ctx, "HEAD", t.regURL("/StudentRegistrationSsb/ssb/classRegistration/classRegistration"), headers, nil), "Visiting Class Registration", true)
 	if err != nil {
 		discardResp(response)
 		return err
@@ -250,14 +385,14 @@ func (t *Task) VisitClassRegistration() error {
 	return nil
 }
 
-func (t *Task) AddCourse(course string) error {
+func (t *Task) AddCourse(ctx context.Context, registration *Registration, course string) error {
 	headers := [][2]string{
 		{"accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8"},
 		{"accept-language", "en-US,en;q=0.9"},
 		{"user-agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/137.0.0.0 Safari/537.36"},
 	}
 
-	response, err := t.DoReq(t.MakeReq("GET", fmt.Sprintf("https://reg.oci.fhda.edu/StudentRegistrationSsb/ssb/classRegistration/addRegistrationItem?term=%s&courseReferenceNumber=%s&olr=false", t.TermID, course), headers, nil), fmt.Sprintf("Adding Course (%s)", course), true)
+	response, err := t.DoReq(t.MakeReq(ctx, "GET", t.regURL(fmt.Sprintf("/StudentRegistrationSsb/ssb/classRegistration/addRegistrationItem?term=%s&courseReferenceNumber=%s&olr=false", t.TermID, course)), headers, nil), fmt.Sprintf("Adding Course (%s)", course), true)
 	if err != nil {
 		discardResp(response)
 		return err
@@ -274,19 +409,20 @@ func (t *Task) AddCourse(course string) error {
 			return err
 		}
 		// Use "WL" for waitlist if requested, otherwise "RW" for regular registration
-		if t.WaitlistTask {
+		if registration.Waitlist || slices.Contains(registration.WaitlistCRNs, course) {
 			model["selectedAction"] = "WL"
 		} else {
 			model["selectedAction"] = "RW"
 		}
-		t.Session.SignupSession.Models = append(t.Session.SignupSession.Models, model)
+		registration.Models = append(registration.Models, model)
 	} else {
-		fmt.Printf("Error Adding Course (%s) - %s\n", course, addCourse.Message)
+		t.logf("Error Adding Course (%s) - %s\n", course, addCourse.Message)
+		return &CRNError{CRN: course, Messages: []string{addCourse.Message}}
 	}
 	return nil
 }
 
-func (t *Task) DropCourse(course string) error {
+func (t *Task) DropCourse(ctx context.Context, registration *Registration, course string) error {
 	headers := [][2]string{
 		{"accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8"},
 		{"accept-language", "en-US,en;q=0.9"},
@@ -295,7 +431,7 @@ func (t *Task) DropCourse(course string) error {
 
 	// FHDA uses the same endpoint to "add" a course to the worksheet before submitting.
 	// We need to get the model for the existing course to drop it.
-	response, err := t.DoReq(t.MakeReq("GET", fmt.Sprintf("https://reg.oci.fhda.edu/StudentRegistrationSsb/ssb/classRegistration/addRegistrationItem?term=%s&courseReferenceNumber=%s&olr=false", t.TermID, course), headers, nil), fmt.Sprintf("Preparing to Drop Course (%s)", course), true)
+	response, err := t.DoReq(t.MakeReq(ctx, "GET", t.regURL(fmt.Sprintf("/StudentRegistrationSsb/ssb/classRegistration/addRegistrationItem?term=%s&courseReferenceNumber=%s&olr=false", t.TermID, course)), headers, nil), fmt.Sprintf("Preparing to Drop Course (%s)", course), true)
 	if err != nil {
 		discardResp(response)
 		return err
@@ -313,37 +449,95 @@ func (t *Task) DropCourse(course string) error {
 			return err
 		}
 		model["selectedAction"] = "DW" // DW is typically the code for Web Drop
-		t.Session.SignupSession.Models = append(t.Session.SignupSession.Models, model)
-		fmt.Printf("Prepared to drop course %s\n", course)
+		registration.Models = append(registration.Models, model)
+		t.logf("Prepared to drop course %s\n", course)
 	} else {
-		fmt.Printf("Error preparing to drop course (%s) - %s\n", course, addCourse.Message)
+		t.logf("Error preparing to drop course (%s) - %s\n", course, addCourse.Message)
+		return &CRNError{CRN: course, Messages: []string{addCourse.Message}}
 	}
 	return nil
 }
 
-func (t *Task) AddCourses() error {
-	// First handle any drops
-	for _, course := range t.DropCRNs {
-		err := t.DropCourse(course)
-		if err != nil {
-			fmt.Printf("Warning: Failed to prepare drop for %s: %v\n", course, err)
+// AddCourses queues every drop and add on the worksheet, drops first. CRNs
+// Banner refuses fall back to their alternates, each checked for linked
+// sections and conflicts first; ones with none left are skipped and returned
+// as CRNErrors, and so is the drop swapped for one. ErrNothingToSubmit is
+// added when nothing at all could be queued.
+func (t *Task) AddCourses(ctx context.Context, registration *Registration) error {
+	var rejected []error
+
+	// Handle adds first, falling back to alternates of any CRN that can't be
+	// added, so drops swapped for a refused CRN are never queued
+	queued := make(map[string]bool)
+	refused := make(map[string]bool)
+	// Linked sections an alternate needs are appended to CRNs as they are
+	// queued, so only the CRNs asked for are walked.
+	asked := len(registration.CRNs)
+	for i := 0; i < asked; i++ {
+		courses := []string{registration.CRNs[i]}
+		for len(courses) > 0 {
+			err := t.AddCourse(ctx, registration, courses[0])
+			if err == nil {
+				queued[courses[0]] = true
+				courses = courses[1:]
+				continue
+			}
+			if !errors.Is(err, ErrCRNRejected) {
+				return err
+			}
+			if courses[0] != registration.CRNs[i] {
+				// A linked section an alternate needs
+				refused[courses[0]] = true
+				rejected = append(rejected, err)
+				courses = courses[1:]
+				continue
+			}
+			// An alternate is checked against everything else still going in
+			var others []string
+			for j, CRN := range registration.CRNs {
+				if j != i && !refused[CRN] {
+					others = append(others, CRN)
+				}
+			}
+			next, nextErr := t.nextAlternate(ctx, registration, i, others)
+			if nextErr != nil {
+				return nextErr
+			}
+			if next == nil {
+				refused[courses[0]] = true
+				rejected = append(rejected, err)
+				break
+			}
+			registration.CRNs = append(registration.CRNs, next[1:]...)
+			courses = next
 		}
 	}
 
-	// Then handle adds
-	for _, course := range t.CRNs {
-		err := t.AddCourse(course)
+	// Then handle drops, which still go ahead of the adds in the batch
+	addModels := registration.Models
+	registration.Models = nil
+	for _, course := range registration.DropCRNs {
+		if CRN, swapped := registration.swappedFor(course); swapped && !queued[CRN] {
+			t.logf("[%s] - Keeping, Since %s Could Not Be Added\n", course, CRN)
+			continue
+		}
+		err := t.DropCourse(ctx, registration, course)
 		if err != nil {
-			return err
+			t.logf("Warning: Failed to prepare drop for %s: %v\n", course, err)
+			if !errors.Is(err, ErrCRNRejected) {
+				return err
+			}
+			rejected = append(rejected, err)
 		}
 	}
-	if len(t.Session.SignupSession.Models) == 0 {
-		return errors.New("No courses to add or drop")
+	registration.Models = append(registration.Models, addModels...)
+	if len(registration.Models) == 0 {
+		return errors.Join(append([]error{ErrNothingToSubmit}, rejected...)...)
 	}
-	return nil
+	return errors.Join(rejected...)
 }
 
-func (t *Task) SendBatch() error {
+func (t *Task) SendBatch(ctx context.Context, registration *Registration) error {
 	headers := [][2]string{
 		{"accept", "application/json"},
 		{"accept-language", "en-US,en;q=0.9"},
@@ -352,16 +546,16 @@ func (t *Task) SendBatch() error {
 	}
 
 	batch := Batch{
-		Update:          t.Session.SignupSession.Models,
-		UniqueSessionId: t.Session.UniqueSessionId,
+		Update:          registration.Models,
+		UniqueSessionId: t.sessionID(),
 	}
 
 	batchJson, err := json.MarshalIndent(batch, "", "  ")
 	if err != nil {
-		return nil
+		return err
 	}
 
-	response, err := t.DoReq(t.MakeReq("POST", "https://reg.oci.fhda.edu/StudentRegistrationSsb/ssb/classRegistration/submitRegistration/batch", headers, []byte(string(batchJson))), "Submitting Batch Update", true)
+	response, err := t.DoReq(t.MakeReq(ctx, "POST", t.regURL("/StudentRegistrationSsb/ssb/classRegistration/submitRegistration/batch"), headers, []byte(string(batchJson))), "Submitting Batch Update", true)
 	if err != nil {
 		discardResp(response)
 		return err
@@ -372,46 +566,169 @@ func (t *Task) SendBatch() error {
 		return err
 	}
 
+	// Check against both added and dropped CRNs
+	allCRNs := append(append([]string(nil), registration.CRNs...), registration.DropCRNs...)
+
+	registration.Statuses = make(map[string]string)
+	var rejected []error
 	for _, data := range changes.Data.Update {
-		// Check against both added and dropped CRNs
-		allCRNs := append(t.CRNs, t.DropCRNs...)
 		for _, courseReferenceNumber := range allCRNs {
 			if data.CourseReferenceNumber == courseReferenceNumber {
+				registration.Statuses[courseReferenceNumber] = data.StatusDescription
 				if data.StatusDescription == "Registered" {
-					fmt.Printf("[%s - %s %s - %s] - Successfully Registered\n", data.CourseReferenceNumber, data.Subject, data.CourseNumber, data.CourseTitle)
-					t.SendNotification(data.CourseTitle, fmt.Sprintf("Successful Enrollment (%s)", data.CourseReferenceNumber))
+					t.logf("[%s - %s %s - %s] - Successfully Registered\n", data.CourseReferenceNumber, data.Subject, data.CourseNumber, data.CourseTitle)
+					t.record("Registered", data.CourseReferenceNumber)
+					t.SendNotification(ctx, data.CourseTitle, fmt.Sprintf("Successful Enrollment (%s)", data.CourseReferenceNumber))
 				} else if data.StatusDescription == "Waitlisted" {
-					fmt.Printf("[%s - %s %s - %s] - Successfully Waitlisted\n", data.CourseReferenceNumber, data.Subject, data.CourseNumber, data.CourseTitle)
-					t.SendNotification(data.CourseTitle, fmt.Sprintf("Successful Waitlisted (%s)", data.CourseReferenceNumber))
+					t.logf("[%s - %s %s - %s] - Successfully Waitlisted\n", data.CourseReferenceNumber, data.Subject, data.CourseNumber, data.CourseTitle)
+					t.record("Waitlisted", data.CourseReferenceNumber)
+					t.SendNotification(ctx, data.CourseTitle, fmt.Sprintf("Successful Waitlisted (%s)", data.CourseReferenceNumber))
 				} else if data.StatusDescription == "Deleted" || data.StatusDescription == "Dropped" || data.StatusDescription == "Web Drop" {
-					fmt.Printf("[%s - %s %s - %s] - Successfully Dropped\n", data.CourseReferenceNumber, data.Subject, data.CourseNumber, data.CourseTitle)
-					t.SendNotification(data.CourseTitle, fmt.Sprintf("Successful Drop (%s)", data.CourseReferenceNumber))
+					t.logf("[%s - %s %s - %s] - Successfully Dropped\n", data.CourseReferenceNumber, data.Subject, data.CourseNumber, data.CourseTitle)
+					t.record("Dropped", data.CourseReferenceNumber)
+					t.SendNotification(ctx, data.CourseTitle, fmt.Sprintf("Successful Drop (%s)", data.CourseReferenceNumber))
 				} else if data.StatusDescription == "Errors Preventing Registration" {
-					fmt.Printf("[%d] - Errors encountered processing [%s - %s %s - %s]\n", len(data.CrnErrors), data.CourseReferenceNumber, data.Subject, data.CourseNumber, data.CourseTitle)
+					t.logf("[%d] - Errors encountered processing [%s - %s %s - %s]\n", len(data.CrnErrors), data.CourseReferenceNumber, data.Subject, data.CourseNumber, data.CourseTitle)
+					crnError := &CRNError{CRN: data.CourseReferenceNumber}
 					for _, err := range data.CrnErrors {
-						fmt.Printf("%s\n", err.Message)
+						t.logf("%s\n", err.Message)
+						crnError.Messages = append(crnError.Messages, err.Message)
 					}
+					rejected = append(rejected, crnError)
 				} else {
-					fmt.Printf("[%s] Status: %s\n", data.CourseReferenceNumber, data.StatusDescription)
+					t.logf("[%s] Status: %s\n", data.CourseReferenceNumber, data.StatusDescription)
 				}
 			}
 		}
 	}
-	return nil
+	return errors.Join(rejected...)
+}
+
+func (t *Task) Signup(ctx context.Context) error {
+	t.HomepageURL = t.regURL("/StudentRegistrationSsb/saml/login")
+	defer t.Client.CloseIdleConnections()
+	if err := t.CheckSignup(ctx); err != nil {
+		return err
+	}
+	if t.DryRun {
+		return t.rehearse(ctx, t.newRegistration())
+	}
+	return t.register(ctx, t.newRegistration())
 }
 
-func (t *Task) Signup() error {
-	t.HomepageURL = "https://reg.oci.fhda.edu/StudentRegistrationSsb/saml/login"
-	t.SSOManagerURL = "https://ssb-prod.ec.fhda.edu/ssomanager/saml/SSO"
-	t.CheckAuthSession()
-	if err := t.GetRegistrationStatus(); err != nil {
+// Status logs in and reports whether registration is open for the term
+// without waiting for it or changing anything.
+func (t *Task) Status(ctx context.Context) error {
+	t.HomepageURL = t.regURL("/StudentRegistrationSsb/saml/login")
+	defer t.Client.CloseIdleConnections()
+
+	if err := t.CheckAuthSession(ctx); err != nil {
 		return err
 	}
-	t.VisitClassRegistration()
-	if err := t.AddCourses(); err != nil {
+	registrationStatus, err := t.fetchRegistrationStatus(ctx)
+	if err != nil {
 		return err
 	}
-	t.SendBatch()
-	t.Client.CloseIdleConnections()
+
+	if len(registrationStatus.StudentEligFailures) == 0 {
+		t.logf("Registration is open for %s\n", t.TermID)
+		return nil
+	}
+	t.logf("Registration is not open for %s:\n", t.TermID)
+	for _, failure := range registrationStatus.StudentEligFailures {
+		t.logf("  %s\n", failure)
+	}
 	return nil
 }
+
+// register runs one signup attempt end to end. It only touches shared Task
+// state through the session lock, so Watch may run several at once.
+func (t *Task) register(ctx context.Context, registration *Registration) error {
+	if err := t.CheckAuthSession(ctx); err != nil {
+		return err
+	}
+	if err := t.GetRegistrationStatus(ctx); err != nil {
+		return err
+	}
+	if err := t.VisitClassRegistration(ctx); err != nil {
+		return err
+	}
+	return t.submit(ctx, registration)
+}
+
+// submit queues and submits a registration's batch. CRNs the batch rejects
+// that still have alternates are replaced by their next alternate in a
+// follow-up batch, along with any drop they are swapped for, and their
+// rejections no longer count against the registration. Remaining swaps are
+// settled once the batch is in.
+func (t *Task) submit(ctx context.Context, registration *Registration) error {
+	queueErr := t.AddCourses(ctx, registration)
+	if queueErr != nil && (errors.Is(queueErr, ErrNothingToSubmit) || !errors.Is(queueErr, ErrCRNRejected)) {
+		return queueErr
+	}
+	if err := t.awaitSubmitTurn(ctx); err != nil {
+		return err
+	}
+	batchErr := t.SendBatch(ctx, registration)
+	t.Submitted()
+
+	followUp := &Registration{Waitlist: registration.Waitlist, Alternates: make(map[string][]string), Swaps: make(map[string]string)}
+	replaced := make(map[string]bool)
+	var kept []string
+	for _, CRN := range registration.CRNs {
+		if registration.added(CRN) {
+			kept = append(kept, CRN)
+		}
+	}
+	for i, CRN := range registration.CRNs {
+		if registration.Statuses[CRN] != "Errors Preventing Registration" {
+			continue
+		}
+		CRNs, err := t.nextAlternate(ctx, registration, i, append(append([]string(nil), kept...), followUp.CRNs...))
+		if err != nil {
+			return errors.Join(queueErr, batchErr, err)
+		}
+		if CRNs == nil {
+			continue
+		}
+		next := CRNs[0]
+		replaced[CRN] = true
+		followUp.CRNs = append(followUp.CRNs, CRNs...)
+		followUp.Alternates[next] = registration.Alternates[next]
+		if drop, ok := registration.Swaps[next]; ok {
+			followUp.Swaps[next] = drop
+			if slices.Contains(registration.DropCRNs, drop) && !registration.dropped(drop) {
+				followUp.DropCRNs = append(followUp.DropCRNs, drop)
+			}
+		}
+	}
+	swapErr := t.settleSwaps(ctx, registration, followUp.Swaps)
+	if len(followUp.CRNs) == 0 {
+		return errors.Join(queueErr, batchErr, swapErr)
+	}
+	batchErr = dropErrors(batchErr, func(err error) bool {
+		var crnError *CRNError
+		return errors.As(err, &crnError) && replaced[crnError.CRN]
+	})
+	// A follow-up with every alternate rejected up front has nothing to
+	// submit, but the first batch did; only the rejections matter.
+	followUpErr := dropErrors(t.submit(ctx, followUp), func(err error) bool {
+		return errors.Is(err, ErrNothingToSubmit)
+	})
+	return errors.Join(queueErr, batchErr, swapErr, followUpErr)
+}
+
+// dropErrors removes the errors matching drop from a joined error.
+func dropErrors(err error, drop func(error) bool) error {
+	errs := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	}
+	var kept []error
+	for _, err := range errs {
+		if err != nil && !drop(err) {
+			kept = append(kept, err)
+		}
+	}
+	return errors.Join(kept...)
+}
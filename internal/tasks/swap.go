@@ -0,0 +1,110 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// swappedFor returns the CRN a drop is swapped for, if it is paired.
+func (r *Registration) swappedFor(drop string) (string, bool) {
+	for CRN, paired := range r.Swaps {
+		if paired == drop {
+			return CRN, true
+		}
+	}
+	return "", false
+}
+
+// swapped reports whether a drop is paired with one of the task's CRNs.
+func (t *Task) swapped(drop string) bool {
+	for _, paired := range t.Swaps {
+		if paired == drop {
+			return true
+		}
+	}
+	return false
+}
+
+// added reports whether the batch registered or waitlisted a CRN.
+func (r *Registration) added(CRN string) bool {
+	switch r.Statuses[CRN] {
+	case "Registered", "Waitlisted":
+		return true
+	}
+	return false
+}
+
+// settleSwaps checks each swap of a submitted batch, except those handed on
+// to a follow-up batch. A drop Banner went through with although its paired
+// CRN was not added is rolled back by adding the class again, and an alert
+// goes out either way; a failed rollback is a *SwapError.
+func (t *Task) settleSwaps(ctx context.Context, registration *Registration, handedOn map[string]string) error {
+	var errs []error
+	for _, CRN := range registration.CRNs {
+		drop, ok := registration.Swaps[CRN]
+		if !ok || handedOn[CRN] != "" {
+			continue
+		}
+		dropped := registration.dropped(drop) || !slices.Contains(registration.DropCRNs, drop)
+		switch {
+		case registration.added(CRN):
+			if dropped {
+				t.logf("[%s] - Swapped In for %s\n", CRN, drop)
+			} else {
+				t.logf("[%s] - Added, but %s Could Not Be Dropped\n", CRN, drop)
+			}
+		case dropped:
+			if err := t.rollbackDrop(ctx, registration, CRN, drop); err != nil {
+				errs = append(errs, err)
+			}
+		case registration.Statuses[CRN] != "":
+			t.logf("[%s] - Keeping, Since %s Was Not Added\n", drop, CRN)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// rollbackDrop adds back a class that was dropped without its replacement,
+// as a regular registration and then, if that is refused, on the waitlist.
+// The registration's status for the drop becomes the restored one, so it no
+// longer counts as dropped, and a class registered again is taken out of
+// the task's result.
+func (t *Task) rollbackDrop(ctx context.Context, registration *Registration, CRN string, drop string) error {
+	t.logf("[%s] - Dropped Without Its Replacement %s, Adding It Back\n", drop, CRN)
+	var err error
+	for _, waitlist := range []bool{false, true} {
+		restore := &Registration{CRNs: []string{drop}, Waitlist: waitlist}
+		if err = t.AddCourses(ctx, restore); err != nil {
+			if errors.Is(err, ErrCRNRejected) {
+				continue
+			}
+			break
+		}
+		err = t.SendBatch(ctx, restore)
+		if restore.added(drop) {
+			t.logf("[%s] - Rolled Back Drop\n", drop)
+			if registration.Statuses == nil {
+				registration.Statuses = make(map[string]string)
+			}
+			registration.Statuses[drop] = restore.Statuses[drop]
+			// Back where it started, so the drop is not a change to report
+			if restore.Statuses[drop] == "Registered" {
+				t.unrecord("Dropped", drop)
+				t.unrecord("Registered", drop)
+			}
+			t.SendNotification(ctx, fmt.Sprintf("Rolled Back Drop (%s)", drop), fmt.Sprintf("%s was dropped but %s was not added, so %s was %s again.", drop, CRN, drop, restore.Statuses[drop]))
+			return nil
+		}
+		if err == nil {
+			err = fmt.Errorf("batch status %q", restore.Statuses[drop])
+		}
+		if !errors.Is(err, ErrCRNRejected) {
+			break
+		}
+	}
+	t.logf("[%s] - Could Not Roll Back Drop: %v\n", drop, err)
+	t.SendNotification(ctx, fmt.Sprintf("Swap Failed (%s)", drop), fmt.Sprintf("%s was dropped but %s was not added, and %s could not be added back: %v", drop, CRN, drop, err))
+	return &SwapError{CRN: CRN, DropCRN: drop, Err: err}
+}
@@ -2,43 +2,156 @@ package tasks
 
 import (
 	"bytes"
+	"context"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math/rand"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
 
 	http "github.com/bogdanfinn/fhttp"
-	tls_client "github.com/bogdanfinn/tls-client"
+
+	"register-bot/internal/export"
+	"register-bot/internal/history"
 )
 
 type Task struct {
-	Mode          string
-	Terms         map[string]string
-	Username      string
-	Password      string
-	Subject       string
-	CRNs          []string
-	DropCRNs      []string
-	TermID        string
-	UserAgent     string
-	Client        tls_client.HttpClient
-	Session       Session
-	WebhookURL    string
-	HomepageURL   string
-	SSOManagerURL string
-	WaitlistTask  bool
-}
-
-func (t *Task) MakeReq(method string, url string, headers [][2]string, body []byte) *http.Request {
-	req, err := http.NewRequest(method, url, bytes.NewBuffer(body))
+	Mode         string
+	Terms        map[string]string
+	Username     string
+	Password     string
+	Subject      string
+	CRNs         []string
+	DropCRNs     []string
+	TermID       string
+	UserAgent    string
+	Client       HTTPClient
+	Endpoints    Endpoints
+	Session      Session
+	WebhookURL   string
+	WebhookURLs  []string
+	HomepageURL  string
+	WaitlistTask bool
+	// RetryPolicy overrides DefaultRetryPolicy; RetryPolicies overrides it
+	// again per stage name, e.g. "Submitting Batch Update".
+	RetryPolicy   *RetryPolicy
+	RetryPolicies map[string]RetryPolicy
+	WatchOptions  WatchOptions
+	// Filters narrow the Search results.
+	Filters SearchFilters
+	// History, when set, records the seat counts Search, Catalog and Watch
+	// see.
+	History *history.Store
+	// Output is where Classes and Transcript write their export: a file, or
+	// a directory to put the default timestamped name in. Empty means the
+	// working directory.
+	Output string
+	// Format is the export format: csv, json, ndjson or sqlite. Empty picks
+	// it from Output's extension, falling back to csv.
+	Format string
+	// Alternates lists, for a CRN in CRNs, other sections of the same course
+	// Signup falls back on, in order, when that CRN is full or rejected.
+	Alternates map[string][]string
+	// DryRun makes Signup rehearse instead: everything up to the batch is
+	// checked and queued, the batch is printed and the worksheet cleared.
+	DryRun bool
+	// Swaps pairs a CRN in CRNs with the CRN in DropCRNs it replaces, so
+	// that class is only dropped if the CRN is added, and added back if
+	// Banner drops it anyway.
+	Swaps map[string]string
+	// Courses are the courses Schedule mode builds schedules from, e.g.
+	// "MATH 1C", ranked by Preferences. ScheduleLimit caps how many are
+	// listed.
+	Courses       []string
+	Preferences   SchedulePreferences
+	ScheduleLimit int
+	// OnConflict is what Signup does when its CRNs overlap in time: "refuse"
+	// (the default) or "warn".
+	OnConflict string
+
+	// SessionFile is where the login is saved, encrypted, for later runs to
+	// reuse; empty turns saving off.
+	SessionFile string
+	// SettingsFile, when set, is the settings.csv the task was loaded from,
+	// and SettingsRow its row there, counting the header as 0. The
+	// registration time Banner reports is saved in that row.
+	SettingsFile string
+	SettingsRow  int
+	// Account, when set, is the login the task shares with other tasks for
+	// the same username; Client should be the account's.
+	Account *Account
+	// Log receives the task's progress output; nil means standard output.
+	Log io.Writer
+	// Prompt, when set, asks the student for a one-time passcode the IdP
+	// wants after the password. Without it such a login fails with a
+	// *ChallengeError. PushTimeout bounds the wait for a push to be
+	// approved instead; zero means DefaultPushTimeout.
+	Prompt      func(label string) (string, error)
+	PushTimeout time.Duration
+	// SubmitOrder, when set, holds the task's first batch until every task
+	// of higher Priority in SubmitSlot has submitted.
+	SubmitOrder *SubmitOrder
+	SubmitSlot  string
+	Priority    int
+
+	// sessionMu serialises logging in when there is no Account; sessionIDMu
+	// guards Session.UniqueSessionId, which concurrent signups read.
+	sessionMu   sync.Mutex
+	sessionIDMu sync.Mutex
+	resultMu    sync.Mutex
+	result      Result
+	// ownJarMu orders requests against clearCookies when there is no
+	// Account.
+	ownJarMu sync.RWMutex
+
+	// sessionRestored is set once SessionFile has been tried.
+	sessionRestored bool
+	// submitOnce leaves SubmitOrder once.
+	submitOnce sync.Once
+	// checked is CRNs as CheckSignup last passed them, so a Release task
+	// checked before waiting is not looked up again at submit time.
+	checked []string
+}
+
+// logWriter is where the task's progress output goes: Log, or standard
+// output.
+func (t *Task) logWriter() io.Writer {
+	if t.Log != nil {
+		return t.Log
+	}
+	return os.Stdout
+}
+
+func (t *Task) logf(format string, args ...any) {
+	fmt.Fprintf(t.logWriter(), format, args...)
+}
+
+func (t *Task) logln(args ...any) {
+	fmt.Fprintln(t.logWriter(), args...)
+}
+
+// Result summarises what a Run did, for the caller to report or act on.
+type Result struct {
+	Mode       string
+	Registered []string
+	Waitlisted []string
+	Dropped    []string
+	Files      []string
+}
+
+func (t *Task) MakeReq(ctx context.Context, method string, url string, headers [][2]string, body []byte) *http.Request {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer(body))
 	if err != nil {
-		fmt.Println(err)
+		t.logln(err)
 	}
 	for _, header := range headers {
 		req.Header.Add(header[0], header[1])
@@ -46,34 +159,70 @@ func (t *Task) MakeReq(method string, url string, headers [][2]string, body []by
 	return req
 }
 
+// DoReq sends req. With default response handling, transport errors and
+// retryable error statuses are retried according to the stage's RetryPolicy,
+// and any error status or transport error that is given up on is returned
+// as an *HTTPError.
 func (t *Task) DoReq(req *http.Request, stage string, useDefaultResponseHandling bool) (*http.Response, error) {
-	fmt.Println(stage)
-	var resp *http.Response
-	resp, err := t.Client.Do(req)
-
-	if err != nil {
-		return resp, err
+	if !useDefaultResponseHandling {
+		t.logln(stage)
+		return t.send(req)
 	}
 
-	if useDefaultResponseHandling {
-		if resp != nil && (resp.StatusCode >= 400 && resp.StatusCode <= 499 || resp.StatusCode >= 500) {
-			body, _ := readBody(resp)
-			reader := strings.NewReader(string(body))
-			document, err := goquery.NewDocumentFromReader(reader)
+	policy := t.retryPolicy(stage)
+	for attempt := 1; ; attempt++ {
+		t.logln(stage)
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
 			if err != nil {
-				discardResp(resp)
-				fmt.Println(err)
+				return nil, err
 			}
-			message := getSelectorAttr(document, "meta[name='errorMessage']", "content")
-			fmt.Printf("Error %s [%d] %s\n", stage, resp.StatusCode, message)
-			time.Sleep(time.Second * 2)
-			return t.DoReq(req, stage, useDefaultResponseHandling)
+			req.Body = body
+		}
+
+		resp, err := t.send(req)
+		if err != nil {
+			if req.Context().Err() != nil || attempt >= policy.MaxAttempts {
+				return nil, &HTTPError{Stage: stage, Attempts: attempt, Err: err}
+			}
+			t.logf("Error %s: %v\n", stage, err)
+		} else if resp.StatusCode < 400 {
+			return resp, nil
+		} else {
+			message := errorMessage(resp)
+			t.logf("Error %s [%d] %s\n", stage, resp.StatusCode, message)
+			if !policy.retryable(resp.StatusCode) || attempt >= policy.MaxAttempts {
+				return nil, &HTTPError{Stage: stage, StatusCode: resp.StatusCode, Message: message, Attempts: attempt}
+			}
+		}
+
+		if err := sleepCtx(req.Context(), policy.delay(attempt, resp)); err != nil {
+			return nil, err
 		}
 	}
-	return resp, err
 }
 
-func (t *Task) SendNotification(action string, message string) {
+// send sends req on the task's client, holding off clearCookies until the
+// client has read its cookie jar.
+func (t *Task) send(req *http.Request) (*http.Response, error) {
+	mu := t.jarMu()
+	mu.RLock()
+	defer mu.RUnlock()
+	return t.Client.Do(req)
+}
+
+// errorMessage reads Banner's meta[name='errorMessage'] from an error page
+// and closes the body.
+func errorMessage(resp *http.Response) string {
+	defer discardResp(resp)
+	document, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return ""
+	}
+	return getSelectorAttr(document, "meta[name='errorMessage']", "content")
+}
+
+func (t *Task) SendNotification(ctx context.Context, action string, message string) error {
 	payload := WebhookPayload{
 		Username: "register-bot",
 		Embeds: []Embed{
@@ -97,11 +246,127 @@ func (t *Task) SendNotification(action string, message string) {
 		{"user-agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/137.0.0.0 Safari/537.36"},
 	}
 
-	t.DoReq(t.MakeReq("POST", t.WebhookURL, headers, []byte(string(jsonData))), "Sending Notification", false)
+	var errs []error
+	for _, webhookURL := range t.webhooks() {
+		response, err := t.DoReq(t.MakeReq(ctx, "POST", webhookURL, headers, []byte(string(jsonData))), "Sending Notification", false)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		discardResp(response)
+	}
+	return errors.Join(errs...)
+}
+
+// webhooks returns WebhookURL followed by WebhookURLs, without blanks or
+// duplicates.
+func (t *Task) webhooks() []string {
+	seen := make(map[string]bool)
+	var urls []string
+	for _, webhookURL := range append([]string{t.WebhookURL}, t.WebhookURLs...) {
+		if webhookURL == "" || seen[webhookURL] {
+			continue
+		}
+		seen[webhookURL] = true
+		urls = append(urls, webhookURL)
+	}
+	return urls
+}
+
+// sleepCtx waits for d or until ctx is cancelled, whichever comes first.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (t *Task) record(status string, crn string) {
+	t.resultMu.Lock()
+	defer t.resultMu.Unlock()
+	switch status {
+	case "Registered":
+		t.result.Registered = append(t.result.Registered, crn)
+	case "Waitlisted":
+		t.result.Waitlisted = append(t.result.Waitlisted, crn)
+	case "Dropped":
+		t.result.Dropped = append(t.result.Dropped, crn)
+	case "File":
+		t.result.Files = append(t.result.Files, crn)
+	}
+}
+
+// unrecord takes back the last record of crn with status, for a change
+// that was later undone.
+func (t *Task) unrecord(status string, crn string) {
+	t.resultMu.Lock()
+	defer t.resultMu.Unlock()
+	var list *[]string
+	switch status {
+	case "Registered":
+		list = &t.result.Registered
+	case "Waitlisted":
+		list = &t.result.Waitlisted
+	case "Dropped":
+		list = &t.result.Dropped
+	default:
+		return
+	}
+	for i := len(*list) - 1; i >= 0; i-- {
+		if (*list)[i] == crn {
+			*list = slices.Delete(*list, i, i+1)
+			return
+		}
+	}
+}
+
+// outputPath resolves where an export named defaultName should be written.
+// An Output ending in a slash is a directory and is created if needed.
+func (t *Task) outputPath(defaultName string) string {
+	if t.Output == "" {
+		return defaultName
+	}
+	if strings.HasSuffix(t.Output, "/") || strings.HasSuffix(t.Output, string(filepath.Separator)) {
+		if err := os.MkdirAll(t.Output, 0o755); err != nil {
+			t.logln("Error creating output directory:", err)
+		}
+	}
+	if info, err := os.Stat(t.Output); err == nil && info.IsDir() {
+		return filepath.Join(t.Output, defaultName)
+	}
+	return t.Output
+}
+
+// writeExport writes dataset in the task's format to Output, or to a default
+// file named from base, and records the file in the Result.
+func (t *Task) writeExport(base string, dataset export.Dataset) error {
+	format := t.Format
+	if format == "" {
+		format = export.FormatOf(t.Output)
+	}
+	if format == "" {
+		format = "csv"
+	}
+	exporter, err := export.Lookup(format)
+	if err != nil {
+		return err
+	}
+
+	fileName := t.outputPath(exporter.FileName(base, time.Now()))
+	t.logf("Writing %s\n", fileName)
+	if err := exporter.Export(fileName, dataset); err != nil {
+		return err
+	}
+	t.record("File", fileName)
+	return nil
 }
 
 func discardResp(resp *http.Response) {
-	if resp.Body != nil {
+	if resp != nil && resp.Body != nil {
 		io.Copy(io.Discard, resp.Body)
 		defer resp.Body.Close()
 	}
@@ -154,18 +419,36 @@ func formatDuration(time time.Duration) string {
 	return fmt.Sprintf("%dd %dh %dm %ds", days, hours, minutes, seconds)
 }
 
-func saveRegistrationTime(registrationTime string) {
-	file, err := os.Open("config/settings.csv")
+// settingsMu serialises rewrites of settings.csv, which every task loaded
+// from it shares.
+var settingsMu sync.Mutex
+
+// saveRegistrationTime writes the registration time Banner reported into
+// the task's own row of SettingsFile. It does nothing for tasks that were
+// not loaded from settings.csv.
+func (t *Task) saveRegistrationTime(registrationTime string) {
+	if t.SettingsFile == "" {
+		return
+	}
+	settingsMu.Lock()
+	defer settingsMu.Unlock()
+
+	file, err := os.Open(t.SettingsFile)
 	if err != nil {
-		fmt.Println("Error Opening config/settings.csv:", err)
+		t.logf("Error Opening %s: %v\n", t.SettingsFile, err)
 		return
 	}
 	defer file.Close()
 
 	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
 	records, err := reader.ReadAll()
 	if err != nil {
-		fmt.Println("Error Reading config/settings.csv:", err)
+		t.logf("Error Reading %s: %v\n", t.SettingsFile, err)
+		return
+	}
+	if len(records) == 0 {
+		t.logf("Error Reading %s: no header\n", t.SettingsFile)
 		return
 	}
 
@@ -181,21 +464,22 @@ func saveRegistrationTime(registrationTime string) {
 	}
 
 	if !found {
-		fmt.Println("SavedRegistrationTime field not found in config/settings.csv")
+		t.logf("SavedRegistrationTime field not found in %s\n", t.SettingsFile)
 		return
 	}
 
-	for i := 1; i < len(records); i++ {
-		if len(records[i]) <= timeIndex {
-			fmt.Println("Invalid Row, Missing SavedRegistrationTime field")
-			continue
-		}
-		records[i][timeIndex] = registrationTime
+	if t.SettingsRow < 1 || t.SettingsRow >= len(records) || len(records[t.SettingsRow]) <= timeIndex {
+		t.logf("Invalid Row %d in %s, Missing SavedRegistrationTime field\n", t.SettingsRow, t.SettingsFile)
+		return
 	}
+	if records[t.SettingsRow][timeIndex] == registrationTime {
+		return
+	}
+	records[t.SettingsRow][timeIndex] = registrationTime
 
-	outputFile, err := os.Create("config/settings.csv")
+	outputFile, err := os.Create(t.SettingsFile)
 	if err != nil {
-		fmt.Println("Error creating file:", err)
+		t.logf("Error Creating %s: %v\n", t.SettingsFile, err)
 		return
 	}
 	defer outputFile.Close()
@@ -203,38 +487,56 @@ func saveRegistrationTime(registrationTime string) {
 	writer := csv.NewWriter(outputFile)
 	err = writer.WriteAll(records)
 	if err != nil {
-		fmt.Println("Error writing config/settings.csv :", err)
+		t.logf("Error Writing %s: %v\n", t.SettingsFile, err)
 		return
 	}
 
-	writer.Flush()
-	if err := writer.Error(); err != nil {
-		fmt.Println("Error flushing writer: ", err)
-		return
-	}
-
-	fmt.Println("Saved Registration Time")
+	t.logln("Saved Registration Time")
 }
 
-func (t *Task) Run() {
+func (t *Task) Run(ctx context.Context) (Result, error) {
 	// Default to Watch mode if Mode is empty or not recognized
 	if t.Mode == "" {
 		t.Mode = "Watch"
 	}
 
+	t.resultMu.Lock()
+	t.result = Result{}
+	t.resultMu.Unlock()
+	// A task that ends without submitting must not hold up the others
+	defer t.Submitted()
+
+	var err error
 	if t.Mode == "Signup" {
-		t.Signup()
-	} else if t.Mode == "Classes" {
-		t.Classes()
+		err = t.Signup(ctx)
+	} else if t.Mode == "Search" || t.Mode == "Classes" {
+		err = t.Classes(ctx)
+	} else if t.Mode == "Catalog" {
+		err = t.Catalog(ctx)
 	} else if t.Mode == "Transcript" {
-		t.HomepageURL = "https://dw-prod.ec.fhda.edu/responsiveDashboard/worksheets/WEB31"
-		t.Transcript()
+		t.HomepageURL = t.dwURL("/responsiveDashboard/worksheets/WEB31")
+		err = t.Transcript(ctx)
 	} else if t.Mode == "Watch" {
-		t.Watch()
+		err = t.Watch(ctx)
+	} else if t.Mode == "Status" {
+		err = t.Status(ctx)
+	} else if t.Mode == "Schedule" {
+		err = t.PlanSchedules(ctx)
+	} else if t.Mode == "Validate" {
+		err = t.CheckSignup(ctx)
+	} else if t.Mode == "Current" {
+		err = t.ShowCurrentSchedule(ctx)
+	} else if t.Mode == "Reconcile" {
+		err = t.Reconcile(ctx)
 	} else {
 		// Unknown mode, default to Watch
-		fmt.Printf("Unknown mode '%s', defaulting to Watch mode\n", t.Mode)
+		t.logf("Unknown mode '%s', defaulting to Watch mode\n", t.Mode)
 		t.Mode = "Watch"
-		t.Watch()
+		err = t.Watch(ctx)
 	}
+
+	t.resultMu.Lock()
+	defer t.resultMu.Unlock()
+	t.result.Mode = t.Mode
+	return t.result, err
 }
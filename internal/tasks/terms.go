@@ -1,6 +1,7 @@
 package tasks
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strconv"
@@ -8,15 +9,15 @@ import (
 	"time"
 )
 
-func (t *Task) GetTerms() error {
+func (t *Task) GetTerms(ctx context.Context) error {
 	headers := [][2]string{
 		{"accept", "application/json"},
 		{"accept-language", "en-US,en;q=0.9"},
 		{"user-agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/137.0.0.0 Safari/537.36"},
 	}
-	response, err := t.DoReq(t.MakeReq("GET", fmt.Sprintf("https://reg.oci.fhda.edu/StudentRegistrationSsb/ssb/classSearch/getTerms?searchTerm=&offset=1&max=100&_=%v", time.Now().UnixNano()/int64(time.Millisecond)), headers, nil), "Getting Terms", true)
+	response, err := t.DoReq(t.MakeReq(ctx, "GET", t.regURL(fmt.Sprintf("/StudentRegistrationSsb/ssb/classSearch/getTerms?searchTerm=&offset=1&max=100&_=%v", time.Now().UnixNano()/int64(time.Millisecond))), headers, nil), "Getting Terms", true)
 	if err != nil {
-		fmt.Println(err)
+		t.logln(err)
 		discardResp(response)
 		return err
 	}
@@ -33,31 +34,44 @@ func (t *Task) GetTerms() error {
 	return nil
 }
 
-func BuildTermId(term string) string {
-
-	fmt.Println("Building Term ID (Offline)")
-	var campus string
+// BuildTermId works out a term's code from a name like "2026 Winter De
+// Anza" without asking Banner.
+func BuildTermId(term string) (string, error) {
 	data := strings.Fields(term)
-	year, quarter := data[0], data[1]
-
-	if len(data) == 4 {
-		campus = "De Anza"
+	if len(data) < 3 {
+		return "", fmt.Errorf("term %q should look like \"2026 Winter De Anza\" or \"2026 Fall Foothill\"", term)
 	}
-	quarterCode := QuarterCodes[quarter]
-	campusCode := CampusCodes[campus]
+	year, quarter, campus := data[0], data[1], strings.Join(data[2:], " ")
 
-	yearInt, _ := strconv.Atoi(year)
+	yearInt, err := strconv.Atoi(year)
+	quarterCode, quarterOK := QuarterCodes[quarter]
+	campusCode, campusOK := CampusCodes[campus]
+	if err != nil || len(year) != 4 || !quarterOK || !campusOK {
+		return "", fmt.Errorf("term %q should look like \"2026 Winter De Anza\" or \"2026 Fall Foothill\"", term)
+	}
 	if quarter == "Summer" {
 		yearInt++
 	}
 
-	return fmt.Sprintf("%d%d%d", yearInt, quarterCode, campusCode)
+	return fmt.Sprintf("%d%d%d", yearInt, quarterCode, campusCode), nil
 }
 
-func (t *Task) GetTermByName(term string) {
-	t.GetTerms()
+// GetTermByName sets TermID from the terms Banner offers, building it from
+// the name when Banner does not list the term yet. An error getting the
+// terms is returned rather than guessed past.
+func (t *Task) GetTermByName(ctx context.Context, term string) error {
+	if err := t.GetTerms(ctx); err != nil {
+		return fmt.Errorf("getting terms: %w", err)
+	}
 	t.TermID = t.Terms[term]
-	if t.Terms[term] == "" {
-		t.TermID = BuildTermId(term)
+	if t.TermID != "" {
+		return nil
 	}
+	t.logln("Building Term ID (Offline)")
+	termID, err := BuildTermId(term)
+	if err != nil {
+		return err
+	}
+	t.TermID = termID
+	return nil
 }
@@ -1,11 +1,11 @@
 package tasks
 
 import (
-	"encoding/csv"
+	"context"
 	"encoding/json"
 	"fmt"
-	"os"
-	"time"
+
+	"register-bot/internal/export"
 )
 
 type TranscriptSession struct {
@@ -17,16 +17,16 @@ type TranscriptSession struct {
 	SchoolDescription string
 }
 
-func (t *Task) GetStudentData() error {
+func (t *Task) GetStudentData(ctx context.Context) error {
 	headers := [][2]string{
 		{"accept", "*/*"},
 		{"accept-language", "en-US,en;q=0.9"},
 		{"user-agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/121.0.0.0 Safari/537.36"},
 	}
 
-	response, err := t.DoReq(t.MakeReq("GET", "https://dw-prod.ec.fhda.edu/responsiveDashboard/api/students/myself", headers, nil), "Getting Student Data", true)
+	response, err := t.DoReq(t.MakeReq(ctx, "GET", t.dwURL("/responsiveDashboard/api/students/myself"), headers, nil), "Getting Student Data", true)
 	if err != nil {
-		fmt.Println(err)
+		t.logln(err)
 		discardResp(response)
 		return err
 	}
@@ -38,7 +38,13 @@ func (t *Task) GetStudentData() error {
 
 	var transcriptSession TranscriptSession
 
+	if len(userInfo.Embedded.Students) == 0 {
+		return ErrNotLoggedIn
+	}
 	for _, student := range userInfo.Embedded.Students {
+		if len(student.Goals) == 0 {
+			return fmt.Errorf("student %s has no degree goals", student.ID)
+		}
 		transcriptSession.Name = student.Name
 		transcriptSession.UserId = student.ID
 		transcriptSession.SchoolKey = student.Goals[0].School.Key
@@ -47,20 +53,19 @@ func (t *Task) GetStudentData() error {
 		transcriptSession.DegreeDescription = student.Goals[0].Degree.Description
 	}
 
-	t.GetAudit(transcriptSession)
-	return nil
+	return t.GetAudit(ctx, transcriptSession)
 }
 
-func (t *Task) GetAudit(transcriptSession TranscriptSession) error {
+func (t *Task) GetAudit(ctx context.Context, transcriptSession TranscriptSession) error {
 	headers := [][2]string{
 		{"accept", "*/*"},
 		{"accept-language", "en-US,en;q=0.9"},
 		{"user-agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/121.0.0.0 Safari/537.36"},
 	}
 
-	response, err := t.DoReq(t.MakeReq("GET", fmt.Sprintf("https://dw-prod.ec.fhda.edu/responsiveDashboard/api/audit?studentId=%s&school=%s&degree=%s&is-process-new=false&audit-type=AA&auditId=&include-inprogress=true&include-preregistered=true&aid-term=", transcriptSession.UserId, transcriptSession.SchoolKey, transcriptSession.Degree), headers, nil), "Getting Audit", true)
+	response, err := t.DoReq(t.MakeReq(ctx, "GET", t.dwURL(fmt.Sprintf("/responsiveDashboard/api/audit?studentId=%s&school=%s&degree=%s&is-process-new=false&audit-type=AA&auditId=&include-inprogress=true&include-preregistered=true&aid-term=", transcriptSession.UserId, transcriptSession.SchoolKey, transcriptSession.Degree)), headers, nil), "Getting Audit", true)
 	if err != nil {
-		fmt.Println(err)
+		t.logln(err)
 		discardResp(response)
 		return err
 	}
@@ -83,48 +88,40 @@ func (t *Task) GetAudit(transcriptSession TranscriptSession) error {
 		}
 		auditInfo = append(auditInfo, classInfo)
 	}
-	t.ExportTranscriptData(transcriptSession, auditInfo)
-	return nil
+	return t.ExportTranscriptData(transcriptSession, auditInfo)
 }
 
-func (t *Task) ExportTranscriptData(transcriptSession TranscriptSession, auditInfo []AuditInfo) error {
-	currentTime := time.Now()
-	fileName := fmt.Sprintf("%s-%s-%s.csv", transcriptSession.Name, transcriptSession.Degree, currentTime.Format("2006-01-02_15-04-05"))
-	file, err := os.Create(fileName)
-	if err != nil {
-		fmt.Println(err)
-	}
-	defer file.Close()
-
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+var transcriptColumns = []export.Column{
+	{Name: "Term", Key: "term"},
+	{Name: "Subject", Key: "subject"},
+	{Name: "Number", Key: "number"},
+	{Name: "Course Title", Key: "course_title"},
+	{Name: "Letter Grade", Key: "letter_grade"},
+	{Name: "Credits", Key: "credits"},
+}
 
-	header := []string{"Term", "Subject", "Number", "Course Title", "Letter Grade", "Credits"}
-	fmt.Printf("Writing %s\n", fileName)
-	err = writer.Write(header)
-	if err != nil {
-		return err
-	}
+func (t *Task) ExportTranscriptData(transcriptSession TranscriptSession, auditInfo []AuditInfo) error {
+	dataset := export.Dataset{Name: "transcript", Columns: transcriptColumns}
 	for _, audit := range auditInfo {
-		record := []string{
+		dataset.Rows = append(dataset.Rows, []any{
 			audit.Term,
 			audit.Subject,
 			audit.Number,
 			audit.CourseTitle,
 			audit.LetterGrade,
 			audit.Credits,
-		}
-		err = writer.Write(record)
-		if err != nil {
-			return err
-		}
+		})
 	}
-	fmt.Println("Exported Transcript Data")
+	if err := t.writeExport(fmt.Sprintf("%s-%s-", transcriptSession.Name, transcriptSession.Degree), dataset); err != nil {
+		return err
+	}
+	t.logln("Exported Transcript Data")
 	return nil
 }
 
-func (t *Task) Transcript() error {
-	t.GenSession()
-	t.GetStudentData()
-	return nil
+func (t *Task) Transcript(ctx context.Context) error {
+	if err := t.GenSession(ctx); err != nil {
+		return err
+	}
+	return t.GetStudentData(ctx)
 }
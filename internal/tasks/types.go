@@ -5,6 +5,11 @@ type Terms []struct {
 	Description string `json:"description"`
 }
 
+type Subject struct {
+	Code        string `json:"code"`
+	Description string `json:"description"`
+}
+
 var QuarterCodes = map[string]int{
 	"Summer": 1,
 	"Fall":   2,
@@ -261,99 +266,13 @@ type Embed struct {
 }
 
 type Courses struct {
-	Success    bool `json:"success"`
-	TotalCount int  `json:"totalCount"`
-	Data       []struct {
-		ID                      int    `json:"id"`
-		Term                    string `json:"term"`
-		TermDesc                string `json:"termDesc"`
-		CourseReferenceNumber   string `json:"courseReferenceNumber"`
-		PartOfTerm              string `json:"partOfTerm"`
-		CourseNumber            string `json:"courseNumber"`
-		Subject                 string `json:"subject"`
-		SubjectDescription      string `json:"subjectDescription"`
-		SequenceNumber          string `json:"sequenceNumber"`
-		CampusDescription       string `json:"campusDescription"`
-		ScheduleTypeDescription string `json:"scheduleTypeDescription"`
-		CourseTitle             string `json:"courseTitle"`
-		CreditHours             any    `json:"creditHours"`
-		MaximumEnrollment       int    `json:"maximumEnrollment"`
-		Enrollment              int    `json:"enrollment"`
-		SeatsAvailable          int    `json:"seatsAvailable"`
-		WaitCapacity            int    `json:"waitCapacity"`
-		WaitCount               int    `json:"waitCount"`
-		WaitAvailable           int    `json:"waitAvailable"`
-		CrossList               any    `json:"crossList"`
-		CrossListCapacity       any    `json:"crossListCapacity"`
-		CrossListCount          any    `json:"crossListCount"`
-		CrossListAvailable      any    `json:"crossListAvailable"`
-		CreditHourHigh          any    `json:"creditHourHigh"`
-		CreditHourLow           any    `json:"creditHourLow"`
-		CreditHourIndicator     any    `json:"creditHourIndicator"`
-		OpenSection             bool   `json:"openSection"`
-		LinkIdentifier          any    `json:"linkIdentifier"`
-		IsSectionLinked         bool   `json:"isSectionLinked"`
-		SubjectCourse           string `json:"subjectCourse"`
-		Faculty                 []struct {
-			BannerID              string `json:"bannerId"`
-			Category              any    `json:"category"`
-			Class                 string `json:"class"`
-			CourseReferenceNumber string `json:"courseReferenceNumber"`
-			DisplayName           string `json:"displayName"`
-			EmailAddress          any    `json:"emailAddress"`
-			PrimaryIndicator      bool   `json:"primaryIndicator"`
-			Term                  string `json:"term"`
-		} `json:"faculty"`
-		MeetingsFaculty []struct {
-			Category              string `json:"category"`
-			Class                 string `json:"class"`
-			CourseReferenceNumber string `json:"courseReferenceNumber"`
-			Faculty               []any  `json:"faculty"`
-			MeetingTime           struct {
-				BeginTime              string  `json:"beginTime"`
-				Building               string  `json:"building"`
-				BuildingDescription    string  `json:"buildingDescription"`
-				Campus                 string  `json:"campus"`
-				CampusDescription      string  `json:"campusDescription"`
-				Category               string  `json:"category"`
-				Class                  string  `json:"class"`
-				CourseReferenceNumber  string  `json:"courseReferenceNumber"`
-				CreditHourSession      float64 `json:"creditHourSession"`
-				EndDate                string  `json:"endDate"`
-				EndTime                string  `json:"endTime"`
-				Friday                 bool    `json:"friday"`
-				HoursWeek              float64 `json:"hoursWeek"`
-				MeetingScheduleType    string  `json:"meetingScheduleType"`
-				MeetingType            string  `json:"meetingType"`
-				MeetingTypeDescription string  `json:"meetingTypeDescription"`
-				Monday                 bool    `json:"monday"`
-				Room                   string  `json:"room"`
-				Saturday               bool    `json:"saturday"`
-				StartDate              string  `json:"startDate"`
-				Sunday                 bool    `json:"sunday"`
-				Term                   string  `json:"term"`
-				Thursday               bool    `json:"thursday"`
-				Tuesday                bool    `json:"tuesday"`
-				Wednesday              bool    `json:"wednesday"`
-			} `json:"meetingTime"`
-			Term string `json:"term"`
-		} `json:"meetingsFaculty"`
-		ReservedSeatSummary any `json:"reservedSeatSummary"`
-		SectionAttributes   []struct {
-			Class                 string `json:"class"`
-			Code                  string `json:"code"`
-			CourseReferenceNumber string `json:"courseReferenceNumber"`
-			Description           string `json:"description"`
-			IsZTCAttribute        bool   `json:"isZTCAttribute"`
-			TermCode              string `json:"termCode"`
-		} `json:"sectionAttributes"`
-		InstructionalMethod            string `json:"instructionalMethod"`
-		InstructionalMethodDescription string `json:"instructionalMethodDescription"`
-	} `json:"data"`
-	PageOffset           int    `json:"pageOffset"`
-	PageMaxSize          int    `json:"pageMaxSize"`
-	SectionsFetchedCount int    `json:"sectionsFetchedCount"`
-	PathMode             string `json:"pathMode"`
+	Success              bool            `json:"success"`
+	TotalCount           int             `json:"totalCount"`
+	Data                 []CourseSection `json:"data"`
+	PageOffset           int             `json:"pageOffset"`
+	PageMaxSize          int             `json:"pageMaxSize"`
+	SectionsFetchedCount int             `json:"sectionsFetchedCount"`
+	PathMode             string          `json:"pathMode"`
 	SearchResultsConfigs []struct {
 		Config   string `json:"config"`
 		Display  string `json:"display"`
@@ -364,6 +283,96 @@ type Courses struct {
 	ZtcEncodedImage string `json:"ztcEncodedImage"`
 }
 
+type CourseSection struct {
+	ID                      int    `json:"id"`
+	Term                    string `json:"term"`
+	TermDesc                string `json:"termDesc"`
+	CourseReferenceNumber   string `json:"courseReferenceNumber"`
+	PartOfTerm              string `json:"partOfTerm"`
+	CourseNumber            string `json:"courseNumber"`
+	Subject                 string `json:"subject"`
+	SubjectDescription      string `json:"subjectDescription"`
+	SequenceNumber          string `json:"sequenceNumber"`
+	CampusDescription       string `json:"campusDescription"`
+	ScheduleTypeDescription string `json:"scheduleTypeDescription"`
+	CourseTitle             string `json:"courseTitle"`
+	CreditHours             any    `json:"creditHours"`
+	MaximumEnrollment       int    `json:"maximumEnrollment"`
+	Enrollment              int    `json:"enrollment"`
+	SeatsAvailable          int    `json:"seatsAvailable"`
+	WaitCapacity            int    `json:"waitCapacity"`
+	WaitCount               int    `json:"waitCount"`
+	WaitAvailable           int    `json:"waitAvailable"`
+	CrossList               any    `json:"crossList"`
+	CrossListCapacity       any    `json:"crossListCapacity"`
+	CrossListCount          any    `json:"crossListCount"`
+	CrossListAvailable      any    `json:"crossListAvailable"`
+	CreditHourHigh          any    `json:"creditHourHigh"`
+	CreditHourLow           any    `json:"creditHourLow"`
+	CreditHourIndicator     any    `json:"creditHourIndicator"`
+	OpenSection             bool   `json:"openSection"`
+	LinkIdentifier          any    `json:"linkIdentifier"`
+	IsSectionLinked         bool   `json:"isSectionLinked"`
+	SubjectCourse           string `json:"subjectCourse"`
+	Faculty                 []struct {
+		BannerID              string `json:"bannerId"`
+		Category              any    `json:"category"`
+		Class                 string `json:"class"`
+		CourseReferenceNumber string `json:"courseReferenceNumber"`
+		DisplayName           string `json:"displayName"`
+		EmailAddress          any    `json:"emailAddress"`
+		PrimaryIndicator      bool   `json:"primaryIndicator"`
+		Term                  string `json:"term"`
+	} `json:"faculty"`
+	MeetingsFaculty []struct {
+		Category              string      `json:"category"`
+		Class                 string      `json:"class"`
+		CourseReferenceNumber string      `json:"courseReferenceNumber"`
+		Faculty               []any       `json:"faculty"`
+		MeetingTime           MeetingTime `json:"meetingTime"`
+		Term                  string      `json:"term"`
+	} `json:"meetingsFaculty"`
+	ReservedSeatSummary any `json:"reservedSeatSummary"`
+	SectionAttributes   []struct {
+		Class                 string `json:"class"`
+		Code                  string `json:"code"`
+		CourseReferenceNumber string `json:"courseReferenceNumber"`
+		Description           string `json:"description"`
+		IsZTCAttribute        bool   `json:"isZTCAttribute"`
+		TermCode              string `json:"termCode"`
+	} `json:"sectionAttributes"`
+	InstructionalMethod            string `json:"instructionalMethod"`
+	InstructionalMethodDescription string `json:"instructionalMethodDescription"`
+}
+
+type MeetingTime struct {
+	BeginTime              string  `json:"beginTime"`
+	Building               string  `json:"building"`
+	BuildingDescription    string  `json:"buildingDescription"`
+	Campus                 string  `json:"campus"`
+	CampusDescription      string  `json:"campusDescription"`
+	Category               string  `json:"category"`
+	Class                  string  `json:"class"`
+	CourseReferenceNumber  string  `json:"courseReferenceNumber"`
+	CreditHourSession      float64 `json:"creditHourSession"`
+	EndDate                string  `json:"endDate"`
+	EndTime                string  `json:"endTime"`
+	Friday                 bool    `json:"friday"`
+	HoursWeek              float64 `json:"hoursWeek"`
+	MeetingScheduleType    string  `json:"meetingScheduleType"`
+	MeetingType            string  `json:"meetingType"`
+	MeetingTypeDescription string  `json:"meetingTypeDescription"`
+	Monday                 bool    `json:"monday"`
+	Room                   string  `json:"room"`
+	Saturday               bool    `json:"saturday"`
+	StartDate              string  `json:"startDate"`
+	Sunday                 bool    `json:"sunday"`
+	Term                   string  `json:"term"`
+	Thursday               bool    `json:"thursday"`
+	Tuesday                bool    `json:"tuesday"`
+	Wednesday              bool    `json:"wednesday"`
+}
+
 type CourseInfo struct {
 	TermDesc              string
 	CourseReferenceNumber string
@@ -382,6 +391,8 @@ type CourseInfo struct {
 	Enrollment            int
 	SeatsAvailable        int
 	WaitAvailable         int
+	LinkGroup             string
+	LinkedCRNs            string
 }
 
 type UserInfo struct {
@@ -568,7 +579,7 @@ type Audit struct {
 					Text    string `json:"text"`
 				} `json:"creditsAppliedTowardsDegree,omitempty"`
 			} `json:"qualifierArray"`
-		} `json:"header,omitempty"`
+		} `json:"-"` // ambiguous with Header0; encoding/json ignores both
 		RuleArray []struct {
 			PercentComplete   string `json:"percentComplete"`
 			RuleID            string `json:"ruleId"`
@@ -694,12 +705,12 @@ type Audit struct {
 						} `json:"requirement"`
 					} `json:"ruleArray"`
 				} `json:"elsePart"`
-			} `json:"requirement,omitempty"`
+			} `json:"-"` // ambiguous with Requirement0; encoding/json ignores both
 			LabelTag     string `json:"labelTag,omitempty"`
 			Requirement0 struct {
 				NumBlocktypes string `json:"numBlocktypes"`
 				Type          string `json:"type"`
-			} `json:"requirement,omitempty"`
+			} `json:"-"`
 			Advice struct {
 				TitleList []string `json:"titleList"`
 			} `json:"advice,omitempty"`
@@ -716,7 +727,7 @@ type Audit struct {
 			Remark struct {
 				TextList []string `json:"textList"`
 			} `json:"remark"`
-		} `json:"header,omitempty"`
+		} `json:"-"`
 		Major1 string `json:"major1,omitempty"`
 	} `json:"blockArray"`
 	ClassInformation struct {
@@ -886,3 +897,35 @@ type Audit struct {
 		Cfg020TIEBREAK string `json:"cfg020TIEBREAK"`
 	} `json:"flags"`
 }
+
+type FacultyMeetingTimes struct {
+	Fmt []struct {
+		Category              string      `json:"category"`
+		Class                 string      `json:"class"`
+		CourseReferenceNumber string      `json:"courseReferenceNumber"`
+		Faculty               []any       `json:"faculty"`
+		MeetingTime           MeetingTime `json:"meetingTime"`
+		Term                  string      `json:"term"`
+	} `json:"fmt"`
+}
+
+type LinkedSections struct {
+	LinkedData [][]CourseSection `json:"linkedData"`
+}
+
+type RegistrationHistory struct {
+	Success bool `json:"success"`
+	Data    struct {
+		Registrations []struct {
+			CourseReferenceNumber               string  `json:"courseReferenceNumber"`
+			Term                                string  `json:"term"`
+			Subject                             string  `json:"subject"`
+			CourseNumber                        string  `json:"courseNumber"`
+			SequenceNumber                      string  `json:"sequenceNumber"`
+			CourseTitle                         string  `json:"courseTitle"`
+			CourseRegistrationStatusDescription string  `json:"courseRegistrationStatusDescription"`
+			CreditHour                          float64 `json:"creditHour"`
+			WaitlistPosition                    int     `json:"waitlistPosition"`
+		} `json:"registrations"`
+	} `json:"data"`
+}
@@ -1,8 +1,12 @@
 package tasks
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"math/rand"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -11,7 +15,48 @@ import (
 	"github.com/PuerkitoBio/goquery"
 )
 
-func (t *Task) CheckEnrollmentData(CRN string) error {
+// WatchOptions tunes the Watch loop.
+type WatchOptions struct {
+	// Interval is how often each CRN is polled; Intervals overrides it per CRN.
+	Interval  time.Duration
+	Intervals map[string]time.Duration
+	// Jitter spreads each poll by up to this fraction of the interval. Zero
+	// uses DefaultWatchOptions.Jitter; a negative value polls on a fixed
+	// cadence.
+	Jitter float64
+	// MaxDuration stops the watch after this long. Zero watches until every
+	// CRN has been signed up for or the context is cancelled.
+	MaxDuration time.Duration
+}
+
+var DefaultWatchOptions = WatchOptions{
+	Interval: 5 * time.Second,
+	Jitter:   0.1,
+}
+
+// maxWatchSignups is how many times Watch tries to sign up for an open CRN
+// that keeps getting rejected before it stops watching it.
+const maxWatchSignups = 3
+
+type Enrollment struct {
+	CRN                    string
+	EnrollmentActual       int
+	EnrollmentMaximum      int
+	SeatsAvailable         int
+	WaitlistCapacity       int
+	WaitlistActual         int
+	WaitlistSeatsAvailable int
+}
+
+func (e Enrollment) HasSeat() bool {
+	return e.SeatsAvailable > 0
+}
+
+func (e Enrollment) HasWaitlistSpot() bool {
+	return e.WaitlistCapacity > e.WaitlistActual && e.WaitlistSeatsAvailable > 0
+}
+
+func (t *Task) CheckEnrollmentData(ctx context.Context, CRN string) (Enrollment, error) {
 	headers := [][2]string{
 		{"accept", "*/*"},
 		{"accept-language", "en-US,en;q=0.9"},
@@ -24,17 +69,11 @@ func (t *Task) CheckEnrollmentData(CRN string) error {
 		"courseReferenceNumber": {CRN},
 	}
 
-	response, err := t.DoReq(t.MakeReq("POST", "https://reg.oci.fhda.edu/StudentRegistrationSsb/ssb/searchResults/getEnrollmentInfo", headers, []byte(values.Encode())), fmt.Sprintf("Getting Enrollment Data (%s)", CRN), true)
+	enrollment := Enrollment{CRN: CRN}
+	response, err := t.DoReq(t.MakeReq(ctx, "POST", t.regURL("/StudentRegistrationSsb/ssb/searchResults/getEnrollmentInfo"), headers, []byte(values.Encode())), fmt.Sprintf("Getting Enrollment Data (%s)", CRN), true)
 	if err != nil {
-		fmt.Println(err)
-		if response != nil {
-			discardResp(response)
-		}
-		return err
-	}
-
-	if response == nil {
-		return fmt.Errorf("received nil response")
+		discardResp(response)
+		return enrollment, err
 	}
 
 	body, _ := readBody(response)
@@ -42,74 +81,255 @@ func (t *Task) CheckEnrollmentData(CRN string) error {
 	document, err := goquery.NewDocumentFromReader(reader)
 	if err != nil {
 		discardResp(response)
-		return err
+		return enrollment, err
 	}
 
-	var enrollmentSeatsAvailable, waitlistCapacity, waitlistActual, waitlistSeatsAvailable string
-
 	document.Find("span.status-bold").Each(func(i int, s *goquery.Selection) {
-		if strings.Contains(s.Text(), "Enrollment Seats Available:") {
-			enrollmentSeatsAvailable = s.Next().Text()
+		value, _ := strconv.Atoi(strings.TrimSpace(s.Next().Text()))
+		if strings.Contains(s.Text(), "Enrollment Actual:") {
+			enrollment.EnrollmentActual = value
+		} else if strings.Contains(s.Text(), "Enrollment Maximum:") {
+			enrollment.EnrollmentMaximum = value
+		} else if strings.Contains(s.Text(), "Enrollment Seats Available:") {
+			enrollment.SeatsAvailable = value
 		} else if strings.Contains(s.Text(), "Waitlist Seats Available:") {
-			waitlistSeatsAvailable = s.Next().Text()
+			enrollment.WaitlistSeatsAvailable = value
 		} else if strings.Contains(s.Text(), "Waitlist Capacity:") {
-			waitlistCapacity = s.Next().Text()
+			enrollment.WaitlistCapacity = value
 		} else if strings.Contains(s.Text(), "Waitlist Actual:") {
-			waitlistActual = s.Next().Text()
+			enrollment.WaitlistActual = value
 		}
 	})
+	return enrollment, nil
+}
 
-	numEnrollmentSeatsAvailable, _ := strconv.Atoi(enrollmentSeatsAvailable)
-	numWaitlistCapacity, _ := strconv.Atoi(waitlistCapacity)
-	numWaitlistActual, _ := strconv.Atoi(waitlistActual)
-	numWaitlistSeatsAvailable, _ := strconv.Atoi(waitlistSeatsAvailable)
+// watchOptions returns the task's WatchOptions with every unset field taken
+// from DefaultWatchOptions.
+func (t *Task) watchOptions() WatchOptions {
+	options := t.WatchOptions
+	if options.Interval <= 0 {
+		options.Interval = DefaultWatchOptions.Interval
+	}
+	if options.Intervals == nil {
+		options.Intervals = DefaultWatchOptions.Intervals
+	}
+	if options.Jitter == 0 {
+		options.Jitter = DefaultWatchOptions.Jitter
+	}
+	if options.MaxDuration <= 0 {
+		options.MaxDuration = DefaultWatchOptions.MaxDuration
+	}
+	return options
+}
 
-	// Auto-enroll if ANY seat is available (enrollment OR waitlist)
-	hasEnrollmentSeat := numEnrollmentSeatsAvailable > 0
-	hasWaitlistSeat := numWaitlistCapacity > numWaitlistActual && numWaitlistSeatsAvailable > 0
+func (o WatchOptions) nextPoll(CRN string, from time.Time) time.Time {
+	interval := o.Interval
+	if override, ok := o.Intervals[CRN]; ok && override > 0 {
+		interval = override
+	}
+	if o.Jitter > 0 {
+		interval += time.Duration(float64(interval) * o.Jitter * (2*rand.Float64() - 1))
+	}
+	return from.Add(interval)
+}
 
-	if hasEnrollmentSeat || hasWaitlistSeat {
-		var message string
-		if hasEnrollmentSeat {
-			message = fmt.Sprintf("[%s] %s Enrollment seat(s) is now Available - Auto-enrolling!", CRN, enrollmentSeatsAvailable)
-			t.WaitlistTask = false
-		} else {
-			message = fmt.Sprintf("[%s] %s Waitlist spot(s) is now Available - Auto-enrolling!", CRN, waitlistSeatsAvailable)
-			t.WaitlistTask = true
+// Watch polls every CRN on its own schedule and signs up for each one as soon
+// as an enrollment seat or waitlist spot opens. Each cycle asks for a CRN's
+// status at most once; CRNs that have been signed up for drop out and the
+// rest keep being watched. CRNs that open in the same cycle are signed up for
+// concurrently, each in its own Registration. A linked CRN, like a lecture,
+// only counts as open once one of its linked sections has room too, and is
+// signed up for together with it. A signup that fails for any reason other
+// than Banner rejecting the CRN maxWatchSignups times leaves it watched,
+// except for a login that cannot succeed, which ends the watch.
+func (t *Task) Watch(ctx context.Context) error {
+	options := t.watchOptions()
+	if options.MaxDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.MaxDuration)
+		defer cancel()
+	}
+
+	t.HomepageURL = t.regURL("/StudentRegistrationSsb/saml/login")
+	defer t.Client.CloseIdleConnections()
+
+	links, linksErr := t.linkedOptions(ctx, t.CRNs)
+	if ctx.Err() != nil {
+		return t.watchEnded(ctx, nil)
+	}
+	if linksErr != nil {
+		t.logf("Could Not Look Up Linked Sections, Watching Without Them for Now: %v\n", linksErr)
+	}
+	watching, links := watchLinks(t.CRNs, links)
+	pendingDrops := append([]string(nil), t.DropCRNs...)
+	due := make(map[string]time.Time)
+	rejections := make(map[string]int)
+	var errs []error
+
+	for len(watching) > 0 {
+		// Look the linked sections up again each round until it goes through
+		if linksErr != nil {
+			var found map[string][][]string
+			if found, linksErr = t.linkedOptions(ctx, watching); linksErr == nil {
+				watching, links = watchLinks(watching, found)
+			} else if ctx.Err() != nil {
+				return t.watchEnded(ctx, errs)
+			}
+		}
+
+		now := time.Now()
+		var remaining []string
+		var openings []Enrollment
+		companions := make(map[string][]string)
+		for _, CRN := range watching {
+			if now.Before(due[CRN]) {
+				remaining = append(remaining, CRN)
+				continue
+			}
+
+			enrollment, err := t.CheckEnrollmentData(ctx, CRN)
+			if ctx.Err() != nil {
+				return t.watchEnded(ctx, errs)
+			}
+			if err != nil {
+				t.logln(err)
+			} else {
+				t.recordEnrollment(enrollment)
+				if !enrollment.HasSeat() && !enrollment.HasWaitlistSpot() {
+					t.logf("[%s] - (Not Available - Enrollment: %d, Waitlist: %d)\n", CRN, enrollment.SeatsAvailable, enrollment.WaitlistSeatsAvailable)
+				} else if options := links[CRN]; len(options) == 0 {
+					openings = append(openings, enrollment)
+					continue
+				} else if option, err := t.openLinkedOption(ctx, enrollment, options); err != nil {
+					t.logln(err)
+				} else if option == nil {
+					t.logf("[%s] - (Open, but none of its linked sections %s are)\n", CRN, formatOptions(options))
+				} else {
+					companions[CRN] = option
+					openings = append(openings, enrollment)
+					continue
+				}
+			}
+			remaining = append(remaining, CRN)
+			due[CRN] = options.nextPoll(CRN, time.Now())
+		}
+
+		registrations := make([]*Registration, len(openings))
+		for i, enrollment := range openings {
+			registrations[i] = &Registration{
+				CRNs:     append([]string{enrollment.CRN}, companions[enrollment.CRN]...),
+				Waitlist: !enrollment.HasSeat(),
+				Swaps:    make(map[string]string),
+			}
+			if drop, ok := t.Swaps[enrollment.CRN]; ok && slices.Contains(pendingDrops, drop) {
+				registrations[i].DropCRNs = []string{drop}
+				registrations[i].Swaps[enrollment.CRN] = drop
+			}
+		}
+		// Unpaired drops ride along with the first opening only, so two
+		// openings never both try to drop the same class.
+		if len(registrations) > 0 {
+			for _, drop := range pendingDrops {
+				if !t.swapped(drop) {
+					registrations[0].DropCRNs = append(registrations[0].DropCRNs, drop)
+				}
+			}
+		}
+
+		results := t.registerOpenings(ctx, openings, registrations)
+		if ctx.Err() != nil {
+			return t.watchEnded(ctx, append(errs, results...))
+		}
+
+		var stillPending []string
+		for _, CRN := range pendingDrops {
+			dropped := false
+			for _, registration := range registrations {
+				dropped = dropped || registration.dropped(CRN)
+			}
+			if !dropped {
+				stillPending = append(stillPending, CRN)
+			}
+		}
+		pendingDrops = stillPending
+
+		for i, enrollment := range openings {
+			CRN := enrollment.CRN
+			err := results[i]
+			if err == nil {
+				continue
+			}
+			t.logln(err)
+			// Only Banner refusing the CRN every time is worth giving up on;
+			// a timeout, a server error or an expired session is not
+			var crnError *CRNError
+			if errors.As(err, &crnError) {
+				rejections[CRN]++
+				if rejections[CRN] >= maxWatchSignups {
+					errs = append(errs, err)
+					continue
+				}
+			}
+			// Retrying a login that cannot succeed would only lock the account
+			if errors.Is(err, ErrInvalidCredentials) || errors.Is(err, ErrLoginChallenge) {
+				return errors.Join(append(errs, err)...)
+			}
+			remaining = append(remaining, CRN)
+			due[CRN] = options.nextPoll(CRN, time.Now())
+		}
+
+		watching = remaining
+		if len(watching) == 0 {
+			break
+		}
+
+		next := due[watching[0]]
+		for _, CRN := range watching[1:] {
+			if due[CRN].Before(next) {
+				next = due[CRN]
+			}
+		}
+		if err := sleepCtx(ctx, time.Until(next)); err != nil {
+			return t.watchEnded(ctx, errs)
 		}
-		
-		t.SendNotification("Watch Task - Seat Available", message)
-		fmt.Println(message)
-		t.CRNs = []string{CRN}
-		t.Signup()
-		return nil
-	} else {
-		// No seats available - continue monitoring
-		// Since hasEnrollmentSeat is false, we know numEnrollmentSeatsAvailable <= 0
-		// Show status message with current availability
-		fmt.Printf("[%s] - (Not Available - Enrollment: %s, Waitlist: %s)\n", CRN, enrollmentSeatsAvailable, waitlistSeatsAvailable)
-		time.Sleep(5 * time.Second)
-		return t.CheckEnrollmentData(CRN)
 	}
+	return errors.Join(errs...)
 }
 
-func (t *Task) Watch() error {
-
+// registerOpenings signs up for every opening at once and returns each
+// attempt's error in the same order.
+func (t *Task) registerOpenings(ctx context.Context, openings []Enrollment, registrations []*Registration) []error {
+	results := make([]error, len(openings))
 	var waitGroup sync.WaitGroup
-	errChan := make(chan error, len(t.CRNs))
+	for i, enrollment := range openings {
+		var message string
+		if enrollment.HasSeat() {
+			message = fmt.Sprintf("[%s] %d Enrollment seat(s) is now Available - Auto-enrolling!", enrollment.CRN, enrollment.SeatsAvailable)
+		} else {
+			message = fmt.Sprintf("[%s] %d Waitlist spot(s) is now Available - Auto-enrolling!", enrollment.CRN, enrollment.WaitlistSeatsAvailable)
+		}
+		if linked := registrations[i].CRNs[1:]; len(linked) > 0 {
+			message += fmt.Sprintf(" (with linked %s)", strings.Join(linked, ", "))
+		}
+		t.logln(message)
 
-	for _, course := range t.CRNs {
 		waitGroup.Add(1)
-
-		go func(course string) {
+		go func(i int, message string) {
 			defer waitGroup.Done()
-			if err := t.CheckEnrollmentData(course); err != nil {
-				errChan <- err
-			}
-		}(course)
+			t.SendNotification(ctx, "Watch Task - Seat Available", message)
+			results[i] = t.register(ctx, registrations[i])
+		}(i, message)
 	}
-
 	waitGroup.Wait()
-	close(errChan)
-	return nil
+	return results
+}
+
+// watchEnded turns the end of the watch context into Watch's result: a
+// deadline from MaxDuration is a normal finish, a cancellation is not.
+func (t *Task) watchEnded(ctx context.Context, errs []error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		t.logln("Watch Reached Its Maximum Duration")
+		return errors.Join(errs...)
+	}
+	return errors.Join(append(errs, ctx.Err())...)
 }
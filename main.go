@@ -1,60 +1,31 @@
 package main
 
 import (
-	"bufio"
+	"bytes"
 	"context"
-	"encoding/csv"
+	"errors"
 	"fmt"
+	"io"
 	"math/rand"
 	"net"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"regexp"
+	"register-bot/internal/config"
+	"register-bot/internal/fakebanner"
+	"register-bot/internal/history"
 	"register-bot/internal/tasks"
+	"sort"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	tls_client "github.com/bogdanfinn/tls-client"
 	"github.com/bogdanfinn/tls-client/profiles"
 )
 
-// TaskConfig represents a single task configuration from CSV
-type TaskConfig struct {
-	Term              string
-	Subject           string
-	Mode              string
-	CRNs              []string
-	DropCRNs          []string
-	RegistrationTime  string
-	Username          string
-	Password          string
-	WebhookURL        string
-}
-
-// loadCredentials reads username, password, and webhook from .credentials file
-func loadCredentials() (string, string, string) {
-	file, err := os.Open("config/.credentials")
-	if err != nil {
-		return "", "", ""
-	}
-	defer file.Close()
-
-	var username, password, webhook string
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if strings.HasPrefix(line, "username=") {
-			username = strings.TrimPrefix(line, "username=")
-		} else if strings.HasPrefix(line, "password=") {
-			password = strings.TrimPrefix(line, "password=")
-		} else if strings.HasPrefix(line, "webhook=") {
-			webhook = strings.TrimPrefix(line, "webhook=")
-		}
-	}
-
-	return username, password, webhook
-}
-
 // createHTTPClient creates a new HTTP client with its own cookie jar and dialer
 func createHTTPClient() (tls_client.HttpClient, error) {
 	var dnsServers = []string{"8.8.8.8", "8.8.4.4", "1.1.1.1", "1.0.0.1"}
@@ -83,192 +54,566 @@ func createHTTPClient() (tls_client.HttpClient, error) {
 	return tls_client.NewHttpClient(tls_client.NewLogger(), client_options...)
 }
 
-// parseCSVRow parses a CSV row into a TaskConfig
-func parseCSVRow(row []string, credUsername, credPassword, credWebhook string) (*TaskConfig, error) {
-	if len(row) < 5 {
-		return nil, fmt.Errorf("invalid row: expected at least 5 columns, got %d", len(row))
+// startOfflineServer starts the bundled fake Banner/SSO server when
+// REGISTER_BOT_OFFLINE is set, so tasks can run without touching FHDA.
+// REGISTER_BOT_OFFLINE_MFA makes its SSO ask for a step after the password:
+// passcode (123456), push, consent, expiring or expired.
+func startOfflineServer() (*fakebanner.Server, error) {
+	if os.Getenv("REGISTER_BOT_OFFLINE") == "" {
+		return nil, nil
+	}
+	server, err := fakebanner.New(fakebanner.DemoSections()...)
+	if err != nil {
+		return nil, err
+	}
+	server.Transcript = []fakebanner.Class{
+		{Term: "Fall 2025", Subject: "MATH", Number: "1B", Title: "Calculus II", LetterGrade: "A", Credits: "5"},
+		{Term: "Fall 2025", Subject: "ENGL", Number: "1A", Title: "Composition and Reading", LetterGrade: "A-", Credits: "5"},
 	}
+	server.Enroll("38894", "Registered")
+	server.Challenge = os.Getenv("REGISTER_BOT_OFFLINE_MFA")
+	server.Passcode = "123456"
+	server.PushPolls = 2
+	fmt.Printf("Offline mode: using fake server at %s\n", server.URL)
+	return server, nil
+}
 
-	config := &TaskConfig{
-		Term:             row[0],
-		Subject:          row[1],
-		Mode:             strings.TrimSpace(row[2]),
-		CRNs:             strings.Split(strings.Trim(row[3], "\""), ","),
-		DropCRNs:         []string{}, // Default empty
-		RegistrationTime: row[4],
+// runTask runs a single task configuration. Tasks for the same username
+// share their client, and so their login, through sessions.
+func runTask(ctx context.Context, cfg *config.TaskConfig, sessions *tasks.SessionManager, order *tasks.SubmitOrder, log io.Writer, offline *fakebanner.Server) (tasks.Result, error) {
+	endpoints := cfg.Endpoints
+	if offline != nil {
+		endpoints = offline.Endpoints()
+		cfg.WebhookURL = offline.WebhookURL()
+		cfg.Notify = nil
+		cfg.SettingsFile = ""
+		if cfg.SessionFile != "" {
+			cfg.SessionFile = filepath.Join(os.TempDir(), "register-bot-offline"+filepath.Base(cfg.SessionFile))
+		}
 	}
 
-	// Check if there's a 6th column for DropCRNs
-	if len(row) >= 6 {
-		config.DropCRNs = strings.Split(strings.Trim(row[5], "\""), ",")
+	// Create task instance
+	t := &tasks.Task{
+		Log:           log,
+		Prompt:        prompt,
+		Endpoints:     endpoints,
+		Username:      cfg.Username,
+		Password:      cfg.Password,
+		WebhookURL:    cfg.WebhookURL,
+		WebhookURLs:   cfg.Notify,
+		SessionFile:   cfg.SessionFile,
+		SettingsFile:  cfg.SettingsFile,
+		SettingsRow:   cfg.SettingsRow,
+		Subject:       cfg.Subject,
+		Mode:          cfg.Mode,
+		CRNs:          cfg.CRNs,
+		DropCRNs:      cfg.DropCRNs,
+		Alternates:    cfg.Alternates,
+		Swaps:         cfg.Swaps,
+		DryRun:        cfg.DryRun,
+		Output:        cfg.Output,
+		Format:        cfg.Format,
+		Filters:       cfg.Filters,
+		OnConflict:    cfg.OnConflict,
+		Courses:       cfg.Courses,
+		Preferences:   cfg.Preferences,
+		ScheduleLimit: cfg.Limit,
+		WatchOptions:  cfg.WatchOptions,
+		Priority:      cfg.Priority,
+	}
+	if slot, ok := submitSlot(cfg); ok {
+		t.SubmitOrder = order
+		t.SubmitSlot = slot
+		defer t.Submitted()
+	}
+	account, err := sessions.Account(cfg.Username)
+	if err != nil {
+		return tasks.Result{}, fmt.Errorf("creating HTTP client: %w", err)
+	}
+	t.Client = account.Client
+	t.Account = account
+	if cfg.History != "" {
+		store, err := history.Open(cfg.History)
+		if err != nil {
+			return tasks.Result{}, fmt.Errorf("opening seat history: %w", err)
+		}
+		defer store.Close()
+		t.History = store
 	}
 
-	// Clean up CRNs (remove empty strings)
-	var cleanCRNs []string
-	for _, crn := range config.CRNs {
-		crn = strings.TrimSpace(crn)
-		if crn != "" {
-			cleanCRNs = append(cleanCRNs, crn)
+	// Wait for the task's scheduled start, if any
+	if wait := time.Until(cfg.Schedule); !cfg.Schedule.IsZero() && wait > 0 {
+		fmt.Fprintf(log, "[%s] Scheduled to start at %s\n", cfg.Label(), cfg.Schedule.Format(time.RFC1123))
+		select {
+		case <-ctx.Done():
+			return tasks.Result{}, ctx.Err()
+		case <-time.After(wait):
 		}
 	}
-	config.CRNs = cleanCRNs
 
-	// Clean up DropCRNs
-	var cleanDropCRNs []string
-	for _, crn := range config.DropCRNs {
-		crn = strings.TrimSpace(crn)
-		if crn != "" {
-			cleanDropCRNs = append(cleanDropCRNs, crn)
+	// Get term ID
+	if cfg.Term != "" {
+		if err := t.GetTermByName(ctx, cfg.Term); err != nil {
+			return tasks.Result{}, err
 		}
 	}
-	config.DropCRNs = cleanDropCRNs
 
-	// Set default mode to Watch if empty
-	if config.Mode == "" {
-		config.Mode = "Watch"
+	// Handle Release mode (wait until registration time)
+	if cfg.Mode == "Release" {
+		t.Mode = "Signup"
+		// Refuse missing linked sections or a conflicting schedule now rather
+		// than at registration time; Signup only checks again if the CRNs change.
+		if err := t.CheckSignup(ctx); err != nil {
+			return tasks.Result{}, err
+		}
+		pattern := regexp.MustCompile(`\d{2}/\d{2}/\d{4} \d{2}:\d{2} [APM]{2}`)
+		matches := pattern.FindAllString(cfg.RegistrationTime, -1)
+		if len(matches) == 0 {
+			return tasks.Result{}, fmt.Errorf("invalid registration time format %q", cfg.RegistrationTime)
+		}
+
+		location, _ := time.LoadLocation("America/Los_Angeles")
+		targetTime, _ := time.ParseInLocation("01/02/2006 03:04 PM", matches[0], location)
+		now := time.Now().In(location)
+		timeToWait := targetTime.Sub(now) - 5*time.Minute
+
+		if cfg.DryRun {
+			fmt.Fprintf(log, "[%s] Dry run: rehearsing now instead of waiting until %s\n", cfg.Label(), targetTime.Format(time.RFC1123))
+		} else if now.Before(targetTime) {
+			fmt.Fprintf(log, "[%s] Will continue in: %s\n", cfg.Label(), timeToWait.String())
+			select {
+			case <-ctx.Done():
+				return tasks.Result{}, ctx.Err()
+			case <-time.After(timeToWait):
+			}
+		}
 	}
 
-	// Read username and password with priority: env vars > credentials file
-	if envUsername := os.Getenv("REGISTER_BOT_USERNAME"); envUsername != "" {
-		config.Username = envUsername
-	} else if credUsername != "" {
-		config.Username = credUsername
-	} else {
-		return nil, fmt.Errorf("username not found in environment variables or .credentials file")
+	// Log task start
+	fmt.Fprintf(log, "[%s] Starting task: Mode=%s, Subject=%s, CRNs=%v\n", cfg.Label(), t.Mode, t.Subject, t.CRNs)
+
+	// Run the task
+	return t.Run(ctx)
+}
+
+// reportResult prints what a task did and whether it failed.
+func reportResult(log io.Writer, cfg *config.TaskConfig, result tasks.Result, err error) {
+	label := cfg.Label()
+	if len(result.Registered) > 0 {
+		fmt.Fprintf(log, "[%s] Registered: %v\n", label, result.Registered)
+	}
+	if len(result.Waitlisted) > 0 {
+		fmt.Fprintf(log, "[%s] Waitlisted: %v\n", label, result.Waitlisted)
+	}
+	if len(result.Dropped) > 0 {
+		fmt.Fprintf(log, "[%s] Dropped: %v\n", label, result.Dropped)
+	}
+	for _, file := range result.Files {
+		fmt.Fprintf(log, "[%s] Wrote %s\n", label, file)
 	}
 
-	if envPassword := os.Getenv("REGISTER_BOT_PASSWORD"); envPassword != "" {
-		config.Password = envPassword
-	} else if credPassword != "" {
-		config.Password = credPassword
-	} else {
-		return nil, fmt.Errorf("password not found in environment variables or .credentials file")
+	switch {
+	case err == nil:
+		fmt.Fprintf(log, "[%s] %s task finished\n", label, cfg.Mode)
+	case errors.Is(err, context.Canceled):
+		fmt.Fprintf(log, "[%s] %s task cancelled\n", label, cfg.Mode)
+	case errors.Is(err, tasks.ErrInvalidCredentials):
+		fmt.Fprintf(log, "[%s] Login failed, check your username and password: %v\n", label, err)
+	case errors.Is(err, tasks.ErrLoginChallenge):
+		fmt.Fprintf(log, "[%s] Login needs a step Register Bot cannot finish: %v\n", label, err)
+	case errors.Is(err, tasks.ErrRegistrationClosed):
+		fmt.Fprintf(log, "[%s] Registration is not open: %v\n", label, err)
+	case errors.Is(err, tasks.ErrCRNRejected):
+		fmt.Fprintf(log, "[%s] Some CRNs were rejected: %v\n", label, err)
+	default:
+		fmt.Fprintf(log, "[%s] %s task failed: %v\n", label, cfg.Mode, err)
 	}
+}
 
-	// Read webhook from credentials file or environment variable
-	if envWebhook := os.Getenv("REGISTER_BOT_WEBHOOK"); envWebhook != "" {
-		config.WebhookURL = envWebhook
-	} else if credWebhook != "" {
-		config.WebhookURL = credWebhook
-	} else {
-		config.WebhookURL = "" // Webhook is optional
+// submitSlot returns when a task submits its batch, for ordering tasks that
+// submit at the same time by priority: its registration time, or "" for a
+// signup that goes as soon as registration opens. Watch tasks and dry runs
+// are not ordered.
+func submitSlot(cfg *config.TaskConfig) (string, bool) {
+	if cfg.DryRun {
+		return "", false
+	}
+	switch cfg.Mode {
+	case "Release":
+		return cfg.RegistrationTime, true
+	case "Signup", "Reconcile":
+		return "", true
+	}
+	return "", false
+}
+
+// runTasks runs every task concurrently and returns how many failed. Tasks
+// for the same username log in once and share the session, and tasks that
+// submit at the same time do so highest priority first.
+func runTasks(ctx context.Context, taskConfigs []*config.TaskConfig, offline *fakebanner.Server) int {
+	sessions := &tasks.SessionManager{NewClient: func() (tasks.HTTPClient, error) {
+		if offline != nil {
+			return offline.Client(), nil
+		}
+		return createHTTPClient()
+	}}
+	defer sessions.Close()
+	order := &tasks.SubmitOrder{}
+	for _, cfg := range taskConfigs {
+		if slot, ok := submitSlot(cfg); ok {
+			order.Join(slot, cfg.Priority)
+		}
 	}
 
-	return config, nil
+	var wg sync.WaitGroup
+	var failedMu sync.Mutex
+	failed := 0
+	for i, cfg := range taskConfigs {
+		wg.Add(1)
+		go func(idx int, cfg *config.TaskConfig) {
+			defer wg.Done()
+			log, closeLog, err := taskLog(cfg)
+			if err != nil {
+				fmt.Printf("[%s] Error Opening Log: %v\n", cfg.Label(), err)
+				log, closeLog = os.Stdout, func() error { return nil }
+			}
+			defer closeLog()
+			result, err := runTask(ctx, cfg, sessions, order, log, offline)
+			reportResult(log, cfg, result, err)
+			if err != nil && !errors.Is(err, context.Canceled) {
+				failedMu.Lock()
+				failed++
+				failedMu.Unlock()
+			}
+		}(i, cfg)
+	}
+
+	// Wait for all tasks to complete
+	wg.Wait()
+	return failed
 }
 
-// runTask runs a single task configuration
-func runTask(config *TaskConfig) {
-	// Create a new HTTP client for this task (each task needs its own session)
-	client, err := createHTTPClient()
+// taskLog returns where a task's output goes. A task signed in with a
+// credentials profile has its lines marked with the profile name and also
+// written to the profile's log file.
+func taskLog(cfg *config.TaskConfig) (io.Writer, func() error, error) {
+	noClose := func() error { return nil }
+	if cfg.Account == "" {
+		return os.Stdout, noClose, nil
+	}
+	out := &prefixWriter{w: os.Stdout, prefix: "[" + cfg.Account + "] "}
+	if cfg.LogFile == "" {
+		return out, noClose, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(cfg.LogFile), 0o700); err != nil {
+		return nil, nil, err
+	}
+	file, err := os.OpenFile(cfg.LogFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
 	if err != nil {
-		fmt.Printf("[%s] Error creating HTTP client: %v\n", config.Term, err)
-		return
+		return nil, nil, err
 	}
-	defer client.CloseIdleConnections()
+	return io.MultiWriter(out, file), file.Close, nil
+}
 
-	// Create task instance
-	t := &tasks.Task{
-		Client:     client,
-		Username:   config.Username,
-		Password:   config.Password,
-		WebhookURL: config.WebhookURL,
-		Subject:    config.Subject,
-		Mode:       config.Mode,
-		CRNs:       config.CRNs,
-		DropCRNs:   config.DropCRNs,
-	}
+// prefixWriter starts every line written to it with prefix.
+type prefixWriter struct {
+	w      io.Writer
+	prefix string
 
-	// Get term ID
-	t.GetTermByName(config.Term)
+	mu      sync.Mutex
+	midLine bool
+}
 
-	// Handle Release mode (wait until registration time)
-	if config.Mode == "Release" {
-		t.Mode = "Signup"
-		pattern := regexp.MustCompile(`\d{2}/\d{2}/\d{4} \d{2}:\d{2} [APM]{2}`)
-		matches := pattern.FindAllString(config.RegistrationTime, -1)
-		if len(matches) == 0 {
-			fmt.Printf("[%s] Invalid Registration Time Format\n", config.Term)
-			return
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var out []byte
+	for rest := b; len(rest) > 0; {
+		if !p.midLine {
+			out = append(out, p.prefix...)
+		}
+		line, after, found := bytes.Cut(rest, []byte("\n"))
+		out = append(out, line...)
+		if found {
+			out = append(out, '\n')
 		}
+		p.midLine = !found
+		rest = after
+	}
+	if _, err := p.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
 
-		location, _ := time.LoadLocation("America/Los_Angeles")
-		targetTime, _ := time.ParseInLocation("01/02/2006 03:04 PM", matches[0], location)
-		now := time.Now().In(location)
-		timeToWait := targetTime.Sub(now) - 5*time.Minute
+// loadTaskFile loads the tasks for the run command. The file is path, else
+// REGISTER_BOT_CONFIG, else the first of config/tasks.yaml, tasks.yml,
+// tasks.json or settings.csv.
+func loadTaskFile(path string, credentials config.Credentials) ([]*config.TaskConfig, string, error) {
+	if path == "" {
+		path = os.Getenv("REGISTER_BOT_CONFIG")
+	}
+	if path == "" {
+		found, err := config.Find("config")
+		if err != nil {
+			return nil, "", err
+		}
+		path = found
+	}
 
-		if now.Before(targetTime) {
-			fmt.Printf("[%s] Will continue in: %s\n", config.Term, timeToWait.String())
-			time.Sleep(timeToWait)
+	taskConfigs, err := config.Load(path, credentials)
+	if err != nil {
+		return nil, path, err
+	}
+	if len(taskConfigs) == 0 {
+		return nil, path, fmt.Errorf("no valid task configurations found in %s", path)
+	}
+	return taskConfigs, path, nil
+}
+
+// listTerms prints every term Banner offers, newest first.
+func listTerms(ctx context.Context, endpoints tasks.Endpoints, offline *fakebanner.Server) error {
+	t := &tasks.Task{Endpoints: endpoints}
+	if offline != nil {
+		t.Client = offline.Client()
+		t.Endpoints = offline.Endpoints()
+	} else {
+		tlsClient, err := createHTTPClient()
+		if err != nil {
+			return fmt.Errorf("creating HTTP client: %w", err)
 		}
+		t.Client = tlsClient
 	}
+	defer t.Client.CloseIdleConnections()
 
-	// Log task start
-	fmt.Printf("[%s] Starting task: Mode=%s, Subject=%s, CRNs=%v\n", config.Term, t.Mode, t.Subject, t.CRNs)
+	if err := t.GetTerms(ctx); err != nil {
+		return err
+	}
+	descriptions := make([]string, 0, len(t.Terms))
+	for description := range t.Terms {
+		descriptions = append(descriptions, description)
+	}
+	sort.Slice(descriptions, func(i, j int) bool {
+		return t.Terms[descriptions[i]] > t.Terms[descriptions[j]]
+	})
+	for _, description := range descriptions {
+		fmt.Printf("%s  %s\n", t.Terms[description], description)
+	}
+	return nil
+}
 
-	// Run the task
-	t.Run()
+// showHistory prints the seat history report for the history command.
+func showHistory(cmd command) error {
+	if _, err := os.Stat(cmd.Database); err != nil {
+		return err
+	}
+	store, err := history.Open(cmd.Database)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	query := history.Query{
+		Term:    cmd.Task.Term,
+		CRNs:    cmd.Task.CRNs,
+		Subject: strings.ToUpper(cmd.Task.Subject),
+	}
+	// History is keyed by term code; accept the description too.
+	if query.Term != "" && !termCodePattern.MatchString(query.Term) {
+		if query.Term, err = tasks.BuildTermId(query.Term); err != nil {
+			return err
+		}
+	}
+	observations, err := store.Observations(query)
+	if err != nil {
+		return err
+	}
+	if len(observations) == 0 {
+		fmt.Printf("No seat history recorded in %s yet; run search, catalog or watch with -history %s\n", cmd.Database, cmd.Database)
+		return nil
+	}
+	return history.WriteReport(os.Stdout, history.Summarize(observations))
 }
 
-func main() {
-	file, err := os.Open("config/settings.csv")
+// diffCatalogs prints what changed between two catalog snapshots and, with
+// -notify, sends it to the webhooks.
+func diffCatalogs(ctx context.Context, cmd command, credentials config.Credentials, offline *fakebanner.Server) error {
+	from, err := tasks.LoadCatalogSnapshot(cmd.Args[0])
 	if err != nil {
-		fmt.Println("Error Opening File:", err)
-		return
+		return err
+	}
+	to, err := tasks.LoadCatalogSnapshot(cmd.Args[1])
+	if err != nil {
+		return err
+	}
+	if to.TakenAt.Before(from.TakenAt) {
+		fmt.Println("Note: the first snapshot is newer than the second")
+	}
+	diff, err := tasks.DiffCatalogs(from, to)
+	if err != nil {
+		return err
+	}
+	fmt.Print(diff)
+
+	if !cmd.Notify || diff.Empty() {
+		return nil
+	}
+	t := &tasks.Task{WebhookURL: credentials.WebhookURL()}
+	if offline != nil {
+		t.Client = offline.Client()
+		t.WebhookURL = offline.WebhookURL()
+	} else {
+		tlsClient, err := createHTTPClient()
+		if err != nil {
+			return fmt.Errorf("creating HTTP client: %w", err)
+		}
+		t.Client = tlsClient
+	}
+	defer t.Client.CloseIdleConnections()
+	if t.WebhookURL == "" {
+		return fmt.Errorf("no webhook configured; set REGISTER_BOT_WEBHOOK or webhook= in the credentials file")
 	}
-	defer file.Close()
+	return t.NotifyDiff(ctx, diff)
+}
 
-	reader := csv.NewReader(file)
+// saveLogin asks for a profile's username, password and webhook and saves
+// them to the credential store.
+func saveLogin(cmd command, store config.CredentialStore) error {
+	if store == nil {
+		return fmt.Errorf("the file store is edited by hand in %s; use -store vault or -store keyring", cmd.CredentialsPath)
+	}
+	var login config.Login
+	var err error
+	if login.Username, err = prompt("Username: "); err != nil {
+		return err
+	}
+	if login.Password, err = promptSecret("Password: "); err != nil {
+		return err
+	}
+	if login.Username == "" || login.Password == "" {
+		return errors.New("username and password are required")
+	}
+	if login.Webhook, err = prompt("Webhook (optional): "); err != nil {
+		return err
+	}
+	if err := store.Save(cmd.Task.Account, login); err != nil {
+		return err
+	}
+	profile := "the default credentials"
+	if cmd.Task.Account != "" {
+		profile = "[" + cmd.Task.Account + "]"
+	}
+	fmt.Printf("Saved Login for %s to %s\n", profile, store)
+	return nil
+}
 
-	// Read header
-	_, err = reader.Read()
+var termCodePattern = regexp.MustCompile(`^\d{6}$`)
+
+func main() {
+	cmd, err := parseCommand(os.Args[1:], os.Stderr)
 	if err != nil {
-		fmt.Println("Error Reading Header:", err)
-		return
+		exitUsage(err)
 	}
 
-	// Load credentials once (priority: env vars > credentials file)
-	credUsername, credPassword, credWebhook := loadCredentials()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// Read all CSV rows and create task configurations
-	var taskConfigs []*TaskConfig
-	for {
-		row, err := reader.Read()
+	// Load credentials once (priority: env vars > credential store >
+	// credentials file). A vault is unlocked now, not when a task needs it.
+	credentials := config.LoadCredentials(cmd.CredentialsPath)
+	if cmd.Name != "terms" && cmd.Name != "history" && (cmd.Name != "diff" || cmd.Notify) {
+		store, err := config.OpenStore(cmd.Store, cmd.CredentialsPath, vaultPassphrase)
 		if err != nil {
-			if err.Error() == "EOF" {
-				break
-			}
-			fmt.Printf("Error Reading Row: %v\n", err)
-			continue
+			fmt.Println("Error Opening Credential Store:", err)
+			os.Exit(1)
 		}
+		credentials.Store = store
+	}
 
-		config, err := parseCSVRow(row, credUsername, credPassword, credWebhook)
+	var taskConfigs []*config.TaskConfig
+	source := "the command line"
+	switch cmd.Name {
+	case "terms", "diff":
+	case "history":
+		if err := showHistory(cmd); err != nil {
+			fmt.Println("Error Reading Seat History:", err)
+			os.Exit(1)
+		}
+		return
+	case "store":
+		if err := saveLogin(cmd, credentials.Store); err != nil {
+			fmt.Println("Error Saving Login:", err)
+			os.Exit(1)
+		}
+		return
+	case "run":
+		taskConfigs, source, err = loadTaskFile(cmd.ConfigPath, credentials)
 		if err != nil {
-			fmt.Printf("Error parsing row: %v\n", err)
-			continue
+			fmt.Println("Error Loading Tasks:", err)
+			os.Exit(1)
 		}
+	default:
+		cfg, err := cmd.taskConfig(credentials)
+		if err != nil {
+			exitUsage(err)
+		}
+		taskConfigs = []*config.TaskConfig{cfg}
+	}
 
-		taskConfigs = append(taskConfigs, config)
+	// Service URLs: flags > env vars > the task file's endpoints block
+	endpoints, err := cmd.endpoints()
+	if err != nil {
+		exitUsage(err)
+	}
+	for _, cfg := range taskConfigs {
+		cfg.Endpoints = endpoints.Or(cfg.Endpoints)
 	}
 
-	if len(taskConfigs) == 0 {
-		fmt.Println("No valid task configurations found in settings.csv")
+	offline, err := startOfflineServer()
+	if err != nil {
+		fmt.Println("Error starting offline server:", err)
+		os.Exit(1)
+	}
+	if offline != nil {
+		defer offline.Close()
+	}
+
+	if cmd.Name == "diff" {
+		if err := diffCatalogs(ctx, cmd, credentials, offline); err != nil {
+			fmt.Println("Error Comparing Snapshots:", err)
+			if offline != nil {
+				offline.Close()
+			}
+			os.Exit(1)
+		}
 		return
 	}
 
-	fmt.Printf("Loaded %d task configuration(s). Starting concurrent execution...\n\n", len(taskConfigs))
+	if cmd.Name == "terms" {
+		if err := listTerms(ctx, endpoints, offline); err != nil {
+			fmt.Println("Error Getting Terms:", err)
+			if offline != nil {
+				offline.Close()
+			}
+			os.Exit(1)
+		}
+		return
+	}
 
-	// Run all tasks concurrently
-	var wg sync.WaitGroup
-	for i, config := range taskConfigs {
-		wg.Add(1)
-		go func(idx int, cfg *TaskConfig) {
-			defer wg.Done()
-			runTask(cfg)
-		}(i, config)
+	if cmd.Name == "run" {
+		fmt.Printf("Loaded %d task configuration(s) from %s. Starting concurrent execution...\n\n", len(taskConfigs), source)
 	}
 
-	// Wait for all tasks to complete
-	wg.Wait()
-	fmt.Println("\nAll tasks completed.")
+	// Run all tasks concurrently; ones submitting at the same time go in
+	// priority order
+	failed := runTasks(ctx, taskConfigs, offline)
+	if cmd.Name == "run" {
+		fmt.Println("\nAll tasks completed.")
+	}
+	if failed > 0 {
+		if offline != nil {
+			offline.Close()
+		}
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+var (
+	stdinOnce   sync.Once
+	stdinReader *bufio.Reader
+	// promptMu keeps tasks that ask at the same time from reading each
+	// other's answers.
+	promptMu sync.Mutex
+)
+
+// prompt asks for a line on standard input, writing label to standard
+// error so it stays out of piped output.
+func prompt(label string) (string, error) {
+	promptMu.Lock()
+	defer promptMu.Unlock()
+	stdinOnce.Do(func() { stdinReader = bufio.NewReader(os.Stdin) })
+	fmt.Fprint(os.Stderr, label)
+	line, err := stdinReader.ReadString('\n')
+	if err != nil && (!errors.Is(err, io.EOF) || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// promptSecret is prompt without echoing what is typed, when standard
+// input is a terminal that allows turning echo off.
+func promptSecret(label string) (string, error) {
+	restore := disableEcho(os.Stdin)
+	value, err := prompt(label)
+	if restore() {
+		fmt.Fprintln(os.Stderr)
+	}
+	return value, err
+}
+
+// vaultPassphrase unlocks the vault with REGISTER_BOT_VAULT_PASSPHRASE or,
+// failing that, a passphrase typed at startup. A new vault's passphrase is
+// asked for twice.
+func vaultPassphrase(create bool) (string, error) {
+	if passphrase := os.Getenv("REGISTER_BOT_VAULT_PASSPHRASE"); passphrase != "" {
+		return passphrase, nil
+	}
+	if !create {
+		return promptSecret("Vault passphrase: ")
+	}
+	passphrase, err := promptSecret("New vault passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", errors.New("vault passphrase must not be empty")
+	}
+	again, err := promptSecret("Repeat passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if again != passphrase {
+		return "", errors.New("vault passphrases do not match")
+	}
+	return passphrase, nil
+}
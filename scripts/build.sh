@@ -1,7 +1,7 @@
 #!/bin/bash
 
 echo "Building register-bot..."
-go build -o bin/register-bot main.go
+go build -o bin/register-bot .
 
 if [ $? -ne 0 ]; then
     echo "Failed to build."